@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/cloudflare/cloudflared/carrier"
+	"github.com/cloudflare/cloudflared/log"
+	"github.com/cloudflare/cloudflared/transfer"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// accessTCP relays a local port, or stdin and stdout, to a TCP origin behind a tunnel hostname
+func accessTCP(args []string) error {
+	flags := flag.NewFlagSet("access tcp", flag.ContinueOnError)
+	hostname := flags.String("hostname", "", "Tunnel hostname of the TCP origin, e.g. ssh.example.com")
+	listenAddr := flags.String("url", "", "Listen for connections on `ADDRESS`, e.g. localhost:2222. Defaults to relaying stdin and stdout.")
+	var headers stringSliceFlag
+	flags.Var(&headers, "header", "Header to add to the websocket handshake, in format `NAME: VALUE`. May be repeated.")
+	logLevel := flags.String("loglevel", "info", "Application logging level {panic, fatal, error, warn, info, debug}.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: cloudflared access tcp --hostname HOSTNAME [--url ADDRESS]\n\n"+
+			"To use with ssh, add to ~/.ssh/config:\n\n"+
+			"  Host ssh.example.com\n"+
+			"    ProxyCommand cloudflared access tcp --hostname %%h\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *hostname == "" {
+		return fmt.Errorf("access tcp: --hostname is required")
+	}
+
+	logger := log.CreateLogger()
+	level, err := logrus.ParseLevel(*logLevel)
+	if err != nil {
+		return errors.Wrap(err, "invalid --loglevel")
+	}
+	logger.SetLevel(level)
+
+	originURL, err := carrier.ParseOriginURL(*hostname)
+	if err != nil {
+		return err
+	}
+	options := &carrier.StartOptions{
+		OriginURL: originURL,
+		Headers:   make(http.Header),
+	}
+	for _, header := range headers {
+		nameValue := strings.SplitN(header, ":", 2)
+		if len(nameValue) != 2 {
+			return fmt.Errorf("access tcp: --header %s must be in format NAME: VALUE", header)
+		}
+		options.Headers.Add(strings.TrimSpace(nameValue[0]), strings.TrimSpace(nameValue[1]))
+	}
+
+	if *listenAddr == "" {
+		return carrier.StdinStdout(options)
+	}
+	listener, err := net.Listen("tcp", *listenAddr)
+	if err != nil {
+		return errors.Wrapf(err, "cannot listen on %s", *listenAddr)
+	}
+	shutdownC := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		<-signals
+		close(shutdownC)
+	}()
+	return carrier.StartServer(logger, listener, shutdownC, options)
+}
+
+// accessTokenEnv is the environment variable access run passes the Access token to the command in
+const accessTokenEnv = "CF_ACCESS_TOKEN"
+
+// accessCurl runs curl with the Access token of the requested application in the cf-access-token header
+func accessCurl(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprintf(os.Stderr, "Usage: cloudflared access curl [CURL_ARGS...] URL [CURL_ARGS...]\n\n"+
+			"Runs curl with an Access token of the application at URL. You log in to the application in a browser\n"+
+			"the first time, then the token is reused until it expires.\n")
+		return flag.ErrHelp
+	}
+	appURL, err := findAppURL(args)
+	if err != nil {
+		return err
+	}
+	token, err := transfer.FetchToken(appURL)
+	if err != nil {
+		return err
+	}
+	return runWrapped(curlCommand(token, args))
+}
+
+// curlCommand returns the curl command to run with args, sending token in the cf-access-token header
+func curlCommand(token string, args []string) *exec.Cmd {
+	curlArgs := append([]string{"-H", fmt.Sprintf("%s: %s", carrier.AccessTokenHeader, token)}, args...)
+	return exec.Command("curl", curlArgs...)
+}
+
+// accessRun runs a command with the Access token of an application in the CF_ACCESS_TOKEN environment variable
+func accessRun(args []string) error {
+	flags := flag.NewFlagSet("access run", flag.ContinueOnError)
+	app := flags.String("app", "", "URL of the Access application, e.g. https://internal.example.com")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: cloudflared access run --app URL [--] COMMAND [ARGS...]\n\n"+
+			"Runs COMMAND with an Access token of the application in the %s environment variable. You log in\n"+
+			"to the application in a browser the first time, then the token is reused until it expires.\n\n", accessTokenEnv)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *app == "" {
+		return fmt.Errorf("access run: --app is required")
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("access run: missing command to run")
+	}
+	appURL, err := carrier.ParseOriginURL(*app)
+	if err != nil {
+		return err
+	}
+	token, err := transfer.FetchToken(appURL)
+	if err != nil {
+		return err
+	}
+	return runWrapped(tokenCommand(token, flags.Args()))
+}
+
+// tokenCommand returns the command to run from args, with token in the CF_ACCESS_TOKEN environment variable
+func tokenCommand(token string, args []string) *exec.Cmd {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), accessTokenEnv+"="+token)
+	return cmd
+}
+
+// curlShortOptionsWithValue are the short options of curl that take a value, e.g. -x in -x http://proxy:3128
+const curlShortOptionsWithValue = "AbcCdDeEFHKmoPQrtTuUwxXyYz"
+
+// curlLongOptionsWithValue are the long options of curl that take a value
+var curlLongOptionsWithValue = makeSet(strings.Fields(`
+	--abstract-unix-socket --cacert --capath --cert --cert-type --ciphers --config --connect-timeout --connect-to
+	--continue-at --cookie --cookie-jar --crlfile --data --data-ascii --data-binary --data-raw --data-urlencode
+	--delegation --dns-interface --dns-ipv4-addr --dns-ipv6-addr --dns-servers --doh-url --dump-header --egd-file
+	--engine --expect100-timeout --form --form-string --ftp-account --ftp-alternative-to-user --ftp-method
+	--ftp-port --ftp-ssl-ccc-mode --header --hostpubmd5 --interface --keepalive-time --key --key-type --krb
+	--libcurl --limit-rate --local-port --login-options --mail-auth --mail-from --mail-rcpt --max-filesize
+	--max-redirs --max-time --netrc-file --noproxy --oauth2-bearer --output --pass --pinnedpubkey --preproxy
+	--proto --proto-default --proto-redir --proxy --proxy-cacert --proxy-capath --proxy-cert --proxy-cert-type
+	--proxy-ciphers --proxy-crlfile --proxy-header --proxy-key --proxy-key-type --proxy-pass --proxy-pinnedpubkey
+	--proxy-service-name --proxy-tls13-ciphers --proxy-tlsauthtype --proxy-tlspassword --proxy-tlsuser
+	--proxy-user --proxy1.0 --pubkey --quote --random-file --range --referer --request --request-target --resolve
+	--retry --retry-delay --retry-max-time --sasl-authzid --service-name --socks4 --socks4a --socks5
+	--socks5-gssapi-service --socks5-hostname --speed-limit --speed-time --stderr --telnet-option --tftp-blksize
+	--time-cond --tls-max --tls13-ciphers --tlsauthtype --tlspassword --tlsuser --trace --trace-ascii
+	--unix-socket --upload-file --user --user-agent --write-out
+`))
+
+// findAppURL returns the first http or https URL curl is going to request. Values of curl options, such as the
+// proxy in -x http://proxy:3128, aren't requested.
+func findAppURL(curlArgs []string) (*url.URL, error) {
+	for i := 0; i < len(curlArgs); i++ {
+		arg := curlArgs[i]
+		switch {
+		case arg == "--url":
+			// The value is the URL to request
+			i++
+			if i == len(curlArgs) {
+				continue
+			}
+			arg = curlArgs[i]
+		case curlLongOptionsWithValue[arg]:
+			i++
+			continue
+		case strings.HasPrefix(arg, "--"):
+			continue
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Short options can be combined, the first one that takes a value takes the rest of the argument,
+			// or the next argument
+			for j := 1; j < len(arg); j++ {
+				if strings.IndexByte(curlShortOptionsWithValue, arg[j]) >= 0 {
+					if j == len(arg)-1 {
+						i++
+					}
+					break
+				}
+			}
+			continue
+		}
+		if !strings.HasPrefix(arg, "http://") && !strings.HasPrefix(arg, "https://") {
+			continue
+		}
+		appURL, err := url.Parse(arg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s is not a valid URL", arg)
+		}
+		return appURL, nil
+	}
+	return nil, fmt.Errorf("access curl: missing http or https URL of the request")
+}
+
+func makeSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// runWrapped runs cmd attached to the standard streams of cloudflared
+func runWrapped(cmd *exec.Cmd) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "%s failed", cmd.Args[0])
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindAppURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		appURL string
+	}{
+		{name: "only_url", args: []string{"https://app.example.com/path"}, appURL: "https://app.example.com/path"},
+		{name: "http", args: []string{"-v", "http://app.example.com"}, appURL: "http://app.example.com"},
+		{name: "proxy", args: []string{"-x", "http://proxy:3128", "https://app.example.com"}, appURL: "https://app.example.com"},
+		{name: "long_proxy", args: []string{"--proxy", "http://proxy:3128", "https://app.example.com"}, appURL: "https://app.example.com"},
+		{name: "attached_proxy", args: []string{"-xhttp://proxy:3128", "https://app.example.com"}, appURL: "https://app.example.com"},
+		{name: "combined_options", args: []string{"-sSe", "http://referer.example.com", "https://app.example.com"}, appURL: "https://app.example.com"},
+		{name: "referer", args: []string{"-e", "http://referer.example.com", "https://app.example.com"}, appURL: "https://app.example.com"},
+		{name: "header_and_data", args: []string{"-H", "Origin: x", "-d", "https://not.the.app", "https://app.example.com"}, appURL: "https://app.example.com"},
+		{name: "url_option", args: []string{"-s", "--url", "https://app.example.com"}, appURL: "https://app.example.com"},
+		{name: "url_before_options", args: []string{"https://app.example.com", "-x", "http://proxy:3128"}, appURL: "https://app.example.com"},
+		{name: "only_proxy", args: []string{"-x", "http://proxy:3128", "app.example.com"}},
+		{name: "missing_url_option_value", args: []string{"--url"}},
+		{name: "no_args"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			appURL, err := findAppURL(test.args)
+			if test.appURL == "" {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.appURL, appURL.String())
+			}
+		})
+	}
+}
+
+func TestCurlCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "url",
+			args: []string{"https://app.example.com"},
+			want: []string{"curl", "-H", "cf-access-token: token", "https://app.example.com"},
+		},
+		{
+			name: "options",
+			args: []string{"-x", "http://proxy:3128", "-H", "Accept: text/plain", "https://app.example.com"},
+			want: []string{"curl", "-H", "cf-access-token: token", "-x", "http://proxy:3128", "-H", "Accept: text/plain", "https://app.example.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, curlCommand("token", test.args).Args)
+		})
+	}
+}
+
+func TestTokenCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "command", args: []string{"env"}},
+		{name: "command_with_args", args: []string{"git", "clone", "https://git.example.com/repo"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := tokenCommand("token", test.args)
+			assert.Equal(t, test.args, cmd.Args)
+			assert.Contains(t, cmd.Env, "CF_ACCESS_TOKEN=token")
+			// The rest of the environment is passed through
+			assert.True(t, len(cmd.Env) > 1)
+		})
+	}
+}
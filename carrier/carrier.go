@@ -0,0 +1,123 @@
+// Package carrier relays local TCP connections, or stdin and stdout, to TCP origins behind a tunnel hostname. The
+// bytes are carried in the messages of a websocket to the hostname.
+package carrier
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cloudflared/transfer"
+	"github.com/cloudflare/cloudflared/websocket"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// AccessTokenHeader is the header Cloudflare Access reads the token of the user from
+const AccessTokenHeader = "cf-access-token"
+
+// StartOptions is where and how to connect to the tunnel hostname
+type StartOptions struct {
+	// OriginURL is the URL of the tunnel hostname
+	OriginURL *url.URL
+	// Headers are added to the websocket handshake
+	Headers http.Header
+}
+
+// ParseOriginURL parses the tunnel hostname to connect to. A bare hostname is assumed to use https.
+func ParseOriginURL(hostname string) (*url.URL, error) {
+	if !strings.Contains(hostname, "://") {
+		hostname = "https://" + hostname
+	}
+	originURL, err := url.Parse(hostname)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s is not a valid hostname", hostname)
+	}
+	if originURL.Host == "" {
+		return nil, fmt.Errorf("%s doesn't have a hostname", hostname)
+	}
+	return originURL, nil
+}
+
+// StdinStdout relays stdin and stdout to the tunnel hostname, e.g. to be the ProxyCommand of ssh
+func StdinStdout(options *StartOptions) error {
+	return serveStream(stdinStdout{Reader: os.Stdin, Writer: os.Stdout}, options)
+}
+
+// StartServer relays the connections accepted by listener to the tunnel hostname, until shutdownC is closed
+func StartServer(logger *logrus.Logger, listener net.Listener, shutdownC <-chan struct{}, options *StartOptions) error {
+	go func() {
+		<-shutdownC
+		listener.Close()
+	}()
+	logger.Infof("Relaying connections on %s to %s", listener.Addr(), options.OriginURL.Host)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-shutdownC:
+				return nil
+			default:
+				return errors.Wrap(err, "cannot accept connection")
+			}
+		}
+		go func() {
+			defer conn.Close()
+			if err := serveStream(conn, options); err != nil {
+				logger.WithError(err).WithField("remoteAddr", conn.RemoteAddr()).Error("Cannot relay connection")
+			}
+		}()
+	}
+}
+
+// serveStream relays stream to a websocket to the tunnel hostname, until either of them is closed
+func serveStream(stream io.ReadWriter, options *StartOptions) error {
+	req, err := BuildAccessRequest(options)
+	if err != nil {
+		return err
+	}
+	wsConn, resp, err := websocket.Dial(req, nil)
+	if err != nil {
+		if resp != nil {
+			return errors.Wrapf(err, "%s responded with status %s", options.OriginURL.Host, resp.Status)
+		}
+		return errors.Wrapf(err, "cannot connect to %s", options.OriginURL.Host)
+	}
+	defer wsConn.Close()
+	websocket.Stream(wsConn, stream)
+	return nil
+}
+
+// BuildAccessRequest builds the websocket handshake request to the tunnel hostname. The Access token cached for the
+// hostname is attached if there is one and it hasn't expired, Access would reject it anyway.
+func BuildAccessRequest(options *StartOptions) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, options.OriginURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range options.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	token, err := transfer.GetTokenIfExists(options.OriginURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read cached Access token")
+	}
+	if token != "" && !transfer.TokenExpired(token, time.Now()) {
+		req.Header.Set(AccessTokenHeader, token)
+	}
+	return req, nil
+}
+
+// stdinStdout reads from stdin and writes to stdout
+type stdinStdout struct {
+	io.Reader
+	io.Writer
+}
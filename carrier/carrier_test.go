@@ -0,0 +1,126 @@
+package carrier
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// newEchoTunnelHostname stands in for a tunnel hostname with a TCP origin that echoes everything. The headers of
+// the websocket handshake are sent to headersC.
+func newEchoTunnelHostname(t *testing.T, headersC chan<- http.Header) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headersC <- r.Header
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, message); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestStartServer(t *testing.T) {
+	headersC := make(chan http.Header, 1)
+	server := newEchoTunnelHostname(t, headersC)
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	originURL, err := ParseOriginURL(server.URL)
+	assert.NoError(t, err)
+	shutdownC := make(chan struct{})
+	serverErrC := make(chan error, 1)
+	go func() {
+		serverErrC <- StartServer(logrus.New(), listener, shutdownC, &StartOptions{
+			OriginURL: originURL,
+			Headers:   http.Header{"X-Test": []string{"carrier"}},
+		})
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Larger than the buffer websocket.Stream reads messages into
+	message := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	go conn.Write(message)
+	echoed := make([]byte, len(message))
+	_, err = io.ReadFull(conn, echoed)
+	assert.NoError(t, err)
+	assert.Equal(t, message, echoed)
+	headers := <-headersC
+	assert.Equal(t, "carrier", headers.Get("X-Test"))
+
+	close(shutdownC)
+	assert.NoError(t, <-serverErrC)
+}
+
+func TestBuildAccessRequestAttachesCachedToken(t *testing.T) {
+	home, err := ioutil.TempDir("", "carrier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	originURL, err := ParseOriginURL("ssh.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ssh.example.com", originURL.String())
+
+	req, err := BuildAccessRequest(&StartOptions{OriginURL: originURL})
+	assert.NoError(t, err)
+	assert.Empty(t, req.Header.Get(AccessTokenHeader))
+
+	assert.NoError(t, os.Mkdir(filepath.Join(home, ".cloudflared"), 0700))
+	tokenPath := filepath.Join(home, ".cloudflared", "ssh.example.com-token")
+	token := testToken(time.Now().Add(time.Hour))
+	assert.NoError(t, ioutil.WriteFile(tokenPath, []byte(token+"\n"), 0600))
+	req, err = BuildAccessRequest(&StartOptions{OriginURL: originURL})
+	assert.NoError(t, err)
+	assert.Equal(t, token, req.Header.Get(AccessTokenHeader))
+	assert.Equal(t, "ssh.example.com", req.Host)
+
+	// Access would reject an expired token
+	assert.NoError(t, ioutil.WriteFile(tokenPath, []byte(testToken(time.Now().Add(-time.Hour))), 0600))
+	req, err = BuildAccessRequest(&StartOptions{OriginURL: originURL})
+	assert.NoError(t, err)
+	assert.Empty(t, req.Header.Get(AccessTokenHeader))
+}
+
+// testToken returns an unsigned JWT that expires at expiry
+func testToken(expiry time.Time) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	header := encode([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload := encode([]byte(fmt.Sprintf(`{"aud":"app","exp":%d}`, expiry.Unix())))
+	return header + "." + payload + "." + encode([]byte("signature"))
+}
@@ -0,0 +1,238 @@
+// Package config loads cloudflared configuration files into a pogs.ClientConfig.
+//
+// A configuration file is YAML (or JSON, which is a subset of YAML) whose keys mirror the JSON representation
+// of pogs.ClientConfig. Durations can be written either as nanoseconds or as strings like "30s".
+//
+//	supervisor_config:
+//	  grace_period: 30s
+//	edge_connection_config:
+//	  num_ha_connections: 4
+//	  heartbeat_interval: 5s
+//	  timeout: 15s
+//	  max_failed_heartbeats: 5
+//	  user_credential_path: ~/.cloudflared/cert.pem
+//	reverse_proxy_configs:
+//	  - tunnel_hostname: app.example.com
+//	    origin_config:
+//	      Http:
+//	        url_string: http://localhost:8080
+//	    retries: 5
+//	    connection_timeout: 30s
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cloudflared/transfer"
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of the edge_connection_config fields a configuration file leaves out. They're also the defaults of the
+// corresponding command line options.
+const (
+	DefaultNumHAConnections    = 4
+	DefaultHeartbeatInterval   = 5 * time.Second
+	DefaultMaxFailedHeartbeats = 5
+	DefaultEdgeTimeout         = 15 * time.Second
+)
+
+// ParseError reports where in a configuration file a value could not be decoded
+type ParseError struct {
+	Line  int
+	Field string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s: %v", e.Line, e.Field, e.Err)
+}
+
+// fieldDecoder decodes the value of a single key in a YAML mapping
+type fieldDecoder func(value *yaml.Node, field string) error
+
+// Load parses the configuration file at path. If path is empty, the first file found in the default
+// configuration directories is used. It returns the path that was loaded, which is empty if there is no
+// configuration file to load.
+func Load(path string) (*pogs.ClientConfig, string, error) {
+	if path == "" {
+		path = transfer.FindDefaultConfigPath()
+		if path == "" {
+			return nil, "", nil
+		}
+	}
+	expandedPath, err := homedir.Expand(path)
+	if err != nil {
+		return nil, path, errors.Wrapf(err, "cannot expand %s", path)
+	}
+	data, err := ioutil.ReadFile(expandedPath)
+	if err != nil {
+		return nil, expandedPath, errors.Wrap(err, "cannot read configuration file")
+	}
+	clientConfig, err := Parse(data)
+	if err != nil {
+		return nil, expandedPath, errors.Wrapf(err, "cannot parse %s", expandedPath)
+	}
+	return clientConfig, expandedPath, nil
+}
+
+// Parse decodes a YAML or JSON configuration into a ClientConfig. Sections that are absent from the
+// configuration are left nil. Fields missing from edge_connection_config get their default values.
+func Parse(data []byte) (*pogs.ClientConfig, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	clientConfig := &pogs.ClientConfig{}
+	// An empty document has no content
+	if len(root.Content) == 0 {
+		return clientConfig, nil
+	}
+	err := decodeMapping(root.Content[0], "", clientConfig, map[string]fieldDecoder{
+		"supervisor_config": func(value *yaml.Node, field string) error {
+			clientConfig.SupervisorConfig = &pogs.SupervisorConfig{}
+			return decodeMapping(value, field, clientConfig.SupervisorConfig, nil)
+		},
+		"edge_connection_config": func(value *yaml.Node, field string) error {
+			clientConfig.EdgeConnectionConfig = &pogs.EdgeConnectionConfig{
+				NumHAConnections:    DefaultNumHAConnections,
+				HeartbeatInterval:   DefaultHeartbeatInterval,
+				Timeout:             DefaultEdgeTimeout,
+				MaxFailedHeartbeats: DefaultMaxFailedHeartbeats,
+			}
+			return decodeMapping(value, field, clientConfig.EdgeConnectionConfig, nil)
+		},
+		"doh_proxy_configs": func(value *yaml.Node, field string) error {
+			return decodeSequence(value, field, func(item *yaml.Node, itemField string) error {
+				dohProxyConfig := &pogs.DoHProxyConfig{}
+				clientConfig.DoHProxyConfigs = append(clientConfig.DoHProxyConfigs, dohProxyConfig)
+				return decodeMapping(item, itemField, dohProxyConfig, nil)
+			})
+		},
+		"reverse_proxy_configs": func(value *yaml.Node, field string) error {
+			return decodeSequence(value, field, func(item *yaml.Node, itemField string) error {
+				reverseProxyConfig, err := decodeReverseProxyConfig(item, itemField)
+				if err != nil {
+					return err
+				}
+				clientConfig.ReverseProxyConfigs = append(clientConfig.ReverseProxyConfigs, reverseProxyConfig)
+				return nil
+			})
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clientConfig, nil
+}
+
+func decodeReverseProxyConfig(node *yaml.Node, field string) (*pogs.ReverseProxyConfig, error) {
+	reverseProxyConfig := &pogs.ReverseProxyConfig{}
+	err := decodeMapping(node, field, reverseProxyConfig, map[string]fieldDecoder{
+		"origin_config": func(value *yaml.Node, field string) error {
+			var originConfig interface{}
+			if err := value.Decode(&originConfig); err != nil {
+				return &ParseError{Line: value.Line, Field: field, Err: err}
+			}
+			originJSON, err := json.Marshal(originConfig)
+			if err != nil {
+				return &ParseError{Line: value.Line, Field: field, Err: err}
+			}
+			// Reuse the type-tagged decoding that is used for configs sent by the edge
+			reverseProxyConfig.OriginConfigJSONHandler = &pogs.OriginConfigJSONHandler{}
+			if err := reverseProxyConfig.OriginConfigJSONHandler.UnmarshalJSON(originJSON); err != nil {
+				return &ParseError{Line: value.Line, Field: field, Err: err}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !reverseProxyConfig.TunnelHostname.IsSet() {
+		return nil, &ParseError{Line: node.Line, Field: field, Err: fmt.Errorf("tunnel_hostname is required")}
+	}
+	if reverseProxyConfig.OriginConfigJSONHandler == nil {
+		return nil, &ParseError{Line: node.Line, Field: field, Err: fmt.Errorf("origin_config is required")}
+	}
+	return reverseProxyConfig, nil
+}
+
+// decodeMapping decodes each key of a YAML mapping into the field of out with the same json tag, so errors
+// can be attributed to the line of the key. Keys in custom are decoded by their fieldDecoder instead.
+func decodeMapping(node *yaml.Node, field string, out interface{}, custom map[string]fieldDecoder) error {
+	if node.Kind != yaml.MappingNode {
+		return &ParseError{Line: node.Line, Field: displayField(field), Err: fmt.Errorf("expected a mapping")}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		keyField := key.Value
+		if field != "" {
+			keyField = field + "." + key.Value
+		}
+		if decode, ok := custom[key.Value]; ok {
+			if err := decode(value, keyField); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := decodeScalarField(key, value, out); err != nil {
+			return &ParseError{Line: key.Line, Field: keyField, Err: err}
+		}
+	}
+	return nil
+}
+
+// decodeScalarField decodes a single key value pair into the struct out
+func decodeScalarField(key, value *yaml.Node, out interface{}) error {
+	var v interface{}
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:  mapstructure.StringToTimeDurationHookFunc(),
+		ErrorUnused: true,
+		Result:      out,
+		TagName:     "json",
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(map[string]interface{}{key.Value: v}); err != nil {
+		if decodeErr, ok := err.(*mapstructure.Error); ok {
+			if strings.Contains(err.Error(), "has invalid keys") {
+				return fmt.Errorf("unknown field")
+			}
+			return fmt.Errorf("%s", strings.Join(decodeErr.Errors, "; "))
+		}
+		return err
+	}
+	return nil
+}
+
+func decodeSequence(node *yaml.Node, field string, decodeItem fieldDecoder) error {
+	if node.Kind != yaml.SequenceNode {
+		return &ParseError{Line: node.Line, Field: field, Err: fmt.Errorf("expected a list")}
+	}
+	for i, item := range node.Content {
+		if err := decodeItem(item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func displayField(field string) string {
+	if field == "" {
+		return "(root)"
+	}
+	return field
+}
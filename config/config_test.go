@@ -0,0 +1,200 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseYAML(t *testing.T) {
+	configYAML := `
+version: 3
+supervisor_config:
+  auto_update_frequency: 24h
+  metrics_update_frequency: 5s
+  grace_period: 30000000000
+edge_connection_config:
+  num_ha_connections: 4
+  heartbeat_interval: 5s
+  timeout: 15s
+  max_failed_heartbeats: 5
+  user_credential_path: ~/.cloudflared/cert.pem
+doh_proxy_configs:
+  - listen_host: localhost
+    listen_port: 53
+    upstreams:
+      - https://1.1.1.1/dns-query
+      - https://1.0.0.1/dns-query
+reverse_proxy_configs:
+  - tunnel_hostname: app.example.com
+    origin_config:
+      Http:
+        url_string: http://localhost:8080
+        tcp_keep_alive: 30s
+        chunked_encoding: true
+    retries: 5
+    connection_timeout: 30s
+  - tunnel_hostname: hello.example.com
+    origin_config:
+      HelloWorld: {}
+`
+	clientConfig, err := Parse([]byte(configYAML))
+	assert.NoError(t, err)
+
+	assert.Equal(t, pogs.Version(3), clientConfig.Version)
+	assert.Equal(t, &pogs.SupervisorConfig{
+		AutoUpdateFrequency:    24 * time.Hour,
+		MetricsUpdateFrequency: 5 * time.Second,
+		GracePeriod:            30 * time.Second,
+	}, clientConfig.SupervisorConfig)
+	assert.Equal(t, &pogs.EdgeConnectionConfig{
+		NumHAConnections:    4,
+		HeartbeatInterval:   5 * time.Second,
+		Timeout:             15 * time.Second,
+		MaxFailedHeartbeats: 5,
+		UserCredentialPath:  "~/.cloudflared/cert.pem",
+	}, clientConfig.EdgeConnectionConfig)
+	assert.Equal(t, []*pogs.DoHProxyConfig{
+		{
+			ListenHost: "localhost",
+			ListenPort: 53,
+			Upstreams:  []string{"https://1.1.1.1/dns-query", "https://1.0.0.1/dns-query"},
+		},
+	}, clientConfig.DoHProxyConfigs)
+	assert.Equal(t, []*pogs.ReverseProxyConfig{
+		{
+			TunnelHostname: "app.example.com",
+			OriginConfigJSONHandler: &pogs.OriginConfigJSONHandler{
+				OriginConfig: &pogs.HTTPOriginConfig{
+					URLString:       "http://localhost:8080",
+					TCPKeepAlive:    30 * time.Second,
+					ChunkedEncoding: true,
+				},
+			},
+			Retries:           5,
+			ConnectionTimeout: 30 * time.Second,
+		},
+		{
+			TunnelHostname: "hello.example.com",
+			OriginConfigJSONHandler: &pogs.OriginConfigJSONHandler{
+				OriginConfig: &pogs.HelloWorldOriginConfig{},
+			},
+		},
+	}, clientConfig.ReverseProxyConfigs)
+}
+
+func TestParseJSON(t *testing.T) {
+	configJSON := `{
+		"edge_connection_config": {"num_ha_connections": 2, "timeout": 15000000000},
+		"reverse_proxy_configs": [{
+			"tunnel_hostname": "app.example.com",
+			"origin_config": {"WebSocket": {"url_string": "ws://localhost:8080"}}
+		}]
+	}`
+	clientConfig, err := Parse([]byte(configJSON))
+	assert.NoError(t, err)
+	assert.Nil(t, clientConfig.SupervisorConfig)
+	assert.Equal(t, &pogs.EdgeConnectionConfig{
+		NumHAConnections:    2,
+		HeartbeatInterval:   DefaultHeartbeatInterval,
+		Timeout:             15 * time.Second,
+		MaxFailedHeartbeats: DefaultMaxFailedHeartbeats,
+	}, clientConfig.EdgeConnectionConfig)
+	assert.Len(t, clientConfig.ReverseProxyConfigs, 1)
+	assert.Equal(t, &pogs.WebSocketOriginConfig{URLString: "ws://localhost:8080"},
+		clientConfig.ReverseProxyConfigs[0].OriginConfigJSONHandler.OriginConfig)
+}
+
+func TestParsePartialEdgeConnectionConfig(t *testing.T) {
+	configYAML := `
+edge_connection_config:
+  num_ha_connections: 2
+`
+	clientConfig, err := Parse([]byte(configYAML))
+	assert.NoError(t, err)
+	// The fields that are left out don't end up zero, e.g. a zero timeout would fail every connection to the edge
+	assert.Equal(t, &pogs.EdgeConnectionConfig{
+		NumHAConnections:    2,
+		HeartbeatInterval:   DefaultHeartbeatInterval,
+		Timeout:             DefaultEdgeTimeout,
+		MaxFailedHeartbeats: DefaultMaxFailedHeartbeats,
+	}, clientConfig.EdgeConnectionConfig)
+	assert.Empty(t, Validate(clientConfig))
+}
+
+func TestParseEmpty(t *testing.T) {
+	clientConfig, err := Parse([]byte(""))
+	assert.NoError(t, err)
+	assert.Equal(t, &pogs.ClientConfig{}, clientConfig)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        string
+		expectedLine  int
+		expectedField string
+	}{
+		{
+			name:          "unknown_section",
+			config:        "edge_connection_config:\n  timeout: 5s\ntunnel_config: {}\n",
+			expectedLine:  3,
+			expectedField: "tunnel_config",
+		},
+		{
+			name:          "wrong_type",
+			config:        "edge_connection_config:\n  timeout: 5s\n  num_ha_connections: many\n",
+			expectedLine:  3,
+			expectedField: "edge_connection_config.num_ha_connections",
+		},
+		{
+			name:          "invalid_duration",
+			config:        "supervisor_config:\n  grace_period: soon\n",
+			expectedLine:  2,
+			expectedField: "supervisor_config.grace_period",
+		},
+		{
+			name:          "section_not_a_mapping",
+			config:        "supervisor_config: [1, 2]\n",
+			expectedLine:  1,
+			expectedField: "supervisor_config",
+		},
+		{
+			name:          "unknown_origin_type",
+			config:        "reverse_proxy_configs:\n  - tunnel_hostname: a.example.com\n    origin_config:\n      Gopher: {}\n",
+			expectedLine:  4,
+			expectedField: "reverse_proxy_configs[0].origin_config",
+		},
+		{
+			name:          "missing_origin",
+			config:        "reverse_proxy_configs:\n  - tunnel_hostname: a.example.com\n  - tunnel_hostname: b.example.com\n",
+			expectedLine:  2,
+			expectedField: "reverse_proxy_configs[0]",
+		},
+		{
+			name:          "missing_hostname",
+			config:        "reverse_proxy_configs:\n  - origin_config:\n      HelloWorld: {}\n",
+			expectedLine:  2,
+			expectedField: "reverse_proxy_configs[0]",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.config))
+			if assert.IsType(t, &ParseError{}, err) {
+				parseErr := err.(*ParseError)
+				assert.Equal(t, test.expectedLine, parseErr.Line)
+				assert.Equal(t, test.expectedField, parseErr.Field)
+			}
+		})
+	}
+}
+
+func TestParseInvalidYAML(t *testing.T) {
+	_, err := Parse([]byte("edge_connection_config:\n  timeout: [5s\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line")
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// Validate checks a ClientConfig without connecting to the edge, and returns every config that cloudflared
+// would fail to apply. Origin services are created to validate them, and shutdown immediately after.
+func Validate(clientConfig *pogs.ClientConfig) (failedConfigs []*pogs.FailedConfig) {
+	fail := func(config pogs.FallibleConfig, err error) {
+		failedConfigs = append(failedConfigs, &pogs.FailedConfig{
+			Config: config,
+			Reason: config.FailReason(err),
+		})
+	}
+
+	if supervisorConfig := clientConfig.SupervisorConfig; supervisorConfig != nil {
+		if err := validateSupervisorConfig(supervisorConfig); err != nil {
+			fail(supervisorConfig, err)
+		}
+	}
+	if edgeConnectionConfig := clientConfig.EdgeConnectionConfig; edgeConnectionConfig != nil {
+		if err := validateEdgeConnectionConfig(edgeConnectionConfig); err != nil {
+			fail(edgeConnectionConfig, err)
+		}
+	}
+	for _, dohProxyConfig := range clientConfig.DoHProxyConfigs {
+		if err := validateDoHProxyConfig(dohProxyConfig); err != nil {
+			fail(dohProxyConfig, err)
+		}
+	}
+	seenHostnames := make(map[string]bool)
+	for _, reverseProxyConfig := range clientConfig.ReverseProxyConfigs {
+		hostname := reverseProxyConfig.TunnelHostname.String()
+		if seenHostnames[hostname] {
+			fail(reverseProxyConfig, fmt.Errorf("tunnel_hostname %s is configured more than once", hostname))
+			continue
+		}
+		seenHostnames[hostname] = true
+		if err := validateReverseProxyConfig(reverseProxyConfig); err != nil {
+			fail(reverseProxyConfig, err)
+		}
+	}
+	return failedConfigs
+}
+
+func validateSupervisorConfig(sc *pogs.SupervisorConfig) error {
+	return validateDurations([]namedDuration{
+		{"auto_update_frequency", sc.AutoUpdateFrequency},
+		{"metrics_update_frequency", sc.MetricsUpdateFrequency},
+		{"grace_period", sc.GracePeriod},
+	})
+}
+
+func validateEdgeConnectionConfig(ecc *pogs.EdgeConnectionConfig) error {
+	if ecc.NumHAConnections == 0 {
+		return fmt.Errorf("num_ha_connections must be at least 1")
+	}
+	// Connections to the edge would time out immediately
+	for _, d := range []namedDuration{
+		{"heartbeat_interval", ecc.HeartbeatInterval},
+		{"timeout", ecc.Timeout},
+	} {
+		if d.duration <= 0 {
+			return fmt.Errorf("%s must be positive, got %v", d.field, d.duration)
+		}
+	}
+	if ecc.UserCredentialPath != "" {
+		path, err := homedir.Expand(ecc.UserCredentialPath)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("user_credential_path: %v", err)
+		}
+	}
+	return nil
+}
+
+func validateDoHProxyConfig(dpc *pogs.DoHProxyConfig) error {
+	if len(dpc.Upstreams) == 0 {
+		return fmt.Errorf("at least one upstream is required")
+	}
+	for _, upstream := range dpc.Upstreams {
+		upstreamURL, err := url.Parse(upstream)
+		if err != nil {
+			return fmt.Errorf("upstream %s is not a valid URL: %v", upstream, err)
+		}
+		if upstreamURL.Scheme != "https" || upstreamURL.Host == "" {
+			return fmt.Errorf("upstream %s must be an https URL", upstream)
+		}
+	}
+	return nil
+}
+
+func validateReverseProxyConfig(rpc *pogs.ReverseProxyConfig) error {
+	if err := validateDurations([]namedDuration{
+		{"connection_timeout", rpc.ConnectionTimeout},
+	}); err != nil {
+		return err
+	}
+	if rpc.OriginConfigJSONHandler == nil || rpc.OriginConfigJSONHandler.OriginConfig == nil {
+		return fmt.Errorf("origin_config is required")
+	}
+	switch originConfig := rpc.OriginConfigJSONHandler.OriginConfig.(type) {
+	case *pogs.HTTPOriginConfig:
+		if err := validateOriginURL(originConfig.URLString, "http", "https", "unix"); err != nil {
+			return err
+		}
+		if err := validateDurations([]namedDuration{
+			{"tcp_keep_alive", originConfig.TCPKeepAlive},
+			{"tls_handshake_timeout", originConfig.TLSHandshakeTimeout},
+			{"idle_connection_timeout", originConfig.IdleConnectionTimeout},
+			{"proxy_connection_timeout", originConfig.ProxyConnectionTimeout},
+			{"expect_continue_timeout", originConfig.ExpectContinueTimeout},
+		}); err != nil {
+			return err
+		}
+	case *pogs.WebSocketOriginConfig:
+		if err := validateOriginURL(originConfig.URLString, "ws", "wss", "http", "https"); err != nil {
+			return err
+		}
+	}
+	// Service() loads CA pools and parses URLs the same way StreamHandler.UpdateConfig does
+	originService, err := rpc.OriginConfigJSONHandler.OriginConfig.Service()
+	if err != nil {
+		return err
+	}
+	originService.Shutdown()
+	return nil
+}
+
+func validateOriginURL(urlString string, schemes ...string) error {
+	originURL, err := url.Parse(urlString)
+	if err != nil {
+		return fmt.Errorf("url_string %s is not a valid URL: %v", urlString, err)
+	}
+	for _, scheme := range schemes {
+		if originURL.Scheme == scheme {
+			if originURL.Host == "" && scheme != "unix" {
+				return fmt.Errorf("url_string %s has no host", urlString)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("url_string %s should have one of the schemes %v", urlString, schemes)
+}
+
+type namedDuration struct {
+	field    string
+	duration time.Duration
+}
+
+func validateDurations(durations []namedDuration) error {
+	for _, d := range durations {
+		if d.duration < 0 {
+			return fmt.Errorf("%s cannot be negative, got %v", d.field, d.duration)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateValidConfig(t *testing.T) {
+	clientConfig, err := Parse([]byte(`
+supervisor_config:
+  grace_period: 30s
+doh_proxy_configs:
+  - listen_host: localhost
+    listen_port: 53
+    upstreams: [https://1.1.1.1/dns-query]
+reverse_proxy_configs:
+  - tunnel_hostname: app.example.com
+    origin_config:
+      Http:
+        url_string: http://localhost:8080
+  - tunnel_hostname: ws.example.com
+    origin_config:
+      WebSocket:
+        url_string: wss://localhost:8443
+`))
+	assert.NoError(t, err)
+	assert.Empty(t, Validate(clientConfig))
+}
+
+func TestValidateFailedConfigs(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         string
+		expectedReason string
+	}{
+		{
+			name:           "negative_duration",
+			config:         "supervisor_config:\n  grace_period: -1s\n",
+			expectedReason: "grace_period cannot be negative",
+		},
+		{
+			name:           "no_ha_connections",
+			config:         "edge_connection_config:\n  num_ha_connections: 0\n",
+			expectedReason: "num_ha_connections must be at least 1",
+		},
+		{
+			name:           "zero_timeout",
+			config:         "edge_connection_config:\n  timeout: 0s\n",
+			expectedReason: "timeout must be positive",
+		},
+		{
+			name:           "zero_heartbeat_interval",
+			config:         "edge_connection_config:\n  heartbeat_interval: 0\n",
+			expectedReason: "heartbeat_interval must be positive",
+		},
+		{
+			name:           "missing_credential",
+			config:         "edge_connection_config:\n  num_ha_connections: 1\n  user_credential_path: /nonexistent/cert.pem\n",
+			expectedReason: "user_credential_path",
+		},
+		{
+			name:           "plaintext_doh_upstream",
+			config:         "doh_proxy_configs:\n  - upstreams: [http://1.1.1.1/dns-query]\n",
+			expectedReason: "must be an https URL",
+		},
+		{
+			name:           "invalid_origin_scheme",
+			config:         "reverse_proxy_configs:\n  - tunnel_hostname: a.example.com\n    origin_config:\n      Http:\n        url_string: ftp://localhost\n",
+			expectedReason: "should have one of the schemes",
+		},
+		{
+			name:           "missing_ca_pool",
+			config:         "reverse_proxy_configs:\n  - tunnel_hostname: a.example.com\n    origin_config:\n      Http:\n        url_string: https://localhost\n        origin_ca_pool: /nonexistent/ca.pem\n",
+			expectedReason: "/nonexistent/ca.pem",
+		},
+		{
+			name:           "duplicate_hostname",
+			config:         "reverse_proxy_configs:\n  - tunnel_hostname: a.example.com\n    origin_config:\n      Http:\n        url_string: http://localhost:8080\n  - tunnel_hostname: a.example.com\n    origin_config:\n      Http:\n        url_string: http://localhost:8081\n",
+			expectedReason: "configured more than once",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientConfig, err := Parse([]byte(test.config))
+			assert.NoError(t, err)
+			failedConfigs := Validate(clientConfig)
+			if assert.Len(t, failedConfigs, 1) {
+				assert.True(t, strings.HasPrefix(failedConfigs[0].Reason, "Cannot apply"))
+				assert.Contains(t, failedConfigs[0].Reason, test.expectedReason)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryFailure(t *testing.T) {
+	clientConfig := &pogs.ClientConfig{
+		SupervisorConfig:     &pogs.SupervisorConfig{MetricsUpdateFrequency: -1},
+		EdgeConnectionConfig: &pogs.EdgeConnectionConfig{},
+		DoHProxyConfigs:      []*pogs.DoHProxyConfig{{}},
+	}
+	failedConfigs := Validate(clientConfig)
+	assert.Len(t, failedConfigs, 3)
+	assert.Equal(t, clientConfig.SupervisorConfig, failedConfigs[0].Config)
+	assert.Equal(t, clientConfig.EdgeConnectionConfig, failedConfigs[1].Config)
+	assert.Equal(t, clientConfig.DoHProxyConfigs[0], failedConfigs[2].Config)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+)
+
+// configValidate checks a configuration file offline, and prints the result in the same format as
+// UseConfigurationResult. It returns an error if any config fails, so it can be used to gate deploys.
+func configValidate(args []string) error {
+	flags := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	configFile := flags.String("config", "", "Path of the config file to validate. Defaults to ~/.cloudflared/config.yml")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: cloudflared config validate [--config PATH | PATH]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("config validate: expected at most one config file, got %d", flags.NArg())
+	}
+	if flags.NArg() == 1 {
+		*configFile = flags.Arg(0)
+	}
+
+	clientConfig, configPath, err := config.Load(*configFile)
+	if err != nil {
+		return err
+	}
+	if clientConfig == nil {
+		return fmt.Errorf("config validate: no config file found, use --config to specify one")
+	}
+
+	failedConfigs := config.Validate(clientConfig)
+	result := &pogs.UseConfigurationResult{
+		Success:       len(failedConfigs) == 0,
+		FailedConfigs: failedConfigs,
+	}
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", output)
+	if !result.Success {
+		return fmt.Errorf("%s: %d config(s) failed validation", configPath, len(failedConfigs))
+	}
+	return nil
+}
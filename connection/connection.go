@@ -3,6 +3,7 @@ package connection
 import (
 	"context"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/cloudflare/cloudflared/h2mux"
@@ -54,7 +55,7 @@ func (c *Connection) Connect(ctx context.Context, parameters *tunnelpogs.Connect
 	openStreamCtx, cancel := context.WithTimeout(ctx, openStreamTimeout)
 	defer cancel()
 
-	rpcConn, err := c.newRPConn(openStreamCtx, logger)
+	rpcConn, err := c.newRPConn(openStreamCtx, "connect", logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot create new RPC connection")
 	}
@@ -65,17 +66,67 @@ func (c *Connection) Connect(ctx context.Context, parameters *tunnelpogs.Connect
 	return tsClient.Connect(ctx, parameters)
 }
 
+// Unregister tells the edge to stop sending new requests on this connection, and to give in-flight requests
+// gracePeriod to finish
+func (c *Connection) Unregister(ctx context.Context, gracePeriod time.Duration, logger *logrus.Entry) error {
+	openStreamCtx, cancel := context.WithTimeout(ctx, openStreamTimeout)
+	defer cancel()
+
+	rpcConn, err := c.newRPConn(openStreamCtx, "unregister", logger)
+	if err != nil {
+		return errors.Wrap(err, "cannot create new RPC connection")
+	}
+	defer rpcConn.Close()
+
+	tsClient := tunnelpogs.TunnelServer_PogsClient{Client: rpcConn.Bootstrap(ctx)}
+	// gracePeriod is encoded in int64 using capnproto
+	return tsClient.UnregisterTunnel(ctx, gracePeriod.Nanoseconds())
+}
+
 func (c *Connection) Shutdown() {
 	c.muxer.Shutdown()
 }
 
-func (c *Connection) newRPConn(ctx context.Context, logger *logrus.Entry) (*rpc.Conn, error) {
+// Drain closes the connection once in-flight requests finish, or aborts them when ctx is done
+func (c *Connection) Drain(ctx context.Context) error {
+	return c.muxer.Drain(ctx)
+}
+
+// StreamHandler handles the streams the edge opens on a connection
+type StreamHandler interface {
+	// ServeStreamAt serves a stream opened by the edge in location, the data center of the connection. location
+	// is empty until the connection is registered.
+	ServeStreamAt(stream *h2mux.MuxedStream, location string) error
+}
+
+// connectionStreamHandler serves the streams of a connection with the location of the edge it's connected to
+type connectionStreamHandler struct {
+	handler      StreamHandler
+	locationLock sync.RWMutex
+	location     string
+}
+
+func (csh *connectionStreamHandler) ServeStream(stream *h2mux.MuxedStream) error {
+	csh.locationLock.RLock()
+	location := csh.location
+	csh.locationLock.RUnlock()
+	return csh.handler.ServeStreamAt(stream, location)
+}
+
+// setLocation records the location returned by Connect
+func (csh *connectionStreamHandler) setLocation(location string) {
+	csh.locationLock.Lock()
+	defer csh.locationLock.Unlock()
+	csh.location = location
+}
+
+func (c *Connection) newRPConn(ctx context.Context, rpcName string, logger *logrus.Entry) (*rpc.Conn, error) {
 	stream, err := c.muxer.OpenRPCStream(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return rpc.NewConn(
-		tunnelrpc.NewTransportLogger(logger.WithField("rpc", "connect"), rpc.StreamTransport(stream)),
-		tunnelrpc.ConnLog(logger.WithField("rpc", "connect")),
+		tunnelrpc.NewTransportLogger(logger.WithField("rpc", rpcName), rpc.StreamTransport(stream)),
+		tunnelrpc.ConnLog(logger.WithField("rpc", rpcName)),
 	), nil
 }
@@ -0,0 +1,163 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newEchoServer returns the address of a server that writes back everything it reads
+func newEchoServer(t *testing.T) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+// newTestProxy accepts one connection, and serves it with handshake followed by relaying bytes to the address
+// handshake returns
+func newTestProxy(t *testing.T, handshake func(conn net.Conn, reader *bufio.Reader) string) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		addr := handshake(conn, reader)
+		if addr == "" {
+			return
+		}
+		upstream, err := net.Dial("tcp", addr)
+		if err != nil {
+			return
+		}
+		defer upstream.Close()
+		go io.Copy(upstream, reader)
+		io.Copy(conn, upstream)
+	}()
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+func assertEcho(t *testing.T, conn net.Conn) {
+	message := []byte("through the proxy")
+	_, err := conn.Write(message)
+	assert.NoError(t, err)
+	response := make([]byte, len(message))
+	_, err = io.ReadFull(conn, response)
+	assert.NoError(t, err)
+	assert.Equal(t, message, response)
+}
+
+func TestHTTPConnectProxy(t *testing.T) {
+	echoAddr, closeEcho := newEchoServer(t)
+	defer closeEcho()
+	proxyAddr, closeProxy := newTestProxy(t, func(conn net.Conn, reader *bufio.Reader) string {
+		req, err := http.ReadRequest(reader)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		assert.Equal(t, http.MethodConnect, req.Method)
+		assert.Equal(t, echoAddr, req.Host)
+		// user:password
+		if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNzd29yZA==" {
+			conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+			return ""
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		return echoAddr
+	})
+	defer closeProxy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dialer, err := NewEdgeDialer("http://user:password@" + proxyAddr)
+	assert.NoError(t, err)
+	conn, err := dialer.DialContext(ctx, "tcp", echoAddr)
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		assertEcho(t, conn)
+	}
+}
+
+func TestHTTPConnectProxyRefused(t *testing.T) {
+	proxyAddr, closeProxy := newTestProxy(t, func(conn net.Conn, reader *bufio.Reader) string {
+		http.ReadRequest(reader)
+		conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+		return ""
+	})
+	defer closeProxy()
+
+	dialer, err := NewEdgeDialer("http://" + proxyAddr)
+	assert.NoError(t, err)
+	_, err = dialer.DialContext(context.Background(), "tcp", "127.0.0.1:7844")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "407")
+	}
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	echoAddr, closeEcho := newEchoServer(t)
+	defer closeEcho()
+	proxyAddr, closeProxy := newTestProxy(t, func(conn net.Conn, reader *bufio.Reader) string {
+		// Method selection, username/password is the only method offered
+		greeting := make([]byte, 3)
+		io.ReadFull(reader, greeting)
+		assert.Equal(t, []byte{socks5Version, 1, socks5AuthPassword}, greeting)
+		conn.Write([]byte{socks5Version, socks5AuthPassword})
+
+		auth := make([]byte, 2+len("user")+1+len("password"))
+		io.ReadFull(reader, auth)
+		assert.Equal(t, append(append([]byte{socks5PasswordVersion, 4}, "user"...), append([]byte{8}, "password"...)...), auth)
+		conn.Write([]byte{socks5PasswordVersion, socks5Succeeded})
+
+		// CONNECT to an IPv4 address
+		req := make([]byte, 10)
+		io.ReadFull(reader, req)
+		assert.Equal(t, []byte{socks5Version, socks5CmdConnect, 0, socks5AddrIPv4}, req[:4])
+		addr := &net.TCPAddr{IP: net.IP(req[4:8]), Port: int(req[8])<<8 | int(req[9])}
+		conn.Write([]byte{socks5Version, socks5Succeeded, 0, socks5AddrIPv4, 127, 0, 0, 1, 0, 0})
+		return addr.String()
+	})
+	defer closeProxy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dialer, err := NewEdgeDialer("socks5://user:password@" + proxyAddr)
+	assert.NoError(t, err)
+	conn, err := dialer.DialContext(ctx, "tcp", echoAddr)
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		assertEcho(t, conn)
+	}
+}
+
+func TestNewEdgeDialerInvalidProxy(t *testing.T) {
+	for _, proxyURL := range []string{"ftp://proxy:21", "http://", "://proxy"} {
+		_, err := NewEdgeDialer(proxyURL)
+		assert.Error(t, err, proxyURL)
+	}
+}
@@ -56,11 +56,14 @@ type EdgeAddrResolver struct {
 	addrs []*net.TCPAddr
 	// index of the next element to use in addrs
 	nextAddrIndex int
-	logger        *logrus.Entry
+	// dialer is used by the DoT fallback
+	dialer EdgeDialer
+	logger *logrus.Entry
 }
 
-func NewEdgeAddrResolver(logger *logrus.Logger) (EdgeServiceDiscoverer, error) {
+func NewEdgeAddrResolver(dialer EdgeDialer, logger *logrus.Logger) (EdgeServiceDiscoverer, error) {
 	r := &EdgeAddrResolver{
+		dialer: dialer,
 		logger: logger.WithField("subsystem", " edgeAddrResolver"),
 	}
 	if err := r.Refresh(); err != nil {
@@ -84,7 +87,7 @@ func (r *EdgeAddrResolver) AvailableAddrs() uint8 {
 }
 
 func (r *EdgeAddrResolver) Refresh() error {
-	newAddrs, err := EdgeDiscovery(r.logger)
+	newAddrs, err := EdgeDiscovery(r.dialer, r.logger)
 	if err != nil {
 		return err
 	}
@@ -95,8 +98,8 @@ func (r *EdgeAddrResolver) Refresh() error {
 	return nil
 }
 
-// HA service discovery lookup
-func EdgeDiscovery(logger *logrus.Entry) ([]*net.TCPAddr, error) {
+// HA service discovery lookup. If the default resolver fails, SRV records are looked up with DoT through dialer.
+func EdgeDiscovery(dialer EdgeDialer, logger *logrus.Entry) ([]*net.TCPAddr, error) {
 	_, addrs, err := net.LookupSRV(srvService, srvProto, srvName)
 	if err != nil {
 		// Try to fall back to DoT from Cloudflare directly.
@@ -109,7 +112,7 @@ func EdgeDiscovery(logger *logrus.Entry) ([]*net.TCPAddr, error) {
 		//     (https://golang.org/pkg/net/#Resolver.LookupSRV)
 		// Does this matter? I don't know. It may someday. Let's use DoT so we don't need to worry about it.
 		// See also: Go feature request for stdlib-supported DoH: https://github.com/golang/go/issues/27552
-		r := fallbackResolver(dotServerName, dotServerAddr)
+		r := fallbackResolver(dialer, dotServerName, dotServerAddr)
 		ctx, cancel := context.WithTimeout(context.Background(), dotTimeout)
 		defer cancel()
 		_, fallbackAddrs, fallbackErr := r.LookupSRV(ctx, srvService, srvProto, srvName)
@@ -176,11 +179,10 @@ func flattenServiceIPs(ipsByService [][]*net.TCPAddr) []*net.TCPAddr {
 }
 
 // Inspiration: https://github.com/artyom/dot/blob/master/dot.go
-func fallbackResolver(serverName, serverAddress string) *net.Resolver {
+func fallbackResolver(dialer EdgeDialer, serverName, serverAddress string) *net.Resolver {
 	return &net.Resolver{
 		PreferGo: true,
 		Dial: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
-			var dialer net.Dialer
 			conn, err := dialer.DialContext(ctx, "tcp", serverAddress)
 			if err != nil {
 				return nil, err
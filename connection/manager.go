@@ -4,10 +4,12 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"math"
 	"net"
 	"sync"
 	"time"
 
+	"github.com/cloudflare/backoff"
 	"github.com/cloudflare/cloudflared/buildinfo"
 	"github.com/cloudflare/cloudflared/h2mux"
 	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
@@ -19,20 +21,32 @@ import (
 const (
 	quickStartLink = "https://developers.cloudflare.com/argo-tunnel/quickstart/"
 	faqLink        = "https://developers.cloudflare.com/argo-tunnel/faq/"
+	// applyConfigTimeout bounds how long applying the ClientConfig returned by Connect can take
+	applyConfigTimeout = 30 * time.Second
+	// edgeRetryInterval and maxEdgeRetryBackoff bound the backoff after failing to connect to an edge address
+	edgeRetryInterval   = time.Second
+	maxEdgeRetryBackoff = 2 * time.Minute
 )
 
 // EdgeManager manages connections with the edge
 type EdgeManager struct {
 	// streamHandler handles stream opened by the edge
-	streamHandler h2mux.MuxedStreamHandler
+	streamHandler StreamHandler
+	// clientService applies the ClientConfig returned by Connect, the same way as a ClientConfig sent by the edge
+	// with UseConfiguration
+	clientService pogs.ClientService
 	// TLSConfig is the TLS configuration to connect with edge
 	tlsConfig *tls.Config
+	// edgeDialer dials TCP connections to the edge, possibly through a proxy
+	edgeDialer EdgeDialer
 	// cloudflaredConfig is the cloudflared configuration that is determined when the process first starts
 	cloudflaredConfig *CloudflaredConfig
 	// serviceDiscoverer returns the next edge addr to connect to
 	serviceDiscoverer EdgeServiceDiscoverer
 	// state is attributes of ConnectionManager that can change during runtime.
 	state *edgeManagerState
+	// addrBackoffs tracks failed attempts to connect to each edge address. It's only used by the Run goroutine.
+	addrBackoffs map[string]*edgeAddrBackoff
 
 	logger *logrus.Entry
 }
@@ -48,23 +62,30 @@ type CloudflaredConfig struct {
 	Tags          []pogs.Tag
 	BuildInfo     *buildinfo.BuildInfo
 	Scope         pogs.Scope
+	// MaxConcurrentStreams is how many streams the edge can have open on each connection. 0 means no limit.
+	MaxConcurrentStreams uint32
 }
 
 func NewEdgeManager(
-	streamHandler h2mux.MuxedStreamHandler,
+	streamHandler StreamHandler,
+	clientService pogs.ClientService,
 	edgeConnMgrConfigurable *EdgeManagerConfigurable,
 	userCredential []byte,
 	tlsConfig *tls.Config,
+	edgeDialer EdgeDialer,
 	serviceDiscoverer EdgeServiceDiscoverer,
 	cloudflaredConfig *CloudflaredConfig,
 	logger *logrus.Logger,
 ) *EdgeManager {
 	return &EdgeManager{
 		streamHandler:     streamHandler,
+		clientService:     clientService,
 		tlsConfig:         tlsConfig,
+		edgeDialer:        edgeDialer,
 		cloudflaredConfig: cloudflaredConfig,
 		serviceDiscoverer: serviceDiscoverer,
 		state:             newEdgeConnectionManagerState(edgeConnMgrConfigurable, userCredential),
+		addrBackoffs:      make(map[string]*edgeAddrBackoff),
 		logger:            logger.WithField("subsystem", "connectionManager"),
 	}
 }
@@ -88,6 +109,9 @@ func (em *EdgeManager) Run(ctx context.Context) error {
 		// in shouldCreateConnection or shouldReduceConnection calculation
 		if em.state.shouldCreateConnection(em.serviceDiscoverer.AvailableAddrs()) {
 			if err := em.newConnection(ctx); err != nil {
+				if _, ok := err.(noRetryError); ok {
+					return err
+				}
 				em.logger.WithError(err).Error("cannot create new connection")
 			}
 		} else if em.state.shouldReduceConnection() {
@@ -103,22 +127,77 @@ func (em *EdgeManager) UpdateConfigurable(newConfigurable *EdgeManagerConfigurab
 	em.state.updateConfigurable(newConfigurable)
 }
 
+// Unregister stops EdgeManager from creating new connections, and tells the edge to stop sending new requests on
+// existing connections. In-flight requests have gracePeriod to finish. It returns when every connection has
+// been drained, or ctx is done.
+func (em *EdgeManager) Unregister(ctx context.Context, gracePeriod time.Duration) {
+	em.state.stopCreatingConnections()
+	var wg sync.WaitGroup
+	for _, conn := range em.state.getConnections() {
+		wg.Add(1)
+		go func(conn *Connection) {
+			defer wg.Done()
+			if err := conn.Unregister(ctx, gracePeriod, em.logger); err != nil {
+				em.logger.WithError(err).Error("Cannot unregister connection")
+			}
+			if err := conn.Drain(ctx); err != nil {
+				em.logger.WithError(err).Warn("In-flight requests didn't finish")
+			}
+		}(conn)
+	}
+	wg.Wait()
+}
+
+// UpdateMetrics records the metrics of each connection
+func (em *EdgeManager) UpdateMetrics() {
+	for _, conn := range em.state.getConnections() {
+		edgeConnMetrics.update(conn.id.String(), conn.muxer.Metrics())
+	}
+}
+
+// newConnection connects to the next edge address, unless it is backing off after failed attempts. It returns a
+// noRetryError if the edge refused the connection and connecting again won't help.
 func (em *EdgeManager) newConnection(ctx context.Context) error {
 	edgeIP := em.serviceDiscoverer.Addr()
+	addrBackoff, ok := em.addrBackoffs[edgeIP.String()]
+	if !ok {
+		addrBackoff = newEdgeAddrBackoff()
+		em.addrBackoffs[edgeIP.String()] = addrBackoff
+	}
+	if !addrBackoff.canRetry(time.Now()) {
+		em.logger.Debugf("Backing off from %s until %v", edgeIP, addrBackoff.retryAt)
+		return nil
+	}
+
+	err := em.connect(ctx, edgeIP, addrBackoff.attempts)
+	if err == nil {
+		delete(em.addrBackoffs, edgeIP.String())
+		return nil
+	}
+	if _, ok := err.(noRetryError); ok {
+		return err
+	}
+	wait := addrBackoff.failed(err, time.Now())
+	return errors.Wrapf(err, "attempt %d to connect to %s failed, retrying in %v", addrBackoff.attempts, edgeIP, wait)
+}
+
+func (em *EdgeManager) connect(ctx context.Context, edgeIP *net.TCPAddr, numPreviousAttempts uint8) error {
 	edgeConn, err := em.dialEdge(ctx, edgeIP)
 	if err != nil {
 		return errors.Wrap(err, "dial edge error")
 	}
 	configurable := em.state.getConfigurable()
+	streamHandler := &connectionStreamHandler{handler: em.streamHandler}
 	// Establish a muxed connection with the edge
 	// Client mux handshake with agent server
 	muxer, err := h2mux.Handshake(edgeConn, edgeConn, h2mux.MuxerConfig{
-		Timeout:           configurable.Timeout,
-		Handler:           em.streamHandler,
-		IsClient:          true,
-		HeartbeatInterval: configurable.HeartbeatInterval,
-		MaxHeartbeats:     configurable.MaxFailedHeartbeats,
-		Logger:            em.logger.WithField("subsystem", "muxer"),
+		Timeout:              configurable.Timeout,
+		Handler:              streamHandler,
+		IsClient:             true,
+		HeartbeatInterval:    configurable.HeartbeatInterval,
+		MaxHeartbeats:        configurable.MaxFailedHeartbeats,
+		Logger:               em.logger.WithField("subsystem", "muxer"),
+		MaxConcurrentStreams: em.cloudflaredConfig.MaxConcurrentStreams,
 	})
 	if err != nil {
 		return errors.Wrap(err, "couldn't perform handshake with edge")
@@ -134,7 +213,7 @@ func (em *EdgeManager) newConnection(ctx context.Context) error {
 	connResult, err := h2muxConn.Connect(ctx, &pogs.ConnectParameters{
 		CloudflaredID:       em.cloudflaredConfig.CloudflaredID,
 		CloudflaredVersion:  em.cloudflaredConfig.BuildInfo.CloudflaredVersion,
-		NumPreviousAttempts: 0,
+		NumPreviousAttempts: numPreviousAttempts,
 		OriginCert:          em.state.getUserCredential(),
 		Scope:               em.cloudflaredConfig.Scope,
 		Tags:                em.cloudflaredConfig.Tags,
@@ -145,17 +224,39 @@ func (em *EdgeManager) newConnection(ctx context.Context) error {
 	}
 
 	if connErr := connResult.Err; connErr != nil {
+		h2muxConn.Shutdown()
 		if !connErr.ShouldRetry {
-			return errors.Wrap(connErr, em.noRetryMessage())
+			return noRetryError{cause: errors.Wrap(connErr, em.noRetryMessage())}
 		}
 		return errors.Wrapf(connErr, "edge responded with RetryAfter=%v", connErr.RetryAfter)
 	}
 
+	streamHandler.setLocation(connResult.ServerInfo.LocationName)
 	em.state.newConnection(h2muxConn)
 	em.logger.Infof("connected to %s", connResult.ServerInfo.LocationName)
+	em.applyConfig(ctx, &connResult.ClientConfig)
 	return nil
 }
 
+// applyConfig applies the ClientConfig returned by Connect. Failing to apply it doesn't close the connection,
+// the current config is kept instead.
+func (em *EdgeManager) applyConfig(ctx context.Context, config *pogs.ClientConfig) {
+	// The edge didn't hand out a config
+	if config.Version == pogs.InitVersion() {
+		return
+	}
+	applyCtx, cancel := context.WithTimeout(ctx, applyConfigTimeout)
+	defer cancel()
+	result, err := em.clientService.UseConfiguration(applyCtx, config)
+	if err != nil {
+		em.logger.WithError(err).Errorf("Cannot apply configuration %v returned by the edge", config.Version)
+		return
+	}
+	for _, failedConfig := range result.FailedConfigs {
+		em.logger.Errorf("Cannot apply %+v returned by the edge, reason: %s", failedConfig.Config, failedConfig.Reason)
+	}
+}
+
 func (em *EdgeManager) closeConnection(ctx context.Context) error {
 	conn := em.state.getFirstConnection()
 	if conn == nil {
@@ -169,6 +270,7 @@ func (em *EdgeManager) serveConn(ctx context.Context, conn *Connection) {
 	err := conn.Serve(ctx)
 	em.logger.WithError(err).Warn("Connection closed")
 	em.state.closeConnection(conn)
+	edgeConnMetrics.delete(conn.id.String())
 }
 
 func (em *EdgeManager) dialEdge(ctx context.Context, edgeIP *net.TCPAddr) (*tls.Conn, error) {
@@ -177,8 +279,7 @@ func (em *EdgeManager) dialEdge(ctx context.Context, edgeIP *net.TCPAddr) (*tls.
 	dialCtx, dialCancel := context.WithTimeout(ctx, timeout)
 	defer dialCancel()
 
-	dialer := net.Dialer{DualStack: true}
-	edgeConn, err := dialer.DialContext(dialCtx, "tcp", edgeIP.String())
+	edgeConn, err := em.edgeDialer.DialContext(dialCtx, "tcp", edgeIP.String())
 	if err != nil {
 		return nil, dialError{cause: errors.Wrap(err, "DialContext error")}
 	}
@@ -204,11 +305,56 @@ func (em *EdgeManager) shutdown() {
 	em.state.shutdown()
 }
 
+// noRetryError means the edge refused the connection, and EdgeManager should stop
+type noRetryError struct {
+	cause error
+}
+
+func (e noRetryError) Error() string {
+	return e.cause.Error()
+}
+
+// edgeAddrBackoff decides when to connect to an edge address again after failed attempts
+type edgeAddrBackoff struct {
+	// attempts is the number of consecutive failed attempts
+	attempts uint8
+	backoff  *backoff.Backoff
+	retryAt  time.Time
+}
+
+func newEdgeAddrBackoff() *edgeAddrBackoff {
+	return &edgeAddrBackoff{
+		backoff: backoff.New(maxEdgeRetryBackoff, edgeRetryInterval),
+	}
+}
+
+// failed records a failed attempt, and returns how long to wait before the next one. The edge can ask to wait for
+// ConnectError.RetryAfter, otherwise the wait grows exponentially with jitter.
+func (b *edgeAddrBackoff) failed(err error, now time.Time) time.Duration {
+	if b.attempts < math.MaxUint8 {
+		b.attempts++
+	}
+	var wait time.Duration
+	if connErr, ok := errors.Cause(err).(*pogs.ConnectError); ok && connErr.RetryAfter > 0 {
+		wait = connErr.RetryAfter
+	} else {
+		wait = b.backoff.Duration()
+	}
+	b.retryAt = now.Add(wait)
+	return wait
+}
+
+func (b *edgeAddrBackoff) canRetry(now time.Time) bool {
+	return !now.Before(b.retryAt)
+}
+
 type edgeManagerState struct {
 	sync.RWMutex
 	configurable   *EdgeManagerConfigurable
 	userCredential []byte
 	conns          map[uuid.UUID]*Connection
+	// unregistering is set once EdgeManager is shutting down, so no new connections should be created
+	unregistering bool
 }
 
 func newEdgeConnectionManagerState(configurable *EdgeManagerConfigurable, userCredential []byte) *edgeManagerState {
@@ -222,6 +368,9 @@ func newEdgeConnectionManagerState(configurable *EdgeManagerConfigurable, userCr
 func (ems *edgeManagerState) shouldCreateConnection(availableEdgeAddrs uint8) bool {
 	ems.RLock()
 	defer ems.RUnlock()
+	if ems.unregistering {
+		return false
+	}
 	expectedHAConns := ems.configurable.NumHAConnections
 	if availableEdgeAddrs < expectedHAConns {
 		expectedHAConns = availableEdgeAddrs
@@ -247,6 +396,22 @@ func (ems *edgeManagerState) closeConnection(conn *Connection) {
 	delete(ems.conns, conn.id)
 }
 
+func (ems *edgeManagerState) stopCreatingConnections() {
+	ems.Lock()
+	defer ems.Unlock()
+	ems.unregistering = true
+}
+
+func (ems *edgeManagerState) getConnections() []*Connection {
+	ems.RLock()
+	defer ems.RUnlock()
+	conns := make([]*Connection, 0, len(ems.conns))
+	for _, conn := range ems.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
 func (ems *edgeManagerState) getFirstConnection() *Connection {
 	ems.RLock()
 	defer ems.RUnlock()
@@ -1,6 +1,9 @@
 package connection
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"testing"
 	"time"
 
@@ -10,6 +13,7 @@ import (
 	"github.com/cloudflare/cloudflared/h2mux"
 	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -45,16 +49,27 @@ var (
 type mockStreamHandler struct {
 }
 
-func (msh *mockStreamHandler) ServeStream(*h2mux.MuxedStream) error {
+func (msh *mockStreamHandler) ServeStreamAt(*h2mux.MuxedStream, string) error {
 	return nil
 }
 
+type mockClientService struct {
+	appliedConfigs []*pogs.ClientConfig
+}
+
+func (mcs *mockClientService) UseConfiguration(ctx context.Context, config *pogs.ClientConfig) (*pogs.UseConfigurationResult, error) {
+	mcs.appliedConfigs = append(mcs.appliedConfigs, config)
+	return &pogs.UseConfigurationResult{Success: true}, nil
+}
+
 func mockEdgeManager() *EdgeManager {
 	return NewEdgeManager(
 		&mockStreamHandler{},
+		&mockClientService{},
 		configurable,
 		[]byte{},
 		nil,
+		&net.Dialer{},
 		&mockEdgeServiceDiscoverer{},
 		cloudflaredConfig,
 		logrus.New(),
@@ -75,3 +90,69 @@ func TestUpdateConfigurable(t *testing.T) {
 
 	assert.Equal(t, newConfigurable, m.state.getConfigurable())
 }
+
+func TestUnregisterStopsCreatingConnections(t *testing.T) {
+	m := mockEdgeManager()
+	assert.True(t, m.state.shouldCreateConnection(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m.Unregister(ctx, 10*time.Second)
+	assert.False(t, m.state.shouldCreateConnection(1))
+}
+
+func TestApplyConnectResultConfig(t *testing.T) {
+	m := mockEdgeManager()
+	clientService := m.clientService.(*mockClientService)
+
+	// A config without a version wasn't sent by the edge
+	m.applyConfig(context.Background(), &pogs.ClientConfig{})
+	assert.Empty(t, clientService.appliedConfigs)
+
+	config := &pogs.ClientConfig{
+		Version: 3,
+		EdgeConnectionConfig: &pogs.EdgeConnectionConfig{
+			NumHAConnections: 2,
+		},
+	}
+	m.applyConfig(context.Background(), config)
+	assert.Equal(t, []*pogs.ClientConfig{config}, clientService.appliedConfigs)
+}
+
+func TestEdgeAddrBackoff(t *testing.T) {
+	now := time.Now()
+	b := newEdgeAddrBackoff()
+	assert.True(t, b.canRetry(now))
+
+	// Dial errors back off exponentially with jitter
+	wait := b.failed(dialError{cause: fmt.Errorf("connection refused")}, now)
+	assert.True(t, wait < edgeRetryInterval)
+	assert.Equal(t, uint8(1), b.attempts)
+	wait = b.failed(dialError{cause: fmt.Errorf("connection refused")}, now)
+	assert.True(t, wait < 2*edgeRetryInterval)
+	assert.Equal(t, uint8(2), b.attempts)
+
+	// The edge can ask to wait longer
+	connErr := &pogs.ConnectError{Cause: "too many connections", RetryAfter: time.Minute, ShouldRetry: true}
+	wait = b.failed(errors.Wrap(connErr, "edge responded with an error"), now)
+	assert.Equal(t, time.Minute, wait)
+	assert.Equal(t, uint8(3), b.attempts)
+	assert.False(t, b.canRetry(now.Add(59*time.Second)))
+	assert.True(t, b.canRetry(now.Add(time.Minute)))
+}
+
+func TestNewConnectionBacksOff(t *testing.T) {
+	// Nothing listens on the address of mockEdgeServiceDiscoverer
+	m := mockEdgeManager()
+	addr := m.serviceDiscoverer.Addr().String()
+
+	assert.Error(t, m.newConnection(context.Background()))
+	if assert.Contains(t, m.addrBackoffs, addr) {
+		assert.Equal(t, uint8(1), m.addrBackoffs[addr].attempts)
+	}
+
+	// The next attempt is skipped while backing off
+	m.addrBackoffs[addr].retryAt = time.Now().Add(time.Hour)
+	assert.NoError(t, m.newConnection(context.Background()))
+	assert.Equal(t, uint8(1), m.addrBackoffs[addr].attempts)
+}
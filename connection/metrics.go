@@ -0,0 +1,86 @@
+package connection
+
+import (
+	"time"
+
+	"github.com/cloudflare/cloudflared/h2mux"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "cloudflared"
+	metricsSubsystem = "edge_connection"
+)
+
+// connMetrics are the metrics of each edge connection, labelled by connection ID
+type connMetrics struct {
+	rtt              *prometheus.GaugeVec
+	receiveWindowAve *prometheus.GaugeVec
+	sendWindowAve    *prometheus.GaugeVec
+	inBoundRateCurr  *prometheus.GaugeVec
+	outBoundRateCurr *prometheus.GaugeVec
+	activeStreams    *prometheus.GaugeVec
+	rejectedStreams  *prometheus.CounterVec
+	// rejectedStreamsTracker turns the rejected streams of each connection into increments of rejectedStreams
+	rejectedStreamsTracker h2mux.RejectedStreamsTracker
+}
+
+var edgeConnMetrics = newConnMetrics()
+
+func newConnMetrics() *connMetrics {
+	newGaugeVec := func(name, help string) *prometheus.GaugeVec {
+		gaugeVec := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricsNamespace,
+				Subsystem: metricsSubsystem,
+				Name:      name,
+				Help:      help,
+			},
+			[]string{"connection_id"},
+		)
+		prometheus.MustRegister(gaugeVec)
+		return gaugeVec
+	}
+	rejectedStreams := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "rejected_streams",
+			Help:      "Number of streams from the edge refused because the maximum number of concurrent streams were open",
+		},
+		[]string{"connection_id"},
+	)
+	prometheus.MustRegister(rejectedStreams)
+	return &connMetrics{
+		rtt:              newGaugeVec("rtt", "Round-trip time in millisecond"),
+		receiveWindowAve: newGaugeVec("receive_window_ave", "Average receive window size in bytes"),
+		sendWindowAve:    newGaugeVec("send_window_ave", "Average send window size in bytes"),
+		inBoundRateCurr:  newGaugeVec("inbound_bytes_per_sec_curr", "Current inbounding bytes per second, 0 if there is no incoming connection"),
+		outBoundRateCurr: newGaugeVec("outbound_bytes_per_sec_curr", "Current outbounding bytes per second, 0 if there is no outgoing traffic"),
+		activeStreams:    newGaugeVec("active_streams", "Number of open streams"),
+		rejectedStreams:  rejectedStreams,
+	}
+}
+
+func (m *connMetrics) update(connectionID string, metrics *h2mux.MuxerMetrics) {
+	m.rtt.WithLabelValues(connectionID).Set(float64(metrics.RTT / time.Millisecond))
+	m.receiveWindowAve.WithLabelValues(connectionID).Set(metrics.ReceiveWindowAve)
+	m.sendWindowAve.WithLabelValues(connectionID).Set(metrics.SendWindowAve)
+	m.inBoundRateCurr.WithLabelValues(connectionID).Set(float64(metrics.InBoundRateCurr))
+	m.outBoundRateCurr.WithLabelValues(connectionID).Set(float64(metrics.OutBoundRateCurr))
+	m.activeStreams.WithLabelValues(connectionID).Set(float64(metrics.ActiveStreams))
+	m.rejectedStreams.WithLabelValues(connectionID).Add(float64(m.rejectedStreamsTracker.Increment(connectionID, metrics.RejectedStreams)))
+}
+
+// delete removes the metrics of a closed connection
+func (m *connMetrics) delete(connectionID string) {
+	m.rtt.DeleteLabelValues(connectionID)
+	m.receiveWindowAve.DeleteLabelValues(connectionID)
+	m.sendWindowAve.DeleteLabelValues(connectionID)
+	m.inBoundRateCurr.DeleteLabelValues(connectionID)
+	m.outBoundRateCurr.DeleteLabelValues(connectionID)
+	m.activeStreams.DeleteLabelValues(connectionID)
+	m.rejectedStreams.DeleteLabelValues(connectionID)
+	m.rejectedStreamsTracker.Delete(connectionID)
+}
@@ -0,0 +1,31 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/cloudflare/cloudflared/h2mux"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectedStreamsCounter(t *testing.T) {
+	rejectedStreams := func(connectionID string) float64 {
+		var metric dto.Metric
+		assert.NoError(t, edgeConnMetrics.rejectedStreams.WithLabelValues(connectionID).Write(&metric))
+		return metric.GetCounter().GetValue()
+	}
+
+	edgeConnMetrics.update("conn", &h2mux.MuxerMetrics{RejectedStreams: 3})
+	assert.Equal(t, float64(3), rejectedStreams("conn"))
+	edgeConnMetrics.update("conn", &h2mux.MuxerMetrics{RejectedStreams: 5})
+	assert.Equal(t, float64(5), rejectedStreams("conn"))
+	edgeConnMetrics.update("conn", &h2mux.MuxerMetrics{RejectedStreams: 5})
+	assert.Equal(t, float64(5), rejectedStreams("conn"))
+
+	// A closed connection starts over
+	edgeConnMetrics.delete("conn")
+	edgeConnMetrics.update("conn", &h2mux.MuxerMetrics{RejectedStreams: 2})
+	assert.Equal(t, float64(2), rejectedStreams("conn"))
+	edgeConnMetrics.delete("conn")
+}
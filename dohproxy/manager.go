@@ -0,0 +1,108 @@
+package dohproxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Manager runs a Proxy for each DoHProxyConfig
+type Manager struct {
+	sync.Mutex
+	// proxies maps the key of a DoHProxyConfig to the Proxy serving it
+	proxies map[string]*Proxy
+	client  *http.Client
+	logger  *logrus.Entry
+}
+
+func NewManager(logger *logrus.Logger) *Manager {
+	return &Manager{
+		proxies: make(map[string]*Proxy),
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSHandshakeTimeout: upstreamTimeout,
+				MaxIdleConnsPerHost: 16,
+			},
+			Timeout: upstreamTimeout,
+		},
+		logger: logger.WithField("subsystem", "dohProxy"),
+	}
+}
+
+// UpdateConfig stops the proxies that aren't in newConfigs, and starts a proxy for each config that doesn't
+// have one yet. Proxies whose config is unchanged keep running.
+func (m *Manager) UpdateConfig(newConfigs []*pogs.DoHProxyConfig) (failedConfigs []*pogs.FailedConfig) {
+	newConfigSet := make(map[string]*pogs.DoHProxyConfig, len(newConfigs))
+	for _, config := range newConfigs {
+		newConfigSet[configKey(config)] = config
+	}
+
+	// Stop removed proxies first, so their addresses can be reused by new proxies. They are shut down without
+	// holding the lock, because Shutdown waits for in-flight queries.
+	var removedProxies []*Proxy
+	m.Lock()
+	for key, proxy := range m.proxies {
+		if _, ok := newConfigSet[key]; !ok {
+			removedProxies = append(removedProxies, proxy)
+			delete(m.proxies, key)
+		}
+	}
+	m.Unlock()
+	shutdownProxies(removedProxies)
+
+	m.Lock()
+	defer m.Unlock()
+	for _, config := range newConfigs {
+		key := configKey(config)
+		if _, ok := m.proxies[key]; ok {
+			continue
+		}
+		proxy, err := NewProxy(config, m.client, m.logger)
+		if err != nil {
+			m.logger.WithError(err).Errorf("Cannot start DNS over HTTPS proxy %+v", config)
+			failedConfigs = append(failedConfigs, &pogs.FailedConfig{
+				Config: config,
+				Reason: config.FailReason(err),
+			})
+			continue
+		}
+		m.proxies[key] = proxy
+	}
+	return
+}
+
+// Shutdown stops every proxy
+func (m *Manager) Shutdown() {
+	var proxies []*Proxy
+	m.Lock()
+	for key, proxy := range m.proxies {
+		proxies = append(proxies, proxy)
+		delete(m.proxies, key)
+	}
+	m.Unlock()
+	shutdownProxies(proxies)
+}
+
+// shutdownProxies stops proxies concurrently
+func shutdownProxies(proxies []*Proxy) {
+	var wg sync.WaitGroup
+	for _, proxy := range proxies {
+		wg.Add(1)
+		go func(proxy *Proxy) {
+			defer wg.Done()
+			proxy.Shutdown()
+		}(proxy)
+	}
+	wg.Wait()
+}
+
+// configKey identifies a DoHProxyConfig, so a proxy is only restarted if its config changed
+func configKey(config *pogs.DoHProxyConfig) string {
+	return fmt.Sprintf("%s:%d|%s", config.ListenHost, config.ListenPort, strings.Join(config.Upstreams, ","))
+}
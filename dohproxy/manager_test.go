@@ -0,0 +1,54 @@
+package dohproxy
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagerUpdateConfig(t *testing.T) {
+	m := NewManager(logrus.New())
+	defer m.Shutdown()
+
+	first := &pogs.DoHProxyConfig{ListenHost: "127.0.0.1", Upstreams: []string{"https://1.1.1.1/dns-query"}}
+	second := &pogs.DoHProxyConfig{ListenHost: "127.0.0.1", Upstreams: []string{"https://1.0.0.1/dns-query"}}
+	assert.Empty(t, m.UpdateConfig([]*pogs.DoHProxyConfig{first, second}))
+	assert.Len(t, m.proxies, 2)
+	firstProxy := m.proxies[configKey(first)]
+
+	// Unchanged configs keep their proxy, removed configs are stopped
+	assert.Empty(t, m.UpdateConfig([]*pogs.DoHProxyConfig{first}))
+	assert.Len(t, m.proxies, 1)
+	assert.Equal(t, firstProxy, m.proxies[configKey(first)])
+
+	assert.Empty(t, m.UpdateConfig(nil))
+	assert.Empty(t, m.proxies)
+}
+
+func TestManagerUpdateConfigFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	m := NewManager(logrus.New())
+	defer m.Shutdown()
+
+	valid := &pogs.DoHProxyConfig{ListenHost: "127.0.0.1", Upstreams: []string{"https://1.1.1.1/dns-query"}}
+	portInUse := &pogs.DoHProxyConfig{
+		ListenHost: "127.0.0.1",
+		ListenPort: uint16(port),
+		Upstreams:  []string{"https://1.1.1.1/dns-query"},
+	}
+	failedConfigs := m.UpdateConfig([]*pogs.DoHProxyConfig{valid, portInUse})
+	if assert.Len(t, failedConfigs, 1) {
+		assert.Equal(t, portInUse, failedConfigs[0].Config)
+		assert.Contains(t, failedConfigs[0].Reason, strconv.Itoa(port))
+	}
+	assert.Len(t, m.proxies, 1)
+}
@@ -0,0 +1,261 @@
+// Package dohproxy serves DNS over UDP and TCP, and forwards queries to DNS over HTTPS (RFC 8484) upstreams.
+package dohproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dnsMessageContentType = "application/dns-message"
+	// maxDNSMessageSize is the largest message that fits in the 2 bytes length prefix of DNS over TCP
+	maxDNSMessageSize = 65535
+	upstreamTimeout   = 5 * time.Second
+	tcpIdleTimeout    = 10 * time.Second
+)
+
+// Proxy listens for DNS queries on ListenHost:ListenPort over UDP and TCP, and forwards them to the first upstream
+// that answers
+type Proxy struct {
+	config      *pogs.DoHProxyConfig
+	upstreams   []*url.URL
+	client      *http.Client
+	udpConn     net.PacketConn
+	tcpListener net.Listener
+	// tcpConnsLock is a mutex for tcpConns
+	tcpConnsLock sync.Mutex
+	// tcpConns are the accepted TCP connections, which Shutdown closes
+	tcpConns  map[net.Conn]struct{}
+	shutdownC chan struct{}
+	wg        sync.WaitGroup
+	logger    *logrus.Entry
+}
+
+// NewProxy binds the listeners of config, and starts serving queries. Upstreams are queried with client.
+func NewProxy(config *pogs.DoHProxyConfig, client *http.Client, logger *logrus.Entry) (*Proxy, error) {
+	if len(config.Upstreams) == 0 {
+		return nil, fmt.Errorf("at least one upstream is required")
+	}
+	upstreams := make([]*url.URL, len(config.Upstreams))
+	for i, upstream := range config.Upstreams {
+		upstreamURL, err := url.Parse(upstream)
+		if err != nil {
+			return nil, errors.Wrapf(err, "upstream %s is not a valid URL", upstream)
+		}
+		if upstreamURL.Scheme != "https" {
+			return nil, fmt.Errorf("upstream %s must be an https URL", upstream)
+		}
+		upstreams[i] = upstreamURL
+	}
+
+	listenAddr := net.JoinHostPort(config.ListenHost, strconv.Itoa(int(config.ListenPort)))
+	tcpListener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot listen on TCP")
+	}
+	// If ListenPort is 0, listen on UDP with the same port the OS picked for TCP
+	udpAddr := net.JoinHostPort(config.ListenHost, strconv.Itoa(tcpListener.Addr().(*net.TCPAddr).Port))
+	udpConn, err := net.ListenPacket("udp", udpAddr)
+	if err != nil {
+		tcpListener.Close()
+		return nil, errors.Wrap(err, "cannot listen on UDP")
+	}
+
+	p := &Proxy{
+		config:      config,
+		upstreams:   upstreams,
+		client:      client,
+		udpConn:     udpConn,
+		tcpListener: tcpListener,
+		tcpConns:    make(map[net.Conn]struct{}),
+		shutdownC:   make(chan struct{}),
+		logger:      logger.WithField("listenAddr", listenAddr),
+	}
+	p.wg.Add(2)
+	go p.serveUDP()
+	go p.serveTCP()
+	p.logger.Infof("DNS over HTTPS proxy started, upstreams: %v", config.Upstreams)
+	return p, nil
+}
+
+// Shutdown closes the listeners and the TCP connections, and waits for in-flight queries to finish
+func (p *Proxy) Shutdown() {
+	close(p.shutdownC)
+	p.udpConn.Close()
+	p.tcpListener.Close()
+	p.tcpConnsLock.Lock()
+	for conn := range p.tcpConns {
+		conn.Close()
+	}
+	p.tcpConnsLock.Unlock()
+	p.wg.Wait()
+	p.logger.Info("DNS over HTTPS proxy stopped")
+}
+
+func (p *Proxy) serveUDP() {
+	defer p.wg.Done()
+	buf := make([]byte, maxDNSMessageSize)
+	for {
+		n, clientAddr, err := p.udpConn.ReadFrom(buf)
+		if err != nil {
+			if !p.isShutdown() {
+				p.logger.WithError(err).Error("Cannot read UDP query")
+			}
+			return
+		}
+		query := make([]byte, n)
+		copy(query, buf[:n])
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			response, err := p.resolve(query)
+			if err != nil {
+				p.logger.WithError(err).Error("Cannot resolve UDP query")
+				return
+			}
+			if _, err := p.udpConn.WriteTo(response, clientAddr); err != nil {
+				p.logger.WithError(err).Error("Cannot write UDP response")
+			}
+		}()
+	}
+}
+
+func (p *Proxy) serveTCP() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.tcpListener.Accept()
+		if err != nil {
+			if !p.isShutdown() {
+				p.logger.WithError(err).Error("Cannot accept TCP connection")
+			}
+			return
+		}
+		if !p.trackTCPConn(conn) {
+			conn.Close()
+			return
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer p.untrackTCPConn(conn)
+			if err := p.serveTCPConn(conn); err != nil && err != io.EOF && !p.isShutdown() {
+				p.logger.WithError(err).Debug("TCP connection closed")
+			}
+		}()
+	}
+}
+
+// trackTCPConn records conn so Shutdown can close it. It returns false if the proxy is shutting down.
+func (p *Proxy) trackTCPConn(conn net.Conn) bool {
+	p.tcpConnsLock.Lock()
+	defer p.tcpConnsLock.Unlock()
+	if p.isShutdown() {
+		return false
+	}
+	p.tcpConns[conn] = struct{}{}
+	return true
+}
+
+// untrackTCPConn closes conn, and stops tracking it
+func (p *Proxy) untrackTCPConn(conn net.Conn) {
+	p.tcpConnsLock.Lock()
+	defer p.tcpConnsLock.Unlock()
+	conn.Close()
+	delete(p.tcpConns, conn)
+}
+
+// serveTCPConn serves queries on a TCP connection until the client closes it or it is idle for tcpIdleTimeout.
+// Each message is prefixed by its length in 2 bytes, see RFC 1035 section 4.2.2.
+func (p *Proxy) serveTCPConn(conn net.Conn) error {
+	for {
+		conn.SetDeadline(time.Now().Add(tcpIdleTimeout))
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return err
+		}
+		query := make([]byte, length)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return err
+		}
+		response, err := p.resolve(query)
+		if err != nil {
+			return err
+		}
+		if err := binary.Write(conn, binary.BigEndian, uint16(len(response))); err != nil {
+			return err
+		}
+		if _, err := conn.Write(response); err != nil {
+			return err
+		}
+	}
+}
+
+// resolve forwards query to each upstream in order, until one of them answers
+func (p *Proxy) resolve(query []byte) ([]byte, error) {
+	var lastErr error
+	for _, upstream := range p.upstreams {
+		response, err := p.exchange(upstream, query)
+		if err == nil {
+			return response, nil
+		}
+		p.logger.WithError(err).Warnf("Upstream %s failed", upstream)
+		lastErr = err
+	}
+	return nil, errors.Wrap(lastErr, "all upstreams failed")
+}
+
+// exchange sends query to upstream with the POST method of RFC 8484
+func (p *Proxy) exchange(upstream *url.URL, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost, upstream.String(), bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", dnsMessageContentType)
+	req.Header.Set("Accept", dnsMessageContentType)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream responded with status %s", resp.Status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != dnsMessageContentType {
+		return nil, fmt.Errorf("upstream responded with Content-Type %q", contentType)
+	}
+	response, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDNSMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(response) > maxDNSMessageSize {
+		return nil, fmt.Errorf("upstream response is larger than %d bytes", maxDNSMessageSize)
+	}
+	return response, nil
+}
+
+func (p *Proxy) isShutdown() bool {
+	select {
+	case <-p.shutdownC:
+		return true
+	default:
+		return false
+	}
+}
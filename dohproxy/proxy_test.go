@@ -0,0 +1,153 @@
+package dohproxy
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var testQuery = []byte{0xab, 0xcd, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+// newTestUpstream returns a DoH server that answers every query with the query itself followed by answer
+func newTestUpstream(t *testing.T, answer []byte) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, dnsMessageContentType, r.Header.Get("Content-Type"))
+		query, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		w.Header().Set("Content-Type", dnsMessageContentType)
+		w.Write(append(query, answer...))
+	}))
+}
+
+func newTestProxy(t *testing.T, client *http.Client, upstreams ...string) *Proxy {
+	config := &pogs.DoHProxyConfig{
+		ListenHost: "127.0.0.1",
+		Upstreams:  upstreams,
+	}
+	proxy, err := NewProxy(config, client, logrus.NewEntry(logrus.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proxy
+}
+
+func TestProxyUDP(t *testing.T) {
+	upstream := newTestUpstream(t, []byte("answer"))
+	defer upstream.Close()
+	proxy := newTestProxy(t, upstream.Client(), upstream.URL+"/dns-query")
+	defer proxy.Shutdown()
+
+	conn, err := net.Dial("udp", proxy.udpConn.LocalAddr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write(testQuery)
+	assert.NoError(t, err)
+
+	response := make([]byte, maxDNSMessageSize)
+	n, err := conn.Read(response)
+	assert.NoError(t, err)
+	assert.Equal(t, append(testQuery, []byte("answer")...), response[:n])
+}
+
+func TestProxyTCP(t *testing.T) {
+	upstream := newTestUpstream(t, []byte("answer"))
+	defer upstream.Close()
+	proxy := newTestProxy(t, upstream.Client(), upstream.URL+"/dns-query")
+	defer proxy.Shutdown()
+
+	conn, err := net.Dial("tcp", proxy.tcpListener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Multiple queries can be sent on the same connection
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, binary.Write(conn, binary.BigEndian, uint16(len(testQuery))))
+		_, err = conn.Write(testQuery)
+		assert.NoError(t, err)
+
+		var length uint16
+		assert.NoError(t, binary.Read(conn, binary.BigEndian, &length))
+		response := make([]byte, length)
+		_, err = io.ReadFull(conn, response)
+		assert.NoError(t, err)
+		assert.Equal(t, append(testQuery, []byte("answer")...), response)
+	}
+}
+
+func TestShutdownClosesTCPConns(t *testing.T) {
+	upstream := newTestUpstream(t, []byte("answer"))
+	defer upstream.Close()
+	proxy := newTestProxy(t, upstream.Client(), upstream.URL+"/dns-query")
+
+	conn, err := net.Dial("tcp", proxy.tcpListener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	assert.NoError(t, binary.Write(conn, binary.BigEndian, uint16(len(testQuery))))
+	_, err = conn.Write(testQuery)
+	assert.NoError(t, err)
+	var length uint16
+	assert.NoError(t, binary.Read(conn, binary.BigEndian, &length))
+	_, err = io.ReadFull(conn, make([]byte, length))
+	assert.NoError(t, err)
+
+	// The connection is idle, but Shutdown doesn't wait for tcpIdleTimeout
+	shutdownC := make(chan struct{})
+	go func() {
+		proxy.Shutdown()
+		close(shutdownC)
+	}()
+	select {
+	case <-shutdownC:
+	case <-time.After(tcpIdleTimeout / 2):
+		t.Fatal("Shutdown waited for the idle TCP connection")
+	}
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestProxyUpstreamFailover(t *testing.T) {
+	failingUpstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failingUpstream.Close()
+	upstream := newTestUpstream(t, []byte("answer"))
+	defer upstream.Close()
+
+	// Both test servers use the same certificate, so either client trusts both
+	proxy := newTestProxy(t, upstream.Client(), failingUpstream.URL, upstream.URL)
+	defer proxy.Shutdown()
+
+	response, err := proxy.resolve(testQuery)
+	assert.NoError(t, err)
+	assert.Equal(t, append(testQuery, []byte("answer")...), response)
+
+	proxy.upstreams = proxy.upstreams[:1]
+	_, err = proxy.resolve(testQuery)
+	assert.Error(t, err)
+}
+
+func TestNewProxyInvalidUpstream(t *testing.T) {
+	for _, upstreams := range [][]string{
+		nil,
+		{"http://1.1.1.1/dns-query"},
+		{"https://1.1.1.1/dns-query", "://"},
+	} {
+		_, err := NewProxy(&pogs.DoHProxyConfig{ListenHost: "127.0.0.1", Upstreams: upstreams}, http.DefaultClient,
+			logrus.NewEntry(logrus.New()))
+		assert.Error(t, err, "upstreams %v", upstreams)
+	}
+}
@@ -0,0 +1,314 @@
+// Package edgeemulator emulates Cloudflare's edge, so tunnels can be tested end to end without it. Connections from
+// cloudflared are accepted over TLS and h2mux, and each hostname served by the tunnels is exposed on a local HTTP
+// port.
+package edgeemulator
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflared/h2mux"
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ServerName is the name in the certificate of the emulated edge
+	ServerName       = "edge.emulator.local"
+	handshakeTimeout = 5 * time.Second
+)
+
+// Config is how the emulated edge responds to cloudflared
+type Config struct {
+	// LocationName is returned by GetServerInfo and Connect
+	LocationName string
+	// ConnectError is returned by Connect if it's not nil
+	ConnectError *pogs.ConnectError
+	// ClientConfig is returned by Connect if it's not nil
+	ClientConfig *pogs.ClientConfig
+}
+
+// Edge accepts tunnel connections on a local TCP port
+type Edge struct {
+	sync.RWMutex
+	config   *Config
+	listener net.Listener
+	// certPool trusts the self-signed certificate of the edge
+	certPool *x509.CertPool
+	conns    map[*tunnelConn]struct{}
+	// hostnames maps each exposed hostname to the local HTTP server that forwards requests to tunnels
+	hostnames map[string]*hostnameServer
+	// nextTrialID numbers the hostnames given to tunnels registered without one
+	nextTrialID int
+	shutdownC   chan struct{}
+	wg          sync.WaitGroup
+	logger      *logrus.Entry
+}
+
+// New starts an Edge listening on 127.0.0.1 with a self-signed certificate for ServerName
+func New(config *Config, logger *logrus.Logger) (*Edge, error) {
+	cert, certPool, err := selfSignedCert()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create the certificate of the edge")
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot listen for tunnel connections")
+	}
+	e := &Edge{
+		config:    config,
+		listener:  listener,
+		certPool:  certPool,
+		conns:     make(map[*tunnelConn]struct{}),
+		hostnames: make(map[string]*hostnameServer),
+		shutdownC: make(chan struct{}),
+		logger:    logger.WithField("subsystem", "edgeEmulator"),
+	}
+	e.wg.Add(1)
+	go e.acceptConns()
+	e.logger.Infof("Edge emulator listening on %s", listener.Addr())
+	return e, nil
+}
+
+// Addr is the address cloudflared should connect to
+func (e *Edge) Addr() *net.TCPAddr {
+	return e.listener.Addr().(*net.TCPAddr)
+}
+
+// ClientTLSConfig is the TLS config cloudflared should use to connect to the edge
+func (e *Edge) ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		RootCAs:    e.certPool,
+		ServerName: ServerName,
+	}
+}
+
+// Expose starts forwarding requests sent to the returned local address to the tunnels serving hostname. Hostnames
+// registered by RegisterTunnel are exposed automatically.
+func (e *Edge) Expose(hostname string) (string, error) {
+	e.Lock()
+	defer e.Unlock()
+	if server, ok := e.hostnames[hostname]; ok {
+		return server.addr(), nil
+	}
+	server, err := newHostnameServer(e, hostname)
+	if err != nil {
+		return "", err
+	}
+	e.hostnames[hostname] = server
+	e.logger.Infof("Exposing %s on http://%s", hostname, server.addr())
+	return server.addr(), nil
+}
+
+// HostnameAddr returns the local address hostname is exposed on
+func (e *Edge) HostnameAddr(hostname string) (string, bool) {
+	e.RLock()
+	defer e.RUnlock()
+	server, ok := e.hostnames[hostname]
+	if !ok {
+		return "", false
+	}
+	return server.addr(), true
+}
+
+// Connections returns the number of connections that registered a tunnel or connected, and haven't unregistered
+func (e *Edge) Connections() int {
+	e.RLock()
+	defer e.RUnlock()
+	var count int
+	for conn := range e.conns {
+		if conn.isServing() {
+			count++
+		}
+	}
+	return count
+}
+
+// UseConfiguration pushes config to every connection that called Connect, and returns their results
+func (e *Edge) UseConfiguration(ctx context.Context, config *pogs.ClientConfig) ([]*pogs.UseConfigurationResult, error) {
+	var results []*pogs.UseConfigurationResult
+	for _, conn := range e.getConns() {
+		if !conn.isDeclarative() {
+			continue
+		}
+		result, err := conn.useConfiguration(ctx, config)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no connection to push the configuration to")
+	}
+	return results, nil
+}
+
+// Close stops accepting connections, and closes every tunnel connection and exposed hostname
+func (e *Edge) Close() {
+	close(e.shutdownC)
+	e.listener.Close()
+	e.Lock()
+	for conn := range e.conns {
+		conn.muxer.Shutdown()
+	}
+	for hostname, server := range e.hostnames {
+		server.close()
+		delete(e.hostnames, hostname)
+	}
+	e.Unlock()
+	e.wg.Wait()
+}
+
+func (e *Edge) acceptConns() {
+	defer e.wg.Done()
+	for {
+		conn, err := e.listener.Accept()
+		if err != nil {
+			select {
+			case <-e.shutdownC:
+			default:
+				e.logger.WithError(err).Error("Cannot accept tunnel connection")
+			}
+			return
+		}
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			e.serveConn(conn)
+		}()
+	}
+}
+
+func (e *Edge) serveConn(conn net.Conn) {
+	defer conn.Close()
+	tc := &tunnelConn{edge: e}
+	muxer, err := h2mux.Handshake(conn, conn, h2mux.MuxerConfig{
+		Timeout:  handshakeTimeout,
+		Handler:  tc,
+		IsClient: false,
+		Name:     "edge",
+		Logger:   e.logger.WithField("remoteAddr", conn.RemoteAddr()),
+	})
+	if err != nil {
+		e.logger.WithError(err).Error("Handshake with cloudflared failed")
+		return
+	}
+	tc.muxer = muxer
+
+	e.Lock()
+	select {
+	case <-e.shutdownC:
+		e.Unlock()
+		muxer.Shutdown()
+		return
+	default:
+		e.conns[tc] = struct{}{}
+	}
+	e.Unlock()
+	defer func() {
+		e.Lock()
+		delete(e.conns, tc)
+		e.Unlock()
+	}()
+
+	err = muxer.Serve(context.Background())
+	e.logger.WithError(err).Debug("Tunnel connection closed")
+}
+
+func (e *Edge) getConns() []*tunnelConn {
+	e.RLock()
+	defer e.RUnlock()
+	conns := make([]*tunnelConn, 0, len(e.conns))
+	for conn := range e.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// connForHostname returns a connection that serves hostname, or nil if there is none
+func (e *Edge) connForHostname(hostname string) *tunnelConn {
+	for _, conn := range e.getConns() {
+		if conn.serves(hostname) {
+			return conn
+		}
+	}
+	return nil
+}
+
+func (e *Edge) trialHostname() string {
+	e.Lock()
+	defer e.Unlock()
+	e.nextTrialID++
+	return fmt.Sprintf("trial-%d.emulator.local", e.nextTrialID)
+}
+
+func selfSignedCert() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: ServerName},
+		DNSNames:              []string{ServerName},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	certPool := x509.NewCertPool()
+	certPool.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: parsed}, certPool, nil
+}
+
+// hostnameServer is the local HTTP server of an exposed hostname
+type hostnameServer struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+func newHostnameServer(e *Edge, hostname string) (*hostnameServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot listen for requests to %s", hostname)
+	}
+	hs := &hostnameServer{
+		listener: listener,
+		server:   &http.Server{Handler: &requestForwarder{edge: e, hostname: hostname}},
+	}
+	go hs.server.Serve(listener)
+	return hs, nil
+}
+
+func (hs *hostnameServer) addr() string {
+	return hs.listener.Addr().String()
+}
+
+func (hs *hostnameServer) close() {
+	hs.server.Close()
+}
@@ -0,0 +1,338 @@
+package edgeemulator
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflared/buildinfo"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/h2mux"
+	cloudflaredorigin "github.com/cloudflare/cloudflared/origin"
+	"github.com/cloudflare/cloudflared/signal"
+	"github.com/cloudflare/cloudflared/streamhandler"
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const testHostname = "tunnel.example.com"
+
+// tunnelMetrics can only be created once, because it registers its collectors with prometheus
+var tunnelMetrics = cloudflaredorigin.NewTunnelMetrics()
+
+// runEdgeManager connects an EdgeManager to edge. It returns a channel of the configs pushed by the edge, which are
+// answered with result, and a channel of the error EdgeManager.Run returns.
+func runEdgeManager(ctx context.Context, t *testing.T, edge *Edge, originURL string, result *pogs.UseConfigurationResult) (chan *pogs.ClientConfig, chan error) {
+	return startEdgeManager(ctx, t, edge, &pogs.HTTPOriginConfig{URLString: originURL}, result, 0)
+}
+
+// startEdgeManager is runEdgeManager with any origin, and a limit of concurrent streams per connection
+func startEdgeManager(ctx context.Context, t *testing.T, edge *Edge, originConfig pogs.OriginConfig, result *pogs.UseConfigurationResult, maxConcurrentStreams uint32) (chan *pogs.ClientConfig, chan error) {
+	logger := logrus.New()
+	newConfigChan := make(chan *pogs.ClientConfig)
+	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
+	streamHandler := streamhandler.NewStreamHandler(newConfigChan, useConfigResultChan, buildinfo.GetBuildInfo("test"), logger)
+	reverseProxyConfig, err := pogs.NewReverseProxyConfig(testHostname, originConfig, 0, time.Second, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, streamHandler.UpdateConfig([]*pogs.ReverseProxyConfig{reverseProxyConfig}))
+
+	serviceDiscoverer, err := connection.NewEdgeHostnameResolver([]string{edge.Addr().String()})
+	assert.NoError(t, err)
+	edgeManager := connection.NewEdgeManager(
+		streamHandler,
+		streamHandler,
+		&connection.EdgeManagerConfigurable{
+			TunnelHostnames: []h2mux.TunnelHostname{testHostname},
+			EdgeConnectionConfig: &pogs.EdgeConnectionConfig{
+				NumHAConnections:    1,
+				HeartbeatInterval:   time.Second,
+				Timeout:             5 * time.Second,
+				MaxFailedHeartbeats: 5,
+			},
+		},
+		[]byte("origin cert"),
+		edge.ClientTLSConfig(),
+		&net.Dialer{},
+		serviceDiscoverer,
+		&connection.CloudflaredConfig{
+			CloudflaredID:        uuid.New(),
+			BuildInfo:            buildinfo.GetBuildInfo("test"),
+			Scope:                pogs.NewGroup("test"),
+			MaxConcurrentStreams: maxConcurrentStreams,
+		},
+		logger,
+	)
+	runErrC := make(chan error, 1)
+	go func() {
+		runErrC <- edgeManager.Run(ctx)
+	}()
+
+	// Stand in for Supervisor, which applies the configs
+	appliedConfigs := make(chan *pogs.ClientConfig, 1)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case config := <-newConfigChan:
+				appliedConfigs <- config
+				useConfigResultChan <- result
+			}
+		}
+	}()
+	return appliedConfigs, runErrC
+}
+
+func waitForConnections(t *testing.T, edge *Edge, expected int) {
+	for i := 0; i < 100; i++ {
+		if edge.Connections() == expected {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("expected %d connections, got %d", expected, edge.Connections())
+}
+
+func TestProxyThroughTunnel(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Origin-Path", r.URL.Path)
+		w.Write([]byte("Hello from the origin of " + r.Host))
+	}))
+	defer origin.Close()
+
+	edge, err := New(&Config{LocationName: "LAX"}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edge.Close()
+	addr, err := edge.Expose(testHostname)
+	assert.NoError(t, err)
+
+	// Nothing serves the hostname yet
+	resp, err := http.Get("http://" + addr + "/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	resp.Body.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runEdgeManager(ctx, t, edge, origin.URL, &pogs.UseConfigurationResult{Success: true})
+	waitForConnections(t, edge, 1)
+
+	resp, err = http.Get("http://" + addr + "/path")
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "/path", resp.Header.Get("X-Origin-Path"))
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello from the origin of "+testHostname, string(body))
+	}
+}
+
+func TestHelloWorldThroughTunnel(t *testing.T) {
+	edge, err := New(&Config{LocationName: "LAX"}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edge.Close()
+	addr, err := edge.Expose(testHostname)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startEdgeManager(ctx, t, edge, &pogs.HelloWorldOriginConfig{}, &pogs.UseConfigurationResult{Success: true}, 0)
+	waitForConnections(t, edge, 1)
+
+	resp, err := http.Get("http://" + addr + "/json")
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var info struct {
+			Location  string               `json:"location"`
+			BuildInfo *buildinfo.BuildInfo `json:"build_info"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		// The location is the one the edge returned when the connection was registered
+		assert.Equal(t, "LAX", info.Location)
+		assert.Equal(t, "test", info.BuildInfo.CloudflaredVersion)
+	}
+}
+
+func TestMaxConcurrentStreams(t *testing.T) {
+	receivedC := make(chan string, 2)
+	releaseC := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedC <- r.URL.Path
+		<-releaseC
+	}))
+	defer origin.Close()
+
+	edge, err := New(&Config{LocationName: "LAX"}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edge.Close()
+	addr, err := edge.Expose(testHostname)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startEdgeManager(ctx, t, edge, &pogs.HTTPOriginConfig{URLString: origin.URL}, &pogs.UseConfigurationResult{Success: true}, 1)
+	waitForConnections(t, edge, 1)
+
+	statusC := make(chan int, 2)
+	get := func(path string) {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			statusC <- 0
+			return
+		}
+		resp.Body.Close()
+		statusC <- resp.StatusCode
+	}
+	go get("/first")
+	assert.Equal(t, "/first", <-receivedC)
+
+	// The edge waits for the first stream to finish before it opens another one
+	go get("/second")
+	select {
+	case path := <-receivedC:
+		t.Fatalf("%s was sent while the connection had the maximum number of streams open", path)
+	case <-time.After(500 * time.Millisecond):
+	}
+	close(releaseC)
+	assert.Equal(t, "/second", <-receivedC)
+	assert.Equal(t, http.StatusOK, <-statusC)
+	assert.Equal(t, http.StatusOK, <-statusC)
+}
+
+func TestStartTunnelDaemon(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello from the origin of " + r.Host))
+	}))
+	defer origin.Close()
+
+	edge, err := New(&Config{LocationName: "LAX"}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edge.Close()
+
+	logger := logrus.New()
+	config := &cloudflaredorigin.TunnelConfig{
+		BuildInfo:         buildinfo.GetBuildInfo("test"),
+		EdgeAddrs:         []string{edge.Addr().String()},
+		GracePeriod:       time.Second,
+		HAConnections:     1,
+		HeartbeatInterval: time.Second,
+		Hostname:          testHostname,
+		Logger:            logger,
+		MaxHeartbeats:     5,
+		Metrics:           tunnelMetrics,
+		MetricsUpdateFreq: time.Second,
+		OriginCert:        []byte("origin cert"),
+		OriginUrl:         origin.URL,
+		TlsConfig:         edge.ClientTLSConfig(),
+		TransportLogger:   logger,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	connectedSignal := signal.New(make(chan struct{}))
+	daemonErrC := make(chan error, 1)
+	go func() {
+		daemonErrC <- cloudflaredorigin.StartTunnelDaemon(ctx, config, connectedSignal, uuid.New())
+	}()
+	select {
+	case <-connectedSignal.Wait():
+	case err := <-daemonErrC:
+		t.Fatalf("StartTunnelDaemon returned before connecting: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("StartTunnelDaemon didn't connect")
+	}
+	waitForConnections(t, edge, 1)
+
+	addr, ok := edge.HostnameAddr(testHostname)
+	assert.True(t, ok)
+	resp, err := http.Get("http://" + addr + "/")
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello from the origin of "+testHostname, string(body))
+	}
+
+	// The tunnel unregisters when the daemon stops
+	cancel()
+	select {
+	case err := <-daemonErrC:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("StartTunnelDaemon didn't return after the context was cancelled")
+	}
+	assert.Equal(t, 0, edge.Connections())
+}
+
+func TestPushConfiguration(t *testing.T) {
+	edge, err := New(&Config{LocationName: "LAX"}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edge.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	expectedResult := &pogs.UseConfigurationResult{Success: true}
+	appliedConfigs, _ := runEdgeManager(ctx, t, edge, "http://127.0.0.1:8080", expectedResult)
+	waitForConnections(t, edge, 1)
+
+	config := &pogs.ClientConfig{
+		Version:              2,
+		SupervisorConfig:     &pogs.SupervisorConfig{GracePeriod: time.Second},
+		EdgeConnectionConfig: &pogs.EdgeConnectionConfig{NumHAConnections: 2},
+	}
+	results, err := edge.UseConfiguration(ctx, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []*pogs.UseConfigurationResult{expectedResult}, results)
+	select {
+	case appliedConfig := <-appliedConfigs:
+		assert.Equal(t, config.Version, appliedConfig.Version)
+		assert.Equal(t, config.EdgeConnectionConfig.NumHAConnections, appliedConfig.EdgeConnectionConfig.NumHAConnections)
+	case <-time.After(time.Second):
+		t.Fatal("config wasn't applied")
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	edge, err := New(&Config{
+		ConnectError: &pogs.ConnectError{Cause: "account suspended", ShouldRetry: false},
+	}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edge.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, runErrC := runEdgeManager(ctx, t, edge, "http://127.0.0.1:8080", nil)
+	select {
+	case err := <-runErrC:
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "account suspended")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("EdgeManager didn't stop after a non-retryable error")
+	}
+	assert.Equal(t, 0, edge.Connections())
+	_, err = edge.UseConfiguration(ctx, &pogs.ClientConfig{Version: 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,208 @@
+package edgeemulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflared/h2mux"
+	"github.com/cloudflare/cloudflared/tunnelrpc"
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"zombiezen.com/go/capnproto2/rpc"
+)
+
+const openStreamTimeout = 30 * time.Second
+
+// tunnelConn is a connection from cloudflared. It serves the TunnelServer RPCs cloudflared makes on its RPC streams.
+type tunnelConn struct {
+	sync.RWMutex
+	edge  *Edge
+	muxer *h2mux.Muxer
+	// hostname is the hostname registered by RegisterTunnel
+	hostname string
+	// declarative is set once cloudflared called Connect. Requests to any exposed hostname can be sent to the
+	// connection, because cloudflared has the mapping from hostnames to origins.
+	declarative  bool
+	unregistered bool
+}
+
+// ServeStream implements h2mux.MuxedStreamHandler. cloudflared only opens streams to make RPCs.
+func (tc *tunnelConn) ServeStream(stream *h2mux.MuxedStream) error {
+	if !stream.IsRPCStream() {
+		stream.WriteHeaders([]h2mux.Header{{Name: ":status", Value: strconv.Itoa(http.StatusBadRequest)}})
+		return fmt.Errorf("cloudflared opened a stream that isn't for RPC")
+	}
+	stream.WriteHeaders([]h2mux.Header{{Name: ":status", Value: "200"}})
+	main := pogs.TunnelServer_ServerToClient(tc)
+	rpcLogger := tc.edge.logger.WithField("subsystem", "tunnelserver-rpc")
+	rpcConn := rpc.NewConn(
+		tunnelrpc.NewTransportLogger(rpcLogger, rpc.StreamTransport(stream)),
+		rpc.MainInterface(main.Client),
+		tunnelrpc.ConnLog(rpcLogger),
+	)
+	return rpcConn.Wait()
+}
+
+// RegisterTunnel implements pogs.TunnelServer
+func (tc *tunnelConn) RegisterTunnel(ctx context.Context, originCert []byte, hostname string, options *pogs.RegistrationOptions) (*pogs.TunnelRegistration, error) {
+	if hostname == "" {
+		hostname = tc.edge.trialHostname()
+	}
+	addr, err := tc.edge.Expose(hostname)
+	if err != nil {
+		return &pogs.TunnelRegistration{Err: err.Error()}, nil
+	}
+	tc.Lock()
+	tc.hostname = hostname
+	tc.unregistered = false
+	tc.Unlock()
+	tunnelID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	return &pogs.TunnelRegistration{
+		Url:      "https://" + hostname,
+		TunnelID: tunnelID.String(),
+		LogLines: []string{fmt.Sprintf("%s is exposed on http://%s by the edge emulator", hostname, addr)},
+	}, nil
+}
+
+// GetServerInfo implements pogs.TunnelServer
+func (tc *tunnelConn) GetServerInfo(ctx context.Context) (*pogs.ServerInfo, error) {
+	return &pogs.ServerInfo{LocationName: tc.edge.config.LocationName}, nil
+}
+
+// UnregisterTunnel implements pogs.TunnelServer. No new requests are sent to the connection after it unregisters.
+func (tc *tunnelConn) UnregisterTunnel(ctx context.Context, gracePeriodNanoSec int64) error {
+	tc.Lock()
+	defer tc.Unlock()
+	tc.unregistered = true
+	return nil
+}
+
+// Connect implements pogs.TunnelServer
+func (tc *tunnelConn) Connect(ctx context.Context, parameters *pogs.ConnectParameters) (*pogs.ConnectResult, error) {
+	config := tc.edge.config
+	if config.ConnectError != nil {
+		return &pogs.ConnectResult{Err: config.ConnectError}, nil
+	}
+	tc.Lock()
+	tc.declarative = true
+	tc.unregistered = false
+	tc.Unlock()
+	result := &pogs.ConnectResult{
+		ServerInfo: pogs.ServerInfo{LocationName: config.LocationName},
+	}
+	if config.ClientConfig != nil {
+		result.ClientConfig = *config.ClientConfig
+	}
+	return result, nil
+}
+
+func (tc *tunnelConn) isServing() bool {
+	tc.RLock()
+	defer tc.RUnlock()
+	return !tc.unregistered && (tc.declarative || tc.hostname != "")
+}
+
+func (tc *tunnelConn) isDeclarative() bool {
+	tc.RLock()
+	defer tc.RUnlock()
+	return tc.declarative
+}
+
+func (tc *tunnelConn) serves(hostname string) bool {
+	tc.RLock()
+	defer tc.RUnlock()
+	return !tc.unregistered && (tc.declarative || tc.hostname == hostname)
+}
+
+// useConfiguration makes the UseConfiguration RPC of cloudflared's ClientService
+func (tc *tunnelConn) useConfiguration(ctx context.Context, config *pogs.ClientConfig) (*pogs.UseConfigurationResult, error) {
+	openStreamCtx, cancel := context.WithTimeout(ctx, openStreamTimeout)
+	defer cancel()
+	stream, err := tc.muxer.OpenRPCStream(openStreamCtx)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open RPC stream")
+	}
+	rpcLogger := tc.edge.logger.WithField("subsystem", "clientservice-rpc")
+	rpcConn := rpc.NewConn(
+		tunnelrpc.NewTransportLogger(rpcLogger, rpc.StreamTransport(stream)),
+		tunnelrpc.ConnLog(rpcLogger),
+	)
+	defer rpcConn.Close()
+	client := pogs.ClientService_PogsClient{Client: rpcConn.Bootstrap(ctx), Conn: rpcConn}
+	return client.UseConfiguration(ctx, config)
+}
+
+// requestForwarder forwards the requests to an exposed hostname to a tunnel connection that serves it
+type requestForwarder struct {
+	edge     *Edge
+	hostname string
+}
+
+func (rf *requestForwarder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn := rf.edge.connForHostname(rf.hostname)
+	if conn == nil {
+		http.Error(w, fmt.Sprintf("no tunnel connection serves %s", rf.hostname), http.StatusBadGateway)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), openStreamTimeout)
+	defer cancel()
+	stream, err := conn.muxer.OpenStream(ctx, rf.requestHeaders(r, conn.isDeclarative()), r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	status := http.StatusOK
+	for _, header := range stream.Headers {
+		if header.Name == ":status" {
+			if status, err = strconv.Atoi(header.Value); err != nil {
+				http.Error(w, "tunnel responded with an invalid status", http.StatusBadGateway)
+				return
+			}
+			continue
+		}
+		w.Header().Add(http.CanonicalHeaderKey(header.Name), header.Value)
+	}
+	w.WriteHeader(status)
+	io.Copy(w, stream)
+}
+
+// requestHeaders converts r to the headers of a stream. Declarative tunnels are also told which hostname the
+// request is for.
+func (rf *requestForwarder) requestHeaders(r *http.Request, declarative bool) []h2mux.Header {
+	headers := []h2mux.Header{
+		{Name: ":method", Value: r.Method},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: rf.hostname},
+		{Name: ":path", Value: r.URL.RequestURI()},
+	}
+	if declarative {
+		headers = append(headers, h2mux.Header{Name: h2mux.CloudflaredProxyTunnelHostnameHeader, Value: rf.hostname})
+	}
+	if r.ContentLength > 0 {
+		headers = append(headers, h2mux.Header{Name: "content-length", Value: strconv.FormatInt(r.ContentLength, 10)})
+	}
+	for name, values := range r.Header {
+		for _, value := range values {
+			headers = append(headers, h2mux.Header{Name: strings.ToLower(name), Value: value})
+		}
+	}
+	// The edge identifies every request with a CF-RAY, whose suffix is the location that handled it
+	if location := rf.edge.config.LocationName; location != "" {
+		rayID, _ := uuid.NewRandom()
+		headers = append(headers, h2mux.Header{Name: "cf-ray", Value: fmt.Sprintf("%x-%s", rayID[:8], location)})
+	}
+	return headers
+}
@@ -23,16 +23,21 @@
 //			log.Fatal(err)
 //		}
 //		fmt.Println(string(data))
+//
+// Keys are saved with 0600 permissions. NewEphemeral returns an encrypter whose keys are never saved, for one-shot
+// exchanges.
 package encrypter
 
 import (
-	"bytes"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"golang.org/x/crypto/nacl/box"
 )
@@ -44,7 +49,11 @@ type Encrypter struct {
 	publicKey  *[32]byte
 }
 
-// New returns a new encrypter with initialized keypair
+// keySize is the size of box keys in bytes
+const keySize = 32
+
+// New returns a new encrypter with the keypair loaded from the provided filenames. If either file doesn't exist, a
+// new keypair is generated and written to them.
 func New(privateKey, publicKey string) (*Encrypter, error) {
 	e := &Encrypter{}
 	pubKey, key, err := e.fetchOrGenerateKeys(privateKey, publicKey)
@@ -55,6 +64,15 @@ func New(privateKey, publicKey string) (*Encrypter, error) {
 	return e, nil
 }
 
+// NewEphemeral returns a new encrypter with a generated keypair that is only kept in memory
+func NewEphemeral() (*Encrypter, error) {
+	pubKey, key, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Encrypter{privateKey: key, publicKey: pubKey}, nil
+}
+
 // PublicKey returns a base64 encoded public key. Useful for transport (like in HTTP requests)
 func (e *Encrypter) PublicKey() string {
 	return base64.URLEncoding.EncodeToString(e.publicKey[:])
@@ -99,7 +117,8 @@ func (e *Encrypter) Encrypt(data []byte, recipientPublicKey string) ([]byte, err
 	return box.Seal(nonce[:], data, &nonce, pubKey, e.privateKey), nil
 }
 
-// WriteKeys keys will take the currently initialized keypair and write them to provided filenames
+// WriteKeys keys will take the currently initialized keypair and write them to provided filenames.
+// Each file is replaced atomically and only readable by the user.
 func (e *Encrypter) WriteKeys(privateKey, publicKey string) error {
 	if err := e.writeKey(e.privateKey[:], "BOX PRIVATE KEY", privateKey); err != nil {
 		return err
@@ -107,57 +126,81 @@ func (e *Encrypter) WriteKeys(privateKey, publicKey string) error {
 	return e.writeKey(e.publicKey[:], "PUBLIC KEY", publicKey)
 }
 
-// fetchOrGenerateKeys will either load or create a keypair if it doesn't exist
+// fetchOrGenerateKeys will either load or create a keypair if it doesn't exist. A created keypair is written to
+// the provided filenames.
 func (e *Encrypter) fetchOrGenerateKeys(privateKey, publicKey string) (*[32]byte, *[32]byte, error) {
 	key, err := e.fetchKey(privateKey)
 	if os.IsNotExist(err) {
-		return box.GenerateKey(rand.Reader)
+		return e.generateKeys(privateKey, publicKey)
 	} else if err != nil {
 		return nil, nil, err
 	}
 
 	pub, err := e.fetchKey(publicKey)
 	if os.IsNotExist(err) {
-		return box.GenerateKey(rand.Reader)
+		return e.generateKeys(privateKey, publicKey)
 	} else if err != nil {
 		return nil, nil, err
 	}
 	return pub, key, nil
 }
 
-// writeKey will write a key to disk in DER format (it's a standard pem key)
+// generateKeys will create a keypair and write it to the provided filenames
+func (e *Encrypter) generateKeys(privateKey, publicKey string) (*[32]byte, *[32]byte, error) {
+	pub, key, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	generated := &Encrypter{privateKey: key, publicKey: pub}
+	if err := generated.WriteKeys(privateKey, publicKey); err != nil {
+		return nil, nil, err
+	}
+	return pub, key, nil
+}
+
+// writeKey will write a key to disk in DER format (it's a standard pem key). The key is written to a temporary
+// file that is renamed to filename, so a partially written key is never loaded.
 func (e *Encrypter) writeKey(key []byte, pemType, filename string) error {
 	data := pem.EncodeToMemory(&pem.Block{
 		Type:  pemType,
 		Bytes: key,
 	})
 
-	f, err := os.Create(filename)
+	// TempFile creates the file with 0600 permissions
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(f.Name())
 
-	_, err = f.Write(data)
-	if err != nil {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
 		return err
 	}
-
-	return nil
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
 }
 
 // fetchKey will load a a DER formatted key from disk
 func (e *Encrypter) fetchKey(filename string) (*[32]byte, error) {
-	f, err := os.Open(filename)
+	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	buf := new(bytes.Buffer)
-	io.Copy(buf, f)
 
-	p, _ := pem.Decode(buf.Bytes())
+	p, _ := pem.Decode(data)
 	if p == nil {
 		return nil, errors.New("Failed to decode key")
 	}
+	if len(p.Bytes) != keySize {
+		return nil, fmt.Errorf("key in %s is %d bytes instead of %d", filename, len(p.Bytes), keySize)
+	}
 	var newKey [32]byte
 	copy(newKey[:], p.Bytes)
 
@@ -170,6 +213,9 @@ func (e *Encrypter) decodePublicKey(key string) (*[32]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(pub) != keySize {
+		return nil, fmt.Errorf("public key is %d bytes instead of %d", len(pub), keySize)
+	}
 	var newKey [32]byte
 	copy(newKey[:], pub)
 	return &newKey, nil
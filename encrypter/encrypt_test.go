@@ -0,0 +1,71 @@
+package encrypter
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempKeyFiles(t *testing.T) (string, string, func()) {
+	dir, err := ioutil.TempDir("", "encrypter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "priv.pem"), filepath.Join(dir, "pub.pem"), func() { os.RemoveAll(dir) }
+}
+
+func TestNewWritesGeneratedKeys(t *testing.T) {
+	privateKey, publicKey, cleanup := tempKeyFiles(t)
+	defer cleanup()
+
+	generated, err := New(privateKey, publicKey)
+	assert.NoError(t, err)
+	for _, filename := range []string{privateKey, publicKey} {
+		info, err := os.Stat(filename)
+		if assert.NoError(t, err) {
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		}
+	}
+	// No temporary files are left behind
+	files, err := ioutil.ReadDir(filepath.Dir(privateKey))
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	loaded, err := New(privateKey, publicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, generated.PublicKey(), loaded.PublicKey())
+	assert.Equal(t, generated.privateKey, loaded.privateKey)
+}
+
+func TestNewRejectsInvalidKeyLength(t *testing.T) {
+	privateKey, publicKey, cleanup := tempKeyFiles(t)
+	defer cleanup()
+
+	_, err := New(privateKey, publicKey)
+	assert.NoError(t, err)
+	truncated := pem.EncodeToMemory(&pem.Block{Type: "BOX PRIVATE KEY", Bytes: make([]byte, 16)})
+	assert.NoError(t, ioutil.WriteFile(privateKey, truncated, 0600))
+	_, err = New(privateKey, publicKey)
+	assert.Error(t, err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	alice, err := NewEphemeral()
+	assert.NoError(t, err)
+	bob, err := NewEphemeral()
+	assert.NoError(t, err)
+	assert.NotEqual(t, alice.PublicKey(), bob.PublicKey())
+
+	encrypted, err := alice.Encrypt([]byte("super safe message."), bob.PublicKey())
+	assert.NoError(t, err)
+	data, err := bob.Decrypt(encrypted, alice.PublicKey())
+	assert.NoError(t, err)
+	assert.Equal(t, "super safe message.", string(data))
+
+	_, err = alice.Encrypt([]byte("super safe message."), "c2hvcnQ=")
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package h2mux
 
 import (
+	"context"
 	"sync"
 
 	"golang.org/x/net/http2"
@@ -19,16 +20,32 @@ type activeStreamMap struct {
 	nextStreamID uint32
 	// maxPeerStreamID is the ID of the most recent stream opened by the peer.
 	maxPeerStreamID uint32
+	// lastAcceptedPeerStreamID is the ID of the most recent stream opened by the peer that wasn't refused.
+	lastAcceptedPeerStreamID uint32
 	// ignoreNewStreams is true when the connection is being shut down. New streams
 	// cannot be registered.
 	ignoreNewStreams bool
+	// maxPeerStreams is how many streams the peer can have open at once. 0 means no limit.
+	maxPeerStreams uint32
+	// maxLocalStreams is how many streams we can have open at once, as advertised by the peer. 0 means no limit.
+	maxLocalStreams uint32
+	// peerStreams is how many streams opened by the peer are open.
+	peerStreams uint32
+	// localStreams is how many streams opened by us are open or being opened.
+	localStreams uint32
+	// localStreamClosed is closed when a local stream closes or new streams are denied, to wake up
+	// AcquireLocalStream. It's replaced with a new channel every time.
+	localStreamClosed chan struct{}
+	// rejectedPeerStreams is how many streams opened by the peer were refused because too many were open.
+	rejectedPeerStreams uint64
 }
 
 func newActiveStreamMap(useClientStreamNumbers bool) *activeStreamMap {
 	m := &activeStreamMap{
-		streams:      make(map[uint32]*MuxedStream),
-		streamsEmpty: make(chan struct{}),
-		nextStreamID: 1,
+		streams:           make(map[uint32]*MuxedStream),
+		streamsEmpty:      make(chan struct{}),
+		nextStreamID:      1,
+		localStreamClosed: make(chan struct{}),
 	}
 	// Client initiated stream uses odd stream ID, server initiated stream uses even stream ID
 	if !useClientStreamNumbers {
@@ -63,6 +80,10 @@ func (m *activeStreamMap) Set(newStream *MuxedStream) bool {
 		return false
 	}
 	m.streams[newStream.streamID] = newStream
+	// local streams are counted by AcquireLocalStream
+	if m.isPeerStreamID(newStream.streamID) {
+		m.peerStreams++
+	}
 	return true
 }
 
@@ -70,7 +91,15 @@ func (m *activeStreamMap) Set(newStream *MuxedStream) bool {
 func (m *activeStreamMap) Delete(streamID uint32) {
 	m.Lock()
 	defer m.Unlock()
+	if _, ok := m.streams[streamID]; !ok {
+		return
+	}
 	delete(m.streams, streamID)
+	if m.isPeerStreamID(streamID) {
+		m.peerStreams--
+	} else {
+		m.releaseLocalStream()
+	}
 	if len(m.streams) == 0 && m.streamsEmpty != nil {
 		close(m.streamsEmpty)
 		m.streamsEmpty = nil
@@ -87,6 +116,7 @@ func (m *activeStreamMap) Shutdown() <-chan struct{} {
 		return nil
 	}
 	m.ignoreNewStreams = true
+	m.notifyLocalStreamClosed()
 	done := make(chan struct{})
 	if len(m.streams) == 0 {
 		// nothing to shut down
@@ -106,6 +136,51 @@ func (m *activeStreamMap) AcquireLocalID() uint32 {
 	return x
 }
 
+// AcquireLocalStream blocks until a stream can be opened without exceeding the peer's limit of concurrent
+// streams, then reserves it. Call ReleaseLocalStream if the stream isn't registered with Set after all.
+func (m *activeStreamMap) AcquireLocalStream(ctx context.Context) error {
+	for {
+		m.Lock()
+		if m.ignoreNewStreams {
+			m.Unlock()
+			return ErrStreamRequestConnectionClosed
+		}
+		if m.maxLocalStreams == 0 || m.localStreams < m.maxLocalStreams {
+			m.localStreams++
+			m.Unlock()
+			return nil
+		}
+		localStreamClosed := m.localStreamClosed
+		m.Unlock()
+		select {
+		case <-ctx.Done():
+			return ErrStreamRequestTimeout
+		case <-localStreamClosed:
+		}
+	}
+}
+
+// ReleaseLocalStream releases a stream reserved by AcquireLocalStream that wasn't registered with Set.
+func (m *activeStreamMap) ReleaseLocalStream() {
+	m.Lock()
+	defer m.Unlock()
+	m.releaseLocalStream()
+}
+
+// releaseLocalStream must happen while holding the lock.
+func (m *activeStreamMap) releaseLocalStream() {
+	if m.localStreams > 0 {
+		m.localStreams--
+	}
+	m.notifyLocalStreamClosed()
+}
+
+// notifyLocalStreamClosed wakes up AcquireLocalStream. It must happen while holding the lock.
+func (m *activeStreamMap) notifyLocalStreamClosed() {
+	close(m.localStreamClosed)
+	m.localStreamClosed = make(chan struct{})
+}
+
 // ObservePeerID observes the ID of a stream opened by the peer. It returns true if we should accept
 // the new stream, or false to reject it. The ErrCode gives the reason why.
 func (m *activeStreamMap) AcquirePeerID(streamID uint32) (bool, http2.ErrCode) {
@@ -113,9 +188,15 @@ func (m *activeStreamMap) AcquirePeerID(streamID uint32) (bool, http2.ErrCode) {
 	defer m.Unlock()
 	switch {
 	case m.ignoreNewStreams:
-		return false, http2.ErrCodeStreamClosed
+		// the stream wasn't processed, so the peer can retry it on another connection
+		return false, http2.ErrCodeRefusedStream
 	case streamID > m.maxPeerStreamID:
 		m.maxPeerStreamID = streamID
+		if m.maxPeerStreams > 0 && m.peerStreams >= m.maxPeerStreams {
+			m.rejectedPeerStreams++
+			return false, http2.ErrCodeRefusedStream
+		}
+		m.lastAcceptedPeerStreamID = streamID
 		return true, http2.ErrCodeNo
 	default:
 		return false, http2.ErrCodeStreamClosed
@@ -126,6 +207,11 @@ func (m *activeStreamMap) AcquirePeerID(streamID uint32) (bool, http2.ErrCode) {
 func (m *activeStreamMap) IsPeerStreamID(streamID uint32) bool {
 	m.RLock()
 	defer m.RUnlock()
+	return m.isPeerStreamID(streamID)
+}
+
+// isPeerStreamID must happen while holding the lock.
+func (m *activeStreamMap) isPeerStreamID(streamID uint32) bool {
 	return (streamID % 2) != (m.nextStreamID % 2)
 }
 
@@ -143,6 +229,14 @@ func (m *activeStreamMap) LastPeerStreamID() uint32 {
 	return m.maxPeerStreamID
 }
 
+// LastAcceptedPeerStreamID returns the most recently opened peer stream ID that wasn't refused. It's sent in
+// GOAWAY frames, so the peer knows which streams it can retry.
+func (m *activeStreamMap) LastAcceptedPeerStreamID() uint32 {
+	m.RLock()
+	defer m.RUnlock()
+	return m.lastAcceptedPeerStreamID
+}
+
 // LastLocalStreamID returns the most recently opened local stream ID.
 func (m *activeStreamMap) LastLocalStreamID() uint32 {
 	m.RLock()
@@ -160,6 +254,16 @@ func (m *activeStreamMap) Abort() {
 	defer m.Unlock()
 	for _, stream := range m.streams {
 		stream.Close()
+		stream.cancel()
 	}
 	m.ignoreNewStreams = true
+	m.notifyLocalStreamClosed()
+}
+
+// StreamCounts returns how many streams are open, and how many streams opened by the peer were refused because
+// too many were open.
+func (m *activeStreamMap) StreamCounts() (active int, rejected uint64) {
+	m.RLock()
+	defer m.RUnlock()
+	return len(m.streams), m.rejectedPeerStreams
 }
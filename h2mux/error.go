@@ -26,8 +26,11 @@ var (
 	ErrStreamRequestTimeout            = MuxerApplicationError{"3003 open stream timeout"}
 	ErrResponseHeadersTimeout          = MuxerApplicationError{"3004 timeout waiting for initial response headers"}
 	ErrResponseHeadersConnectionClosed = MuxerApplicationError{"3005 connection closed while waiting for initial response headers"}
+	ErrStreamReset                     = MuxerApplicationError{"3006 stream reset"}
 
 	ErrClosedStream = MuxerStreamError{"4000 stream closed", http2.ErrCodeStreamClosed}
+
+	ErrStreamTimeout = MuxerTimeoutError{"5000 stream deadline exceeded"}
 )
 
 type MuxerHandshakeError struct {
@@ -55,6 +58,24 @@ func (e MuxerApplicationError) Error() string {
 	return fmt.Sprintf("Application error: %s", e.cause)
 }
 
+// MuxerTimeoutError is returned by reads and writes of a stream after its deadline. It implements net.Error, so
+// callers can tell it apart from other errors with Timeout().
+type MuxerTimeoutError struct {
+	cause string
+}
+
+func (e MuxerTimeoutError) Error() string {
+	return fmt.Sprintf("Timeout error: %s", e.cause)
+}
+
+func (e MuxerTimeoutError) Timeout() bool {
+	return true
+}
+
+func (e MuxerTimeoutError) Temporary() bool {
+	return true
+}
+
 type MuxerStreamError struct {
 	cause  string
 	h2code http2.ErrCode
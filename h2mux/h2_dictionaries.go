@@ -295,6 +295,19 @@ func (r *h2DictionaryReader) Write(p []byte) (n int, err error) {
 	return
 }
 
+// CloseWithError makes reads fail with err, unless Close was called already. The decompressed output might not have
+// been written to the buffer yet in that case.
+func (r *h2DictionaryReader) CloseWithError(err error) {
+	if r.isClosed {
+		return
+	}
+	r.isClosed = true
+	r.SharedBuffer.CloseWithError(err)
+	if r.decomp != nil {
+		r.decomp.Close()
+	}
+}
+
 func (r *h2DictionaryReader) Close() error {
 	if r.isClosed {
 		return nil
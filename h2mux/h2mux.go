@@ -3,6 +3,7 @@ package h2mux
 import (
 	"context"
 	"io"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -55,6 +56,9 @@ type MuxerConfig struct {
 	MaxWindowSize uint32
 	// Largest allowable capacity for the buffer of data to be sent
 	StreamWriteBufferMaxLen int
+	// Maximum number of streams the peer can have open at once, advertised in SETTINGS_MAX_CONCURRENT_STREAMS.
+	// More streams are refused with REFUSED_STREAM. 0 means no limit.
+	MaxConcurrentStreams uint32
 }
 
 type Muxer struct {
@@ -132,6 +136,7 @@ func Handshake(
 		readyList:     NewReadyList(),
 		streams:       newActiveStreamMap(config.IsClient),
 	}
+	m.streams.maxPeerStreams = config.MaxConcurrentStreams
 
 	m.f.ReadMetaHeaders = hpack.NewDecoder(4096, func(hpack.HeaderField) {})
 	// Initialise the settings to identify this connection and confirm the other end is sane.
@@ -150,9 +155,14 @@ func Handshake(
 		handshakeSetting.Val = MuxerMagicOrigin
 		expectedMagic = MuxerMagicEdge
 	}
+	settings := []http2.Setting{handshakeSetting, compressionSetting}
+	if config.MaxConcurrentStreams > 0 {
+		settings = append(settings, http2.Setting{ID: http2.SettingMaxConcurrentStreams, Val: config.MaxConcurrentStreams})
+	}
+
 	errChan := make(chan error, 2)
 	// Simultaneously send our settings and verify the peer's settings.
-	go func() { errChan <- m.f.WriteSettings(handshakeSetting, compressionSetting) }()
+	go func() { errChan <- m.f.WriteSettings(settings...) }()
 	go func() { errChan <- m.readPeerSettings(expectedMagic) }()
 	err := joinErrorsWithTimeout(errChan, 2, config.Timeout, ErrHandshakeTimeout)
 	if err != nil {
@@ -187,6 +197,7 @@ func Handshake(
 	}
 
 	compBytesBefore, compBytesAfter := NewAtomicCounter(0), NewAtomicCounter(0)
+	localAddr, remoteAddr := connAddrs(r)
 
 	m.muxMetricsUpdater = newMuxMetricsUpdater(
 		m.abortChan,
@@ -211,6 +222,8 @@ func Handshake(
 		r:                       m.r,
 		metricsUpdater:          m.muxMetricsUpdater,
 		bytesRead:               inBoundCounter,
+		localAddr:               localAddr,
+		remoteAddr:              remoteAddr,
 	}
 	m.muxWriter = &MuxWriter{
 		f:               m.f,
@@ -265,6 +278,9 @@ func (m *Muxer) readPeerSettings(magic uint32) error {
 	if magic != peerMagic {
 		return ErrBadHandshakeWrongMagic
 	}
+	if maxConcurrentStreams, ok := settingsFrame.Value(http2.SettingMaxConcurrentStreams); ok {
+		m.streams.maxLocalStreams = maxConcurrentStreams
+	}
 	peerCompression, ok := settingsFrame.Value(SettingCompression)
 	if !ok {
 		m.compressionQuality = compressionPresets[CompressionNone]
@@ -356,6 +372,27 @@ func (m *Muxer) Shutdown() {
 	m.muxReader.Shutdown()
 }
 
+// Drain gracefully closes the connection. It sends GOAWAY with NO_ERROR and the ID of the last stream accepted
+// from the peer, refuses new streams, and waits for the active streams to finish. If ctx is done first, the
+// remaining streams are aborted and ctx.Err() is returned. If the connection fails before the active streams
+// finish, ErrConnectionDropped is returned. The connection is closed when Drain returns.
+func (m *Muxer) Drain(ctx context.Context) error {
+	m.Shutdown()
+	select {
+	case <-m.abortChan:
+		// explicitShutdown isn't set if the connection had already failed when Drain was called. Streams are only
+		// left when the connection failed while they were drained.
+		if !m.explicitShutdown.Value() || m.streams.Len() > 0 {
+			return ErrConnectionDropped
+		}
+		return nil
+	case <-ctx.Done():
+		m.abort()
+		m.r.Close()
+		return ctx.Err()
+	}
+}
+
 // IsUnexpectedTunnelError identifies errors that are expected when shutting down the h2mux tunnel.
 // The set of expected errors change depending on whether we initiated shutdown or not.
 func isUnexpectedTunnelError(err error, expectedShutdown bool) bool {
@@ -384,6 +421,15 @@ func isConnectionClosedError(err error) bool {
 	return false
 }
 
+// connAddrs returns the addresses of the connection the muxer reads from, or unknown addresses if it isn't a
+// net.Conn.
+func connAddrs(r io.Reader) (local, remote net.Addr) {
+	if conn, ok := r.(net.Conn); ok {
+		return conn.LocalAddr(), conn.RemoteAddr()
+	}
+	return unknownAddr{}, unknownAddr{}
+}
+
 // OpenStream opens a new data stream with the given headers.
 // Called by proxy server and tunnel
 func (m *Muxer) OpenStream(ctx context.Context, headers []Header, body io.Reader) (*MuxedStream, error) {
@@ -398,8 +444,10 @@ func (m *Muxer) OpenStream(ctx context.Context, headers []Header, body io.Reader
 }
 
 
+// OpenRPCStream opens a stream for RPC. Its data is written before the data of all other streams.
 func (m *Muxer) OpenRPCStream(ctx context.Context) (*MuxedStream, error) {
 	stream := m.NewStream(RPCHeaders())
+	stream.urgent = true
 	if err := m.MakeMuxedStreamRequest(ctx, MuxedStreamRequest{stream: stream, body: nil}); err != nil {
 		return nil, err
 	}
@@ -410,14 +458,24 @@ func (m *Muxer) OpenRPCStream(ctx context.Context) (*MuxedStream, error) {
 }
 
 func (m *Muxer) NewStream(headers []Header) *MuxedStream {
-	return NewStream(m.config, headers, m.readyList, m.muxReader.dictionaries)
+	stream := NewStream(m.config, headers, m.readyList, m.muxReader.dictionaries)
+	stream.streamErrors = m.muxReader.streamErrors
+	stream.localAddr, stream.remoteAddr = m.muxReader.localAddr, m.muxReader.remoteAddr
+	return stream
 }
 
+// MakeMuxedStreamRequest blocks until the peer's limit of concurrent streams allows another stream, then
+// asks the writer to open the stream.
 func (m *Muxer) MakeMuxedStreamRequest(ctx context.Context, request MuxedStreamRequest) error {
+	if err := m.streams.AcquireLocalStream(ctx); err != nil {
+		return err
+	}
 	select {
 	case <-ctx.Done():
+		m.streams.ReleaseLocalStream()
 		return ErrStreamRequestTimeout
 	case <-m.abortChan:
+		m.streams.ReleaseLocalStream()
 		return ErrStreamRequestConnectionClosed
 	// Will be received by mux writer
 	case m.newStreamChan <- request:
@@ -431,13 +489,26 @@ func (m *Muxer) AwaitResponseHeaders(ctx context.Context, stream *MuxedStream) e
 		return ErrResponseHeadersTimeout
 	case <-m.abortChan:
 		return ErrResponseHeadersConnectionClosed
+	case <-stream.ctx.Done():
+		// The peer may reset the stream right after responding, and the context of the stream is also cancelled
+		// when the muxer is aborted
+		select {
+		case <-stream.responseHeadersReceived:
+			return nil
+		case <-m.abortChan:
+			return ErrResponseHeadersConnectionClosed
+		default:
+			return ErrStreamReset
+		}
 	case <-stream.responseHeadersReceived:
 		return nil
 	}
 }
 
 func (m *Muxer) Metrics() *MuxerMetrics {
-	return m.muxMetricsUpdater.metrics()
+	metrics := m.muxMetricsUpdater.metrics()
+	metrics.ActiveStreams, metrics.RejectedStreams = m.streams.StreamCounts()
+	return metrics
 }
 
 func (m *Muxer) abort() {
@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +19,7 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -44,8 +46,16 @@ type DefaultMuxerPair struct {
 }
 
 func NewDefaultMuxerPair(t assert.TestingT, f MuxedStreamFunc) *DefaultMuxerPair {
+	p := newDefaultMuxerPairConfig(f)
+	assert.NoError(t, p.Handshake())
+	return p
+}
+
+// newDefaultMuxerPairConfig returns the muxer pair of NewDefaultMuxerPair before the handshake, so tests can
+// change the configs
+func newDefaultMuxerPairConfig(f MuxedStreamFunc) *DefaultMuxerPair {
 	origin, edge := net.Pipe()
-	p := &DefaultMuxerPair{
+	return &DefaultMuxerPair{
 		OriginMuxConfig: MuxerConfig{
 			Timeout:                 testHandshakeTimeout,
 			Handler:                 f,
@@ -69,8 +79,6 @@ func NewDefaultMuxerPair(t assert.TestingT, f MuxedStreamFunc) *DefaultMuxerPair
 		EdgeConn: edge,
 		doneC:    make(chan struct{}),
 	}
-	assert.NoError(t, p.Handshake())
-	return p
 }
 
 func NewCompressedMuxerPair(t assert.TestingT, quality CompressionSetting, f MuxedStreamFunc) *DefaultMuxerPair {
@@ -439,6 +447,60 @@ func TestMultipleStreamsFlowControl(t *testing.T) {
 	assert.NoError(t, errGroup.Wait())
 }
 
+func TestWeightedStreamsShareWriter(t *testing.T) {
+	// The writer only chooses between streams that are ready. With a single P, the handlers refill their write
+	// buffers before the writer picks the next stream, so both streams are always ready.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	const bodySize = 256 * 1024
+	var handlersReady sync.WaitGroup
+	handlersReady.Add(2)
+	f := MuxedStreamFunc(func(stream *MuxedStream) error {
+		if stream.Headers[0].Value == "heavy" {
+			stream.SetWeight(MaxStreamWeight)
+		}
+		stream.WriteHeaders([]Header{{Name: "response-token", Value: stream.Headers[0].Value}})
+		// Both streams compete for the writer from the start
+		handlersReady.Done()
+		handlersReady.Wait()
+		chunk := make([]byte, 1024)
+		for written := 0; written < bodySize; written += len(chunk) {
+			if _, err := stream.Write(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	muxPair := NewDefaultMuxerPair(t, f)
+	muxPair.Serve(t)
+
+	heavyStream, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "client-token", Value: "heavy"}}, nil)
+	assert.NoError(t, err)
+	lightStream, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "client-token", Value: "light"}}, nil)
+	assert.NoError(t, err)
+
+	var lightReadLock sync.Mutex
+	lightRead := 0
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := lightStream.Read(buf)
+			lightReadLock.Lock()
+			lightRead += n
+			lightReadLock.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	_, err = io.CopyN(ioutil.Discard, heavyStream, bodySize)
+	assert.NoError(t, err)
+
+	// The heavy stream is written 16 times as often as the light stream, which has a default weight
+	lightReadLock.Lock()
+	defer lightReadLock.Unlock()
+	assert.True(t, lightRead < bodySize/4, "light stream read %d bytes while the heavy stream read %d", lightRead, bodySize)
+}
+
 func TestGracefulShutdown(t *testing.T) {
 	sendC := make(chan struct{})
 	responseBuf := bytes.Repeat([]byte("Hello world"), 65536)
@@ -545,6 +607,300 @@ func TestUnexpectedShutdown(t *testing.T) {
 	<-handlerFinishC
 }
 
+func TestMaxConcurrentStreams(t *testing.T) {
+	// The handler keeps the stream open until the edge closes its end
+	f := MuxedStreamFunc(func(stream *MuxedStream) error {
+		stream.WriteHeaders([]Header{{Name: "response-header", Value: "responseValue"}})
+		stream.Read([]byte{0})
+		return nil
+	})
+	muxPair := newDefaultMuxerPairConfig(f)
+	muxPair.OriginMuxConfig.MaxConcurrentStreams = 2
+	assert.NoError(t, muxPair.Handshake())
+	muxPair.Serve(t)
+
+	streams := make([]*MuxedStream, 2)
+	for i := range streams {
+		stream, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "test-header", Value: "headerValue"}}, nil)
+		if err != nil {
+			t.Fatalf("error in OpenStream: %s", err)
+		}
+		streams[i] = stream
+	}
+
+	// The edge waits for a stream to close before opening another one
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := muxPair.EdgeMux.OpenStream(ctx, []Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	assert.Equal(t, ErrStreamRequestTimeout, err)
+
+	openedC := make(chan error, 1)
+	go func() {
+		_, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "test-header", Value: "headerValue"}}, nil)
+		openedC <- err
+	}()
+	select {
+	case err := <-openedC:
+		t.Fatalf("stream opened while 2 streams were open, err: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	streams[0].Close()
+	select {
+	case err := <-openedC:
+		assert.NoError(t, err)
+	case <-time.After(testOpenStreamTimeout):
+		t.Fatal("stream wasn't opened after a stream closed")
+	}
+
+	// A peer that doesn't respect the limit gets REFUSED_STREAM
+	muxPair.EdgeMux.streams.Lock()
+	muxPair.EdgeMux.streams.maxLocalStreams = 0
+	muxPair.EdgeMux.streams.Unlock()
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = muxPair.EdgeMux.OpenStream(ctx, []Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	assert.Error(t, err)
+	metrics := muxPair.OriginMux.Metrics()
+	assert.Equal(t, 2, metrics.ActiveStreams)
+	assert.Equal(t, uint64(1), metrics.RejectedStreams)
+}
+
+func TestDrain(t *testing.T) {
+	sendC := make(chan struct{})
+	f := MuxedStreamFunc(func(stream *MuxedStream) error {
+		stream.WriteHeaders([]Header{{Name: "response-header", Value: "responseValue"}})
+		<-sendC
+		stream.Write([]byte("response"))
+		return nil
+	})
+	muxPair := NewDefaultMuxerPair(t, f)
+	muxPair.Serve(t)
+
+	stream, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	if err != nil {
+		t.Fatalf("error in OpenStream: %s", err)
+	}
+	drainErrC := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+		defer cancel()
+		drainErrC <- muxPair.OriginMux.Drain(ctx)
+	}()
+
+	// Neither side accepts new streams once the edge has received GOAWAY
+	assert.Equal(t, ErrStreamRequestConnectionClosed, waitForOpenStreamError(t, muxPair.EdgeMux))
+	_, err = muxPair.OriginMux.OpenStream(context.Background(), []Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	assert.Equal(t, ErrStreamRequestConnectionClosed, err)
+	select {
+	case err := <-drainErrC:
+		t.Fatalf("Drain returned before the active stream finished, err: %v", err)
+	default:
+	}
+
+	// The active stream still finishes
+	close(sendC)
+	response, err := ioutil.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, "response", string(response))
+	stream.Close()
+	assert.NoError(t, <-drainErrC)
+	muxPair.Wait(t)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	handlerDoneC := make(chan struct{})
+	defer close(handlerDoneC)
+	f := MuxedStreamFunc(func(stream *MuxedStream) error {
+		stream.WriteHeaders([]Header{{Name: "response-header", Value: "responseValue"}})
+		<-handlerDoneC
+		return nil
+	})
+	muxPair := NewDefaultMuxerPair(t, f)
+	muxPair.Serve(t)
+
+	_, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	if err != nil {
+		t.Fatalf("error in OpenStream: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, muxPair.OriginMux.Drain(ctx))
+	muxPair.Wait(t)
+}
+
+func TestDrainConnectionDropped(t *testing.T) {
+	handlerDoneC := make(chan struct{})
+	defer close(handlerDoneC)
+	f := MuxedStreamFunc(func(stream *MuxedStream) error {
+		stream.WriteHeaders([]Header{{Name: "response-header", Value: "responseValue"}})
+		<-handlerDoneC
+		return nil
+	})
+
+	// The connection fails while the active stream is being drained
+	muxPair := NewDefaultMuxerPair(t, f)
+	muxPair.Serve(t)
+	_, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	if err != nil {
+		t.Fatalf("error in OpenStream: %s", err)
+	}
+	drainErrC := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+		defer cancel()
+		drainErrC <- muxPair.OriginMux.Drain(ctx)
+	}()
+	assert.Equal(t, ErrStreamRequestConnectionClosed, waitForOpenStreamError(t, muxPair.EdgeMux))
+	muxPair.EdgeConn.Close()
+	assert.Equal(t, ErrConnectionDropped, <-drainErrC)
+	muxPair.Wait(t)
+
+	// The connection failed before Drain was called. Serve isn't used, because it shuts down the origin muxer
+	// when the edge muxer stops.
+	muxPair = NewDefaultMuxerPair(t, f)
+	go muxPair.EdgeMux.Serve(context.Background())
+	serveErrC := make(chan error, 1)
+	go func() {
+		serveErrC <- muxPair.OriginMux.Serve(context.Background())
+	}()
+	muxPair.EdgeConn.Close()
+	select {
+	case <-serveErrC:
+	case <-time.After(5 * time.Second):
+		t.Fatal("origin muxer didn't stop after the connection was closed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+	defer cancel()
+	assert.Equal(t, ErrConnectionDropped, muxPair.OriginMux.Drain(ctx))
+}
+
+// waitForOpenStreamError opens streams until the muxer stops accepting new streams, and returns the error. Streams
+// opened before the muxer received GOAWAY are closed.
+func waitForOpenStreamError(t *testing.T, mux *Muxer) error {
+	deadline := time.Now().Add(testOpenStreamTimeout)
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		stream, err := mux.OpenStream(ctx, []Header{{Name: "test-header", Value: "headerValue"}}, nil)
+		cancel()
+		if err == ErrStreamRequestConnectionClosed {
+			return err
+		}
+		if stream != nil {
+			stream.Close()
+		}
+	}
+	t.Fatal("muxer still accepts new streams")
+	return nil
+}
+
+func TestResetStream(t *testing.T) {
+	handlerFinishC := make(chan struct{})
+	f := MuxedStreamFunc(func(stream *MuxedStream) error {
+		defer close(handlerFinishC)
+		stream.WriteHeaders([]Header{{Name: "response-header", Value: "responseValue"}})
+		// Blocks until the edge resets the stream
+		_, err := stream.Read([]byte{0})
+		assert.Equal(t, ErrStreamReset, err)
+		select {
+		case <-stream.Context().Done():
+		case <-time.After(time.Second):
+			t.Error("stream context wasn't cancelled by RST_STREAM")
+		}
+		_, err = stream.Write([]byte("response"))
+		assert.Equal(t, ErrStreamReset, err)
+		return nil
+	})
+	muxPair := NewDefaultMuxerPair(t, f)
+	muxPair.Serve(t)
+
+	stream, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	if err != nil {
+		t.Fatalf("error in OpenStream: %s", err)
+	}
+	stream.Reset(http2.ErrCodeCancel)
+	assert.Error(t, stream.Context().Err())
+	_, err = stream.Write([]byte("request"))
+	assert.Equal(t, ErrStreamReset, err)
+	_, err = stream.Read([]byte{0})
+	assert.Equal(t, ErrStreamReset, err)
+	<-handlerFinishC
+}
+
+func TestResetStreamBeforeResponse(t *testing.T) {
+	f := MuxedStreamFunc(func(stream *MuxedStream) error {
+		stream.Reset(http2.ErrCodeRefusedStream)
+		return nil
+	})
+	muxPair := NewDefaultMuxerPair(t, f)
+	muxPair.Serve(t)
+
+	_, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	assert.Equal(t, ErrStreamReset, err)
+}
+
+func TestAbortCancelsStreamContext(t *testing.T) {
+	handlerStartC := make(chan *MuxedStream, 1)
+	f := MuxedStreamFunc(func(stream *MuxedStream) error {
+		stream.WriteHeaders([]Header{{Name: "response-header", Value: "responseValue"}})
+		handlerStartC <- stream
+		<-stream.Context().Done()
+		return nil
+	})
+	muxPair := NewDefaultMuxerPair(t, f)
+	muxPair.Serve(t)
+
+	stream, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	if err != nil {
+		t.Fatalf("error in OpenStream: %s", err)
+	}
+	originStream := <-handlerStartC
+	muxPair.EdgeConn.Close()
+	for _, s := range []*MuxedStream{stream, originStream} {
+		select {
+		case <-s.Context().Done():
+		case <-time.After(time.Second):
+			t.Fatal("stream context wasn't cancelled when the connection dropped")
+		}
+	}
+}
+
+func TestStreamDeadlines(t *testing.T) {
+	readTimedOutC := make(chan struct{})
+	edgeDoneC := make(chan struct{})
+	f := MuxedStreamFunc(func(stream *MuxedStream) error {
+		stream.WriteHeaders([]Header{{Name: "response-header", Value: "responseValue"}})
+		stream.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		_, err := stream.Read([]byte{0})
+		if assert.Equal(t, ErrStreamTimeout, err) {
+			assert.True(t, err.(net.Error).Timeout())
+		}
+		close(readTimedOutC)
+		// Keep the stream open without reading it
+		<-edgeDoneC
+		return nil
+	})
+	muxPair := NewDefaultMuxerPair(t, f)
+	muxPair.Serve(t)
+
+	stream, err := muxPair.OpenEdgeMuxStream([]Header{{Name: "test-header", Value: "headerValue"}}, nil)
+	if err != nil {
+		t.Fatalf("error in OpenStream: %s", err)
+	}
+	defer close(edgeDoneC)
+	<-readTimedOutC
+	assert.Equal(t, muxPair.EdgeConn.LocalAddr(), stream.LocalAddr())
+	assert.Equal(t, muxPair.EdgeConn.RemoteAddr(), stream.RemoteAddr())
+
+	// The origin doesn't read the stream, so writes block once the send window and the write buffer are full
+	stream.SetWriteDeadline(time.Now().Add(50 * time.Millisecond))
+	payload := make([]byte, 1<<20)
+	n, err := stream.Write(payload)
+	assert.Equal(t, ErrStreamTimeout, err)
+	assert.True(t, n < len(payload))
+	_, err = stream.Write(payload)
+	assert.Equal(t, ErrStreamTimeout, err)
+}
+
 func EchoHandler(stream *MuxedStream) error {
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf, "Hello, world!\n\n# REQUEST HEADERS:\n\n")
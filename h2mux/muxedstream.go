@@ -2,8 +2,13 @@ package h2mux
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"net"
 	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
 )
 
 type ReadWriteLengther interface {
@@ -15,6 +20,8 @@ type ReadWriteLengther interface {
 type ReadWriteClosedCloser interface {
 	io.ReadWriteCloser
 	Closed() bool
+	CloseWithError(error)
+	SetReadDeadline(time.Time)
 }
 
 // MuxedStream is logically an HTTP/2 stream, with an additional buffer for outgoing data.
@@ -52,11 +59,17 @@ type MuxedStream struct {
 	writeBufferMaxLen int
 	// A channel to be notified when the send buffer is not full.
 	writeBufferHasSpace chan struct{}
+	// Writes fail with ErrStreamTimeout after writeDeadline, unless it's zero
+	writeDeadline time.Time
 	// This is the amount of bytes that are in the peer's receive window
 	// (how much data we can send from this stream).
 	sendWindow uint32
 	// Reference to the muxer's readyList; signal this for stream data to be sent.
 	readyList *ReadyList
+	// The share of the connection this stream gets when other streams have data to send, see ReadyList.
+	weight uint16
+	// Urgent streams, such as RPC streams, are written before all other streams.
+	urgent bool
 	// The headers that should be sent, and a flag so we only send them once.
 	headersSent  bool
 	writeHeaders []Header
@@ -68,6 +81,16 @@ type MuxedStream struct {
 	sentEOF bool
 	// true if the peer sent us an EOF
 	receivedEOF bool
+	// true if the stream was reset by either side
+	isReset bool
+	// ctx is cancelled when the stream is reset, or the muxer is aborted.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// Reference to the muxer's streamErrors; raise an error for a RST_STREAM frame to be sent.
+	streamErrors *StreamErrorMap
+	// The addresses of the muxer's underlying connection
+	localAddr  net.Addr
+	remoteAddr net.Addr
 	// If valid, tunnelHostname is used to identify which origin service is the intended recipient of the request
 	tunnelHostname TunnelHostname
 	// Compression-related fields
@@ -78,6 +101,12 @@ type MuxedStream struct {
 	dictionaries    h2Dictionaries
 }
 
+// unknownAddr is the address of a stream whose muxer doesn't run on a net.Conn
+type unknownAddr struct{}
+
+func (unknownAddr) Network() string { return "h2mux" }
+func (unknownAddr) String() string  { return "unknown" }
+
 type TunnelHostname string
 
 func (th TunnelHostname) String() string {
@@ -88,7 +117,11 @@ func (th TunnelHostname) IsSet() bool {
 	return th != ""
 }
 
+// MuxedStream implements net.Conn, so it can be used wherever a connection with deadlines is expected.
+var _ net.Conn = (*MuxedStream)(nil)
+
 func NewStream(config MuxerConfig, writeHeaders []Header, readyList *ReadyList, dictionaries h2Dictionaries) *MuxedStream {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &MuxedStream{
 		responseHeadersReceived: make(chan struct{}),
 		readBuffer:              NewSharedBuffer(),
@@ -100,8 +133,13 @@ func NewStream(config MuxerConfig, writeHeaders []Header, readyList *ReadyList,
 		receiveWindowMax:        config.MaxWindowSize,
 		sendWindow:              config.DefaultWindowSize,
 		readyList:               readyList,
+		weight:                  DefaultStreamWeight,
 		writeHeaders:            writeHeaders,
 		dictionaries:            dictionaries,
+		ctx:                     ctx,
+		cancel:                  cancel,
+		localAddr:               unknownAddr{},
+		remoteAddr:              unknownAddr{},
 	}
 }
 
@@ -129,6 +167,9 @@ func (s *MuxedStream) Write(p []byte) (int, error) {
 	}
 	defer s.writeLock.Unlock()
 
+	if s.isReset {
+		return 0, ErrStreamReset
+	}
 	if s.writeEOF {
 		return 0, io.EOF
 	}
@@ -137,10 +178,20 @@ func (s *MuxedStream) Write(p []byte) (int, error) {
 		// If the buffer is full, block till there is more room.
 		// Use a loop to recheck the buffer size after the lock is reacquired.
 		for s.writeBufferMaxLen <= s.writeBuffer.Len() {
+			if s.writeDeadlineExceeded() {
+				return totalWritten, ErrStreamTimeout
+			}
+			deadline := s.writeDeadline
 			s.writeLock.Unlock()
-			<-s.writeBufferHasSpace
+			s.waitForWriteBufferSpace(deadline)
 			s.writeLock.Lock()
 		}
+		if s.isReset {
+			return totalWritten, ErrStreamReset
+		}
+		if s.writeDeadlineExceeded() {
+			return totalWritten, ErrStreamTimeout
+		}
 		amountToWrite := len(p) - totalWritten
 		spaceAvailable := s.writeBufferMaxLen - s.writeBuffer.Len()
 		if spaceAvailable < amountToWrite {
@@ -195,6 +246,9 @@ func (s *MuxedStream) CloseWrite() error {
 func (s *MuxedStream) WriteHeaders(headers []Header) error {
 	s.writeLock.Lock()
 	defer s.writeLock.Unlock()
+	if s.isReset {
+		return ErrStreamReset
+	}
 	if s.writeHeaders != nil {
 		return ErrStreamHeadersSent
 	}
@@ -228,6 +282,72 @@ func (s *MuxedStream) IsRPCStream() bool {
 	return true
 }
 
+// SetWeight sets the share of the connection the stream gets when other streams also have data to send, between 1
+// and MaxStreamWeight. A stream with twice the weight of another is written twice as often. The weights of streams
+// opened by the peer are set from the priority of their HEADERS frame and from PRIORITY frames.
+func (s *MuxedStream) SetWeight(weight uint16) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	s.weight = weight
+}
+
+// Reset aborts the stream with code, e.g. http2.ErrCodeCancel. Blocked and future reads and writes fail, the
+// stream's context is cancelled, and the peer is sent a RST_STREAM frame.
+func (s *MuxedStream) Reset(code http2.ErrCode) {
+	if !s.reset() {
+		return
+	}
+	if s.streamErrors != nil {
+		s.streamErrors.RaiseError(s.streamID, code)
+	}
+}
+
+// Context returns a context that is cancelled when the stream is reset by either side, or the muxer is aborted.
+// Work done on behalf of the stream, such as requests to the origin, should stop when it's done.
+func (s *MuxedStream) Context() context.Context {
+	return s.ctx
+}
+
+// SetDeadline sets the read and write deadlines of the stream, see net.Conn.
+func (s *MuxedStream) SetDeadline(t time.Time) error {
+	s.SetReadDeadline(t)
+	return s.SetWriteDeadline(t)
+}
+
+// SetReadDeadline makes blocked and future reads fail with ErrStreamTimeout once t has passed. A zero t means reads
+// don't time out.
+func (s *MuxedStream) SetReadDeadline(t time.Time) error {
+	s.readBufferLock.RLock()
+	readBuffer := s.readBuffer
+	s.readBufferLock.RUnlock()
+	readBuffer.SetReadDeadline(t)
+	return nil
+}
+
+// SetWriteDeadline makes writes that are blocked waiting for room in the write buffer, and future writes, fail with
+// ErrStreamTimeout once t has passed. A zero t means writes don't time out.
+func (s *MuxedStream) SetWriteDeadline(t time.Time) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	s.writeDeadline = t
+	// Wake up a blocked Write, so it waits for the new deadline
+	select {
+	case s.writeBufferHasSpace <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// LocalAddr returns the local address of the muxer's connection.
+func (s *MuxedStream) LocalAddr() net.Addr {
+	return s.localAddr
+}
+
+// RemoteAddr returns the remote address of the muxer's connection.
+func (s *MuxedStream) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
+
 func (s *MuxedStream) TunnelHostname() TunnelHostname {
 	return s.tunnelHostname
 }
@@ -244,9 +364,33 @@ func (s *MuxedStream) getSendWindow() uint32 {
 	return s.sendWindow
 }
 
+// waitForWriteBufferSpace blocks until the write buffer may have room, or deadline passes. It must happen without
+// holding writeLock.
+func (s *MuxedStream) waitForWriteBufferSpace(deadline time.Time) {
+	if deadline.IsZero() {
+		<-s.writeBufferHasSpace
+		return
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-s.writeBufferHasSpace:
+	case <-timer.C:
+	}
+}
+
+// writeDeadlineExceeded must happen while holding writeLock.
+func (s *MuxedStream) writeDeadlineExceeded() bool {
+	return !s.writeDeadline.IsZero() && !time.Now().Before(s.writeDeadline)
+}
+
 // writeNotify must happen while holding writeLock.
 func (s *MuxedStream) writeNotify() {
-	s.readyList.Signal(s.streamID)
+	if s.urgent {
+		s.readyList.SignalUrgent(s.streamID)
+	} else {
+		s.readyList.SignalWeighted(s.streamID, s.weight)
+	}
 }
 
 // Call by muxreader when it gets a WindowUpdateFrame. This is an update of the peer's
@@ -301,6 +445,33 @@ func (s *MuxedStream) receiveEOF() (closed bool) {
 	return s.writeEOF && s.writeBuffer.Len() == 0
 }
 
+// reset stops the stream from sending or receiving any more data, and cancels its context. It returns false if the
+// stream was already reset.
+func (s *MuxedStream) reset() bool {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if s.isReset {
+		return false
+	}
+	s.isReset = true
+	s.writeEOF = true
+	s.sentEOF = true
+	s.headersSent = true
+	s.windowUpdate = 0
+	s.writeBuffer.Reset()
+	// Unblock a Write waiting for space in the write buffer
+	select {
+	case s.writeBufferHasSpace <- struct{}{}:
+	default:
+	}
+	// Reads shouldn't see the stream end normally, or a truncated body would look complete
+	s.readBufferLock.RLock()
+	s.readBuffer.CloseWithError(ErrStreamReset)
+	s.readBufferLock.RUnlock()
+	s.cancel()
+	return true
+}
+
 func (s *MuxedStream) gotReceiveEOF() bool {
 	s.writeLock.Lock()
 	defer s.writeLock.Unlock()
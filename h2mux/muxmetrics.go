@@ -68,6 +68,11 @@ type MuxerMetrics struct {
 	InBoundRateCurr, InBoundRateMin, InBoundRateMax                  uint64
 	OutBoundRateCurr, OutBoundRateMin, OutBoundRateMax               uint64
 	CompBytesBefore, CompBytesAfter                                  *AtomicCounter
+	// ActiveStreams is how many streams are open
+	ActiveStreams int
+	// RejectedStreams is how many streams opened by the peer were refused because MuxerConfig.MaxConcurrentStreams
+	// streams were open
+	RejectedStreams uint64
 }
 
 func (m *MuxerMetrics) CompRateAve() float64 {
@@ -77,6 +82,36 @@ func (m *MuxerMetrics) CompRateAve() float64 {
 	return float64(m.CompBytesAfter.Value()) / float64(m.CompBytesBefore.Value())
 }
 
+// RejectedStreamsTracker turns the RejectedStreams total of each connection into increments of a counter
+type RejectedStreamsTracker struct {
+	sync.Mutex
+	// lastRejectedStreams records the RejectedStreams of each connection when it was last tracked
+	lastRejectedStreams map[string]uint64
+}
+
+// Increment returns how many streams the connection rejected since it was last tracked
+func (t *RejectedStreamsTracker) Increment(connectionID string, rejectedStreams uint64) uint64 {
+	t.Lock()
+	defer t.Unlock()
+	if t.lastRejectedStreams == nil {
+		t.lastRejectedStreams = make(map[string]uint64)
+	}
+	lastRejectedStreams := t.lastRejectedStreams[connectionID]
+	t.lastRejectedStreams[connectionID] = rejectedStreams
+	if rejectedStreams < lastRejectedStreams {
+		// The ID was given to the muxer of a new connection, which counts from 0
+		return rejectedStreams
+	}
+	return rejectedStreams - lastRejectedStreams
+}
+
+// Delete stops tracking a closed connection
+func (t *RejectedStreamsTracker) Delete(connectionID string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.lastRejectedStreams, connectionID)
+}
+
 type roundTripMeasurement struct {
 	receiveTime, sendTime time.Time
 }
@@ -167,3 +167,17 @@ func TestMuxMetricsUpdater(t *testing.T) {
 	close(errChan)
 
 }
+
+func TestRejectedStreamsTracker(t *testing.T) {
+	var tracker RejectedStreamsTracker
+	assert.Equal(t, uint64(3), tracker.Increment("conn1", 3))
+	assert.Equal(t, uint64(2), tracker.Increment("conn1", 5))
+	assert.Equal(t, uint64(0), tracker.Increment("conn1", 5))
+	assert.Equal(t, uint64(1), tracker.Increment("conn2", 1))
+
+	// The ID was given to a new connection, whose muxer counts from 0
+	assert.Equal(t, uint64(1), tracker.Increment("conn1", 1))
+
+	tracker.Delete("conn2")
+	assert.Equal(t, uint64(4), tracker.Increment("conn2", 4))
+}
@@ -2,8 +2,10 @@ package h2mux
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"io"
+	"net"
 	"net/url"
 	"time"
 
@@ -49,6 +51,9 @@ type MuxReader struct {
 	bytesRead *AtomicCounter
 	// dictionaries holds the h2 cross-stream compression dictionaries
 	dictionaries h2Dictionaries
+	// localAddr and remoteAddr are the addresses of the underlying connection, given to new streams
+	localAddr  net.Addr
+	remoteAddr net.Addr
 }
 
 func (r *MuxReader) Shutdown() {
@@ -115,11 +120,16 @@ func (r *MuxReader) run(parentLogger *log.Entry) error {
 			err = r.receiveFrameData(f, logger)
 		case *http2.MetaHeadersFrame:
 			err = r.receiveHeaderData(f)
+		case *http2.PriorityFrame:
+			r.receivePriority(f)
 		case *http2.RSTStreamFrame:
 			streamID := f.Header().StreamID
 			if streamID == 0 {
 				return ErrInvalidStream
 			}
+			if stream, ok := r.streams.Get(streamID); ok {
+				stream.reset()
+			}
 			r.streams.Delete(streamID)
 		case *http2.PingFrame:
 			r.receivePingData(f)
@@ -149,6 +159,7 @@ func (r *MuxReader) run(parentLogger *log.Entry) error {
 }
 
 func (r *MuxReader) newMuxedStream(streamID uint32) *MuxedStream {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &MuxedStream{
 		streamID:                streamID,
 		readBuffer:              NewSharedBuffer(),
@@ -160,7 +171,13 @@ func (r *MuxReader) newMuxedStream(streamID uint32) *MuxedStream {
 		receiveWindowMax:        r.streamWindowMax,
 		sendWindow:              r.initialStreamWindow,
 		readyList:               r.readyList,
+		weight:                  DefaultStreamWeight,
 		dictionaries:            r.dictionaries,
+		ctx:                     ctx,
+		cancel:                  cancel,
+		streamErrors:            r.streamErrors,
+		localAddr:               r.localAddr,
+		remoteAddr:              r.remoteAddr,
 	}
 }
 
@@ -224,6 +241,9 @@ func (r *MuxReader) receiveHeaderData(frame *http2.MetaHeadersFrame) error {
 			return r.defaultStreamErrorHandler(err, frame.Header())
 		}
 	}
+	if frame.HasPriority() {
+		stream.SetWeight(priorityWeight(frame.Priority))
+	}
 	headers := make([]Header, 0, len(frame.Fields))
 	for _, header := range frame.Fields {
 		switch header.Name {
@@ -257,6 +277,19 @@ func (r *MuxReader) receiveHeaderData(frame *http2.MetaHeadersFrame) error {
 	return nil
 }
 
+// Receives a PRIORITY frame, which changes the weight of a stream. Stream dependencies aren't supported.
+// PRIORITY frames can be sent for streams in any state, so frames for unknown streams are ignored.
+func (r *MuxReader) receivePriority(frame *http2.PriorityFrame) {
+	if stream, ok := r.streams.Get(frame.Header().StreamID); ok {
+		stream.SetWeight(priorityWeight(frame.PriorityParam))
+	}
+}
+
+// priorityWeight returns the weight of an HTTP/2 priority, which is sent as weight-1
+func priorityWeight(priority http2.PriorityParam) uint16 {
+	return uint16(priority.Weight) + 1
+}
+
 func (r *MuxReader) handleStream(stream *MuxedStream) {
 	defer stream.Close()
 	r.handler.ServeStream(stream)
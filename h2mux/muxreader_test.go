@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
 )
 
 var (
@@ -105,3 +106,22 @@ func TestReceiveHeaderData(t *testing.T) {
 	assert.True(t, originHandler.stream.TunnelHostname().IsSet())
 	assert.Equal(t, tunnelHostnameHeader.Value, originHandler.stream.TunnelHostname().String())
 }
+
+func TestReceivePriority(t *testing.T) {
+	r := &MuxReader{streams: newActiveStreamMap(false)}
+	stream := &MuxedStream{streamID: 1, weight: DefaultStreamWeight}
+	assert.True(t, r.streams.Set(stream))
+
+	r.receivePriority(&http2.PriorityFrame{
+		FrameHeader:   http2.FrameHeader{StreamID: 1},
+		PriorityParam: http2.PriorityParam{Weight: 255},
+	})
+	assert.Equal(t, uint16(MaxStreamWeight), stream.weight)
+
+	// PRIORITY frames for streams that aren't open are ignored
+	r.receivePriority(&http2.PriorityFrame{
+		FrameHeader:   http2.FrameHeader{StreamID: 3},
+		PriorityParam: http2.PriorityParam{Weight: 0},
+	})
+	assert.Equal(t, uint16(MaxStreamWeight), stream.weight)
+}
@@ -92,7 +92,7 @@ func (w *MuxWriter) run(parentLogger *log.Entry) error {
 			return nil
 		case errCode := <-w.goAwayChan:
 			logger.Debug("sending GOAWAY code ", errCode)
-			err := w.f.WriteGoAway(w.streams.LastPeerStreamID(), errCode, []byte{})
+			err := w.f.WriteGoAway(w.streams.LastAcceptedPeerStreamID(), errCode, []byte{})
 			if err != nil {
 				return err
 			}
@@ -123,6 +123,11 @@ func (w *MuxWriter) run(parentLogger *log.Entry) error {
 				if err != nil {
 					return err
 				}
+				// A stream is closed once it's reset
+				if stream, ok := w.streams.Get(streamID); ok {
+					stream.reset()
+					w.streams.Delete(streamID)
+				}
 			}
 			w.idleTimer.MarkActive()
 		case streamRequest := <-w.newStreamChan:
@@ -133,6 +138,7 @@ func (w *MuxWriter) run(parentLogger *log.Entry) error {
 				// care of this stream. Ideally we'd pass the error directly to the stream object somehow so the
 				// caller can be unblocked sooner, but the value of that optimisation is minimal for most of the
 				// reasons why you'd call Shutdown anyway.
+				w.streams.ReleaseLocalStream()
 				continue
 			}
 			if streamRequest.body != nil {
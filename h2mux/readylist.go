@@ -1,11 +1,26 @@
 package h2mux
 
-import "sync"
+import (
+	"container/heap"
+	"sync"
+)
+
+const (
+	// DefaultStreamWeight is the weight of streams without a priority, as in HTTP/2
+	DefaultStreamWeight = 16
+	// MaxStreamWeight is the highest weight a stream can have, as in HTTP/2
+	MaxStreamWeight = 256
+	// strideScale is divided by the weight of a stream to get how far it's pushed back after it's scheduled
+	strideScale = 1 << 16
+)
 
 // ReadyList multiplexes several event signals onto a single channel.
+// Urgent streams, such as RPC streams, are handed out first in FIFO order. The other streams are handed out in
+// proportion to their weight (stride scheduling), so a stream with a high bandwidth can't starve the others.
+// Streams with the same weight are handed out in FIFO order.
 type ReadyList struct {
 	// signalC is used to signal that a stream can be enqueued
-	signalC chan uint32
+	signalC chan readySignal
 	// waitC is used to signal the ID of the first ready descriptor
 	waitC chan uint32
 	// doneC is used to signal that run should terminate
@@ -15,7 +30,7 @@ type ReadyList struct {
 
 func NewReadyList() *ReadyList {
 	rl := &ReadyList{
-		signalC: make(chan uint32),
+		signalC: make(chan readySignal),
 		waitC:   make(chan uint32),
 		doneC:   make(chan struct{}),
 	}
@@ -23,10 +38,37 @@ func NewReadyList() *ReadyList {
 	return rl
 }
 
+// readySignal is a stream that can be enqueued, with how it's scheduled
+type readySignal struct {
+	ID     uint32
+	weight uint16
+	urgent bool
+}
+
 // ID is the stream ID
 func (r *ReadyList) Signal(ID uint32) {
+	r.signal(readySignal{ID: ID, weight: DefaultStreamWeight})
+}
+
+// SignalWeighted signals a stream that is scheduled according to weight, between 1 and MaxStreamWeight.
+// A weight of 0 is DefaultStreamWeight.
+func (r *ReadyList) SignalWeighted(ID uint32, weight uint16) {
+	if weight == 0 {
+		weight = DefaultStreamWeight
+	} else if weight > MaxStreamWeight {
+		weight = MaxStreamWeight
+	}
+	r.signal(readySignal{ID: ID, weight: weight})
+}
+
+// SignalUrgent signals a stream that is scheduled before all weighted streams
+func (r *ReadyList) SignalUrgent(ID uint32) {
+	r.signal(readySignal{ID: ID, urgent: true})
+}
+
+func (r *ReadyList) signal(s readySignal) {
 	select {
-	case r.signalC <- ID:
+	case r.signalC <- s:
 	// ReadyList already closed
 	case <-r.doneC:
 	}
@@ -44,28 +86,52 @@ func (r *ReadyList) Close() {
 
 func (r *ReadyList) run() {
 	defer close(r.waitC)
-	var queue readyDescriptorQueue
-	var firstReady *readyDescriptor
+	var urgentQueue readyDescriptorQueue
+	var weightedQueue readyDescriptorHeap
+	// virtualTime is the pass of the last weighted stream handed out. A stream that becomes ready is scheduled
+	// relative to it, so streams that were idle don't get a burst of turns.
+	var virtualTime, sequence uint64
 	activeDescriptors := newReadyDescriptorMap()
+	enqueue := func(s readySignal) {
+		newReady := activeDescriptors.SetIfMissing(s.ID)
+		if newReady == nil {
+			// already enqueued
+			return
+		}
+		if s.urgent {
+			urgentQueue.Enqueue(newReady)
+			return
+		}
+		newReady.Pass = virtualTime + strideScale/uint64(s.weight)
+		newReady.Sequence = sequence
+		sequence++
+		heap.Push(&weightedQueue, newReady)
+	}
 	for {
+		firstReady := urgentQueue.Head
+		if firstReady == nil && weightedQueue.Len() > 0 {
+			firstReady = weightedQueue[0]
+		}
 		if firstReady == nil {
 			select {
-			case i := <-r.signalC:
-				firstReady = activeDescriptors.SetIfMissing(i)
+			case s := <-r.signalC:
+				enqueue(s)
 			case <-r.doneC:
 				return
 			}
+			continue
 		}
 		select {
 		case r.waitC <- firstReady.ID:
-			activeDescriptors.Delete(firstReady.ID)
-			firstReady = queue.Dequeue()
-		case i := <-r.signalC:
-			newReady := activeDescriptors.SetIfMissing(i)
-			if newReady != nil {
-				// key doesn't exist
-				queue.Enqueue(newReady)
+			if firstReady == urgentQueue.Head {
+				urgentQueue.Dequeue()
+			} else {
+				heap.Pop(&weightedQueue)
+				virtualTime = firstReady.Pass
 			}
+			activeDescriptors.Delete(firstReady.ID)
+		case s := <-r.signalC:
+			enqueue(s)
 		case <-r.doneC:
 			return
 		}
@@ -75,6 +141,10 @@ func (r *ReadyList) run() {
 type readyDescriptor struct {
 	ID   uint32
 	Next *readyDescriptor
+	// Pass is when a weighted stream is handed out, streams with a lower pass go first
+	Pass uint64
+	// Sequence orders weighted streams with the same pass by when they became ready
+	Sequence uint64
 }
 
 // readyDescriptorQueue is a queue of readyDescriptors in the form of a singly-linked list.
@@ -112,6 +182,36 @@ func (q *readyDescriptorQueue) Dequeue() *readyDescriptor {
 	return x
 }
 
+// readyDescriptorHeap is a priority queue of readyDescriptors ordered by pass, for use with container/heap.
+type readyDescriptorHeap []*readyDescriptor
+
+func (h readyDescriptorHeap) Len() int {
+	return len(h)
+}
+
+func (h readyDescriptorHeap) Less(i, j int) bool {
+	if h[i].Pass != h[j].Pass {
+		return h[i].Pass < h[j].Pass
+	}
+	return h[i].Sequence < h[j].Sequence
+}
+
+func (h readyDescriptorHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *readyDescriptorHeap) Push(x interface{}) {
+	*h = append(*h, x.(*readyDescriptor))
+}
+
+func (h *readyDescriptorHeap) Pop() interface{} {
+	old := *h
+	descriptor := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return descriptor
+}
+
 // readyDescriptorQueue is a map of readyDescriptors keyed by ID.
 // It maintains a free list of deleted ready descriptors.
 type readyDescriptorMap struct {
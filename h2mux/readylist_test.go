@@ -169,3 +169,79 @@ func TestReadyDescriptorMap(t *testing.T) {
 		t.Fatal("SetIfMissing didn't reuse freed object")
 	}
 }
+
+func TestReadyListUrgentFirst(t *testing.T) {
+	rl := NewReadyList()
+	defer rl.Close()
+
+	rl.SignalWeighted(1, MaxStreamWeight)
+	rl.Signal(2)
+	rl.SignalUrgent(3)
+	rl.SignalUrgent(4)
+	assert.Equal(t, uint32(3), receiveWithTimeout(t, rl))
+	assert.Equal(t, uint32(4), receiveWithTimeout(t, rl))
+	assert.Equal(t, uint32(1), receiveWithTimeout(t, rl))
+	rl.SignalUrgent(5)
+	assert.Equal(t, uint32(5), receiveWithTimeout(t, rl))
+	assert.Equal(t, uint32(2), receiveWithTimeout(t, rl))
+	assertEmpty(t, rl)
+}
+
+// receiveBacklogged receives n IDs from rl, signalling each ID again as if its stream always has more data to send.
+// It returns how many times each ID was received.
+func receiveBacklogged(t *testing.T, rl *ReadyList, n int, weights map[uint32]uint16) map[uint32]int {
+	received := make(map[uint32]int)
+	for i := 0; i < n; i++ {
+		ID := receiveWithTimeout(t, rl)
+		received[ID]++
+		rl.SignalWeighted(ID, weights[ID])
+	}
+	return received
+}
+
+func TestReadyListWeightedFairness(t *testing.T) {
+	rl := NewReadyList()
+	defer rl.Close()
+
+	weights := map[uint32]uint16{1: MaxStreamWeight, 2: DefaultStreamWeight, 3: DefaultStreamWeight / 2}
+	for ID, weight := range weights {
+		rl.SignalWeighted(ID, weight)
+	}
+	received := receiveBacklogged(t, rl, 2500, weights)
+	// Each stream is received in proportion to its weight, within one turn of the lowest weight stream
+	assert.InDelta(t, 32*received[3], received[1], 32)
+	assert.InDelta(t, 2*received[3], received[2], 2)
+	assert.True(t, received[3] > 0, "stream with the lowest weight was starved")
+}
+
+func TestReadyListEqualWeightsRoundRobin(t *testing.T) {
+	rl := NewReadyList()
+	defer rl.Close()
+
+	weights := map[uint32]uint16{}
+	for ID := uint32(1); ID <= 3; ID++ {
+		weights[ID] = DefaultStreamWeight
+		rl.Signal(ID)
+	}
+	for round := 0; round < 3; round++ {
+		for ID := uint32(1); ID <= 3; ID++ {
+			assert.Equal(t, ID, receiveWithTimeout(t, rl))
+			rl.Signal(ID)
+		}
+	}
+}
+
+func TestReadyListIdleStreamDoesNotBurst(t *testing.T) {
+	rl := NewReadyList()
+	defer rl.Close()
+
+	weights := map[uint32]uint16{1: DefaultStreamWeight, 2: DefaultStreamWeight}
+	rl.Signal(1)
+	receiveBacklogged(t, rl, 100, weights)
+	// Stream 2 becomes ready after stream 1 had the connection to itself. It shares the connection from then on,
+	// instead of catching up on the turns it didn't need.
+	rl.Signal(2)
+	received := receiveBacklogged(t, rl, 100, weights)
+	assert.InDelta(t, 50, received[1], 1)
+	assert.InDelta(t, 50, received[2], 1)
+}
@@ -4,12 +4,19 @@ import (
 	"bytes"
 	"io"
 	"sync"
+	"time"
 )
 
 type SharedBuffer struct {
 	cond   *sync.Cond
 	buffer bytes.Buffer
 	eof    bool
+	// closeErr is returned by reads instead of what's left in the buffer, if it was closed with CloseWithError
+	closeErr error
+	// Reads fail with ErrStreamTimeout after readDeadline, unless it's zero
+	readDeadline time.Time
+	// deadlineTimer wakes up blocked reads when readDeadline passes
+	deadlineTimer *time.Timer
 }
 
 func NewSharedBuffer() *SharedBuffer {
@@ -22,6 +29,14 @@ func (s *SharedBuffer) Read(p []byte) (n int, err error) {
 	totalRead := 0
 	s.cond.L.Lock()
 	for totalRead == 0 {
+		if s.closeErr != nil {
+			err = s.closeErr
+			break
+		}
+		if s.deadlineExceeded() {
+			err = ErrStreamTimeout
+			break
+		}
 		n, err = s.buffer.Read(p[totalRead:])
 		totalRead += n
 		if err == io.EOF {
@@ -60,6 +75,44 @@ func (s *SharedBuffer) Close() error {
 	return nil
 }
 
+// CloseWithError closes the buffer, making blocked and future reads fail with err. It does nothing if the buffer
+// was already closed, since everything that was written to it can be read.
+func (s *SharedBuffer) CloseWithError(err error) {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+	if s.eof {
+		return
+	}
+	s.eof = true
+	s.closeErr = err
+	s.cond.Broadcast()
+}
+
+// SetReadDeadline makes blocked and future reads fail with ErrStreamTimeout once t has passed. A zero t means reads
+// don't time out.
+func (s *SharedBuffer) SetReadDeadline(t time.Time) {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+	s.readDeadline = t
+	if s.deadlineTimer != nil {
+		s.deadlineTimer.Stop()
+		s.deadlineTimer = nil
+	}
+	if t.IsZero() {
+		return
+	}
+	s.deadlineTimer = time.AfterFunc(time.Until(t), func() {
+		s.cond.L.Lock()
+		defer s.cond.L.Unlock()
+		s.cond.Broadcast()
+	})
+}
+
+// deadlineExceeded must happen while holding cond.L.
+func (s *SharedBuffer) deadlineExceeded() bool {
+	return !s.readDeadline.IsZero() && !time.Now().Before(s.readDeadline)
+}
+
 func (s *SharedBuffer) Closed() bool {
 	s.cond.L.Lock()
 	defer s.cond.L.Unlock()
@@ -125,3 +125,62 @@ func TestSharedBufferClose(t *testing.T) {
 		t.Fatalf("expected EOF, got %s", err)
 	}
 }
+
+func TestSharedBufferReadDeadline(t *testing.T) {
+	b := NewSharedBuffer()
+	errC := make(chan error)
+	go func() {
+		_, err := b.Read(make([]byte, 1))
+		errC <- err
+	}()
+	// The deadline applies to a read that is already blocked
+	b.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	select {
+	case err := <-errC:
+		assert.Equal(t, ErrStreamTimeout, err)
+	case <-time.After(time.Second):
+		t.Fatal("blocked read didn't time out")
+	}
+
+	// Reads keep failing until the deadline is moved
+	testData := []byte("Hello world")
+	AssertIOReturnIsGood(t, len(testData))(b.Write(testData))
+	_, err := b.Read(make([]byte, len(testData)))
+	assert.Equal(t, ErrStreamTimeout, err)
+	b.SetReadDeadline(time.Time{})
+	AssertIOReturnIsGood(t, len(testData))(b.Read(make([]byte, len(testData))))
+}
+
+func TestSharedBufferCloseWithError(t *testing.T) {
+	b := NewSharedBuffer()
+	errC := make(chan error)
+	go func() {
+		_, err := b.Read(make([]byte, 1))
+		errC <- err
+	}()
+	b.CloseWithError(ErrStreamReset)
+	select {
+	case err := <-errC:
+		assert.Equal(t, ErrStreamReset, err)
+	case <-time.After(time.Second):
+		t.Fatal("blocked read wasn't woken up")
+	}
+
+	// Unread data is discarded, the reader shouldn't mistake it for the whole stream
+	_, err := b.Write([]byte("Hello world"))
+	assert.Equal(t, io.EOF, err)
+	_, err = b.Read(make([]byte, 1))
+	assert.Equal(t, ErrStreamReset, err)
+}
+
+func TestSharedBufferCloseWithErrorAfterClose(t *testing.T) {
+	b := NewSharedBuffer()
+	testData := []byte("Hello world")
+	AssertIOReturnIsGood(t, len(testData))(b.Write(testData))
+	b.Close()
+	// Everything was written already, so it can still be read
+	b.CloseWithError(ErrStreamReset)
+	AssertIOReturnIsGood(t, len(testData))(b.Read(make([]byte, len(testData))))
+	_, err := b.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
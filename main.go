@@ -1,9 +1,155 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/cloudflare/cloudflared/transfer"
 )
 
+// Version and BuildTime are set at link time, see VERSION_FLAGS in the Makefile
+var (
+	Version   = "DEV"
+	BuildTime = "unknown"
+)
+
+// command is a node in the cloudflared command tree. A command either has an action or dispatches
+// to one of its subcommands.
+type command struct {
+	name        string
+	usage       string
+	action      func(args []string) error
+	subcommands []*command
+}
+
 func main() {
-	transfer.Login()
+	if err := app().run(os.Args[1:]); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func app() *command {
+	return &command{
+		name:  "cloudflared",
+		usage: "Cloudflare's command-line tool and agent",
+		subcommands: []*command{
+			{
+				name:   "login",
+				usage:  "Generate a configuration file with your login details",
+				action: login,
+			},
+			{
+				name:  "tunnel",
+				usage: "Make a locally-running web service accessible over the internet using Argo Tunnel",
+				subcommands: []*command{
+					{
+						name:   "run",
+						usage:  "Run a tunnel that proxies traffic from Cloudflare's edge to local origins",
+						action: tunnelRun,
+					},
+				},
+			},
+			{
+				name:  "access",
+				usage: "Reach applications behind tunnel hostnames that are protected by Cloudflare Access",
+				subcommands: []*command{
+					{
+						name:   "tcp",
+						usage:  "Relay a local port, or stdin and stdout, to a TCP origin such as SSH",
+						action: accessTCP,
+					},
+					{
+						name:   "curl",
+						usage:  "Run curl with an Access token of the requested application in the cf-access-token header",
+						action: accessCurl,
+					},
+					{
+						name:   "run",
+						usage:  "Run a command with an Access token of an application in the CF_ACCESS_TOKEN environment variable",
+						action: accessRun,
+					},
+				},
+			},
+			{
+				name:  "config",
+				usage: "Inspect cloudflared configuration files",
+				subcommands: []*command{
+					{
+						name:   "validate",
+						usage:  "Check a config file without connecting to the edge, exits non-zero on any failure",
+						action: configValidate,
+					},
+				},
+			},
+			{
+				name:   "version",
+				usage:  "Print the version",
+				action: version,
+			},
+		},
+	}
+}
+
+func (c *command) run(args []string) error {
+	if c.action != nil {
+		return c.action(args)
+	}
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		c.printUsage()
+		return flag.ErrHelp
+	}
+	for _, subcommand := range c.subcommands {
+		if subcommand.name == args[0] {
+			return subcommand.run(args[1:])
+		}
+	}
+	c.printUsage()
+	return fmt.Errorf("%s: unknown command %q", c.name, args[0])
+}
+
+func (c *command) printUsage() {
+	fmt.Fprintf(os.Stderr, "%s - %s\n\nCommands:\n", c.name, c.usage)
+	width := 0
+	for _, subcommand := range c.subcommands {
+		if len(subcommand.name) > width {
+			width = len(subcommand.name)
+		}
+	}
+	for _, subcommand := range c.subcommands {
+		fmt.Fprintf(os.Stderr, "  %s%s  %s\n", subcommand.name, strings.Repeat(" ", width-len(subcommand.name)), subcommand.usage)
+	}
+}
+
+func login(args []string) error {
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	var options transfer.LoginOptions
+	flags.BoolVar(&options.NoBrowser, "no-browser", false, "Print the login URL instead of opening a browser, to log in from another machine.")
+	flags.DurationVar(&options.Timeout, "timeout", transfer.DefaultLoginTimeout, "Maximum time to wait for the login to complete.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	return transfer.Login(context.Background(), options)
+}
+
+func version(args []string) error {
+	fmt.Printf("cloudflared version %s (built %s)\n", Version, BuildTime)
+	return nil
+}
+
+// stringSliceFlag is a flag.Value that can be specified multiple times
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
@@ -28,6 +28,10 @@ type muxerMetrics struct {
 	compBytesBefore  *prometheus.GaugeVec
 	compBytesAfter   *prometheus.GaugeVec
 	compRateAve      *prometheus.GaugeVec
+	activeStreams    *prometheus.GaugeVec
+	rejectedStreams  *prometheus.CounterVec
+	// rejectedStreamsTracker turns the rejected streams of each connection into increments of rejectedStreams
+	rejectedStreamsTracker h2mux.RejectedStreamsTracker
 }
 
 type TunnelMetrics struct {
@@ -223,6 +227,24 @@ func newMuxerMetrics() *muxerMetrics {
 	)
 	prometheus.MustRegister(compRateAve)
 
+	activeStreams := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "active_streams",
+			Help: "Number of open streams",
+		},
+		[]string{"connection_id"},
+	)
+	prometheus.MustRegister(activeStreams)
+
+	rejectedStreams := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rejected_streams",
+			Help: "Number of streams from the edge refused because the maximum number of concurrent streams were open",
+		},
+		[]string{"connection_id"},
+	)
+	prometheus.MustRegister(rejectedStreams)
+
 	return &muxerMetrics{
 		rtt:              rtt,
 		rttMin:           rttMin,
@@ -242,6 +264,8 @@ func newMuxerMetrics() *muxerMetrics {
 		compBytesBefore:  compBytesBefore,
 		compBytesAfter:   compBytesAfter,
 		compRateAve:      compRateAve,
+		activeStreams:    activeStreams,
+		rejectedStreams:  rejectedStreams,
 	}
 }
 
@@ -264,6 +288,8 @@ func (m *muxerMetrics) update(connectionID string, metrics *h2mux.MuxerMetrics)
 	m.compBytesBefore.WithLabelValues(connectionID).Set(float64(metrics.CompBytesBefore.Value()))
 	m.compBytesAfter.WithLabelValues(connectionID).Set(float64(metrics.CompBytesAfter.Value()))
 	m.compRateAve.WithLabelValues(connectionID).Set(float64(metrics.CompRateAve()))
+	m.activeStreams.WithLabelValues(connectionID).Set(float64(metrics.ActiveStreams))
+	m.rejectedStreams.WithLabelValues(connectionID).Add(float64(m.rejectedStreamsTracker.Increment(connectionID, metrics.RejectedStreams)))
 }
 
 func convertRTTMilliSec(t time.Duration) float64 {
@@ -5,6 +5,9 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/cloudflare/cloudflared/h2mux"
+
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -119,3 +122,31 @@ func TestRegisterServerLocation(t *testing.T) {
 	}
 
 }
+
+func TestRejectedStreamsCounter(t *testing.T) {
+	rejectedStreams := func(connectionID string) float64 {
+		var metric dto.Metric
+		assert.NoError(t, m.muxerMetrics.rejectedStreams.WithLabelValues(connectionID).Write(&metric))
+		return metric.GetCounter().GetValue()
+	}
+	update := func(connectionID string, rejected uint64) {
+		m.muxerMetrics.update(connectionID, &h2mux.MuxerMetrics{
+			CompBytesBefore: h2mux.NewAtomicCounter(0),
+			CompBytesAfter:  h2mux.NewAtomicCounter(0),
+			RejectedStreams: rejected,
+		})
+	}
+
+	update("0", 3)
+	assert.Equal(t, float64(3), rejectedStreams("0"))
+	update("0", 5)
+	assert.Equal(t, float64(5), rejectedStreams("0"))
+	update("0", 5)
+	assert.Equal(t, float64(5), rejectedStreams("0"))
+	// The connection reconnected, so the muxer counts from 0 again
+	update("0", 2)
+	assert.Equal(t, float64(7), rejectedStreams("0"))
+	update("1", 1)
+	assert.Equal(t, float64(1), rejectedStreams("1"))
+	assert.Equal(t, float64(7), rejectedStreams("0"))
+}
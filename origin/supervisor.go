@@ -223,7 +223,7 @@ func (s *Supervisor) resolveEdgeIPs() ([]*net.TCPAddr, error) {
 		return connection.ResolveAddrs(s.config.EdgeAddrs)
 	}
 	// Otherwise lookup edge server addresses through service discovery
-	return connection.EdgeDiscovery(s.logger)
+	return connection.EdgeDiscovery(s.config.edgeDialer(), s.logger)
 }
 
 func (s *Supervisor) refreshEdgeIPs() {
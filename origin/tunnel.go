@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/cloudflare/cloudflared/buildinfo"
+	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/h2mux"
 	"github.com/cloudflare/cloudflared/signal"
 	"github.com/cloudflare/cloudflared/streamhandler"
@@ -47,6 +48,7 @@ type TunnelConfig struct {
 	CloseConnOnce        *sync.Once // Used to close connectedSignal no more than once
 	CompressionQuality   uint64
 	EdgeAddrs            []string
+	EdgeDialer           connection.EdgeDialer // Dials the edge through the proxy in HTTPS_PROXY if nil
 	GracePeriod          time.Duration
 	HAConnections        int
 	HTTPTransport        http.RoundTripper
@@ -57,6 +59,7 @@ type TunnelConfig struct {
 	IsFreeTunnel         bool
 	LBPool               string
 	Logger               *log.Logger
+	MaxConcurrentStreams uint32 // How many streams the edge can have open on each connection, 0 means no limit
 	MaxHeartbeats        uint64
 	Metrics              *TunnelMetrics
 	MetricsUpdateFreq    time.Duration
@@ -74,6 +77,15 @@ type TunnelConfig struct {
 	OriginUrl string
 }
 
+func (c *TunnelConfig) edgeDialer() connection.EdgeDialer {
+	if c.EdgeDialer == nil {
+		// Without a proxy URL, the proxy is read from HTTPS_PROXY and NO_PROXY, so it can't fail
+		edgeDialer, _ := connection.NewEdgeDialer("")
+		return edgeDialer
+	}
+	return c.EdgeDialer
+}
+
 type dialError struct {
 	cause error
 }
@@ -167,7 +179,11 @@ func ServeTunnelLoop(ctx context.Context,
 		if recoverable {
 			duration := timer.Duration()
 			logger.Infof("Retrying in %s seconds", duration)
-			<-time.After(duration)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(duration):
+			}
 			continue
 		}
 		return err
@@ -453,8 +469,6 @@ type TunnelHandler struct {
 	noChunkedEncoding bool
 }
 
-var dialer = net.Dialer{DualStack: true}
-
 // NewTunnelHandler returns a TunnelHandler, origin LAN IP and error
 func NewTunnelHandler(ctx context.Context,
 	config *TunnelConfig,
@@ -478,7 +492,7 @@ func NewTunnelHandler(ctx context.Context,
 	// Inherit from parent context so we can cancel (Ctrl-C) while dialing
 	dialCtx, dialCancel := context.WithTimeout(ctx, dialTimeout)
 	// TUN-92: enforce a timeout on dial and handshake (as tls.Dial does not support one)
-	plaintextEdgeConn, err := dialer.DialContext(dialCtx, "tcp", addr)
+	plaintextEdgeConn, err := config.edgeDialer().DialContext(dialCtx, "tcp", addr)
 	dialCancel()
 	if err != nil {
 		return nil, "", dialError{cause: errors.Wrap(err, "DialContext error")}
@@ -494,13 +508,14 @@ func NewTunnelHandler(ctx context.Context,
 	// Establish a muxed connection with the edge
 	// Client mux handshake with agent server
 	h.muxer, err = h2mux.Handshake(edgeConn, edgeConn, h2mux.MuxerConfig{
-		Timeout:            5 * time.Second,
-		Handler:            h,
-		IsClient:           true,
-		HeartbeatInterval:  config.HeartbeatInterval,
-		MaxHeartbeats:      config.MaxHeartbeats,
-		Logger:             config.TransportLogger.WithFields(log.Fields{}),
-		CompressionQuality: h2mux.CompressionSetting(config.CompressionQuality),
+		Timeout:              5 * time.Second,
+		Handler:              h,
+		IsClient:             true,
+		HeartbeatInterval:    config.HeartbeatInterval,
+		MaxHeartbeats:        config.MaxHeartbeats,
+		Logger:               config.TransportLogger.WithFields(log.Fields{}),
+		CompressionQuality:   h2mux.CompressionSetting(config.CompressionQuality),
+		MaxConcurrentStreams: config.MaxConcurrentStreams,
 	})
 	if err != nil {
 		return h, "", errors.New("TLS handshake error")
@@ -548,6 +563,8 @@ func (h *TunnelHandler) createRequest(stream *h2mux.MuxedStream) (*http.Request,
 	if err != nil {
 		return nil, errors.Wrap(err, "Unexpected error from http.NewRequest")
 	}
+	// Stop the request to the origin when the eyeball goes away
+	req = req.WithContext(stream.Context())
 	err = streamhandler.H2RequestHeadersToH1Request(stream.Headers, req)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid request received")
@@ -0,0 +1,272 @@
+package originservice
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cloudflare/cloudflared/buildinfo"
+	"github.com/cloudflare/cloudflared/h2mux"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const helloWorldPageTemplate = `<!DOCTYPE html>
+<html>
+<head><title>Argo Tunnel Connection</title></head>
+<body>
+<h1>Congrats! You created a tunnel!</h1>
+<p>Argo Tunnel exposes locally running applications to the internet by running an encrypted, virtual tunnel from
+your laptop or server to Cloudflare's edge network.</p>
+<table>
+<tr><th>Tunnel hostname</th><td>{{.TunnelHostname}}</td></tr>
+<tr><th>Location</th><td>{{.Location}}</td></tr>
+<tr><th>cloudflared version</th><td>{{.BuildInfo.CloudflaredVersion}}</td></tr>
+<tr><th>Platform</th><td>{{.BuildInfo.GoOS}}/{{.BuildInfo.GoArch}} {{.BuildInfo.GoVersion}}</td></tr>
+</table>
+<h2>Request headers</h2>
+<table>
+{{range $name, $values := .Headers}}<tr><th>{{$name}}</th><td>{{range $values}}{{.}} {{end}}</td></tr>
+{{end}}</table>
+<p>The same information is available as JSON at <a href="/json">/json</a>. Connect a websocket client to /ws to
+have messages echoed back.</p>
+</body>
+</html>
+`
+
+var helloWorldPage = template.Must(template.New("helloWorld").Parse(helloWorldPageTemplate))
+
+// helloWorldInfo is the diagnostics information served by HelloWorldService
+type helloWorldInfo struct {
+	TunnelHostname h2mux.TunnelHostname `json:"tunnel_hostname"`
+	Location       string               `json:"location"`
+	Method         string               `json:"method"`
+	Path           string               `json:"path"`
+	Headers        http.Header          `json:"headers"`
+	BuildInfo      *buildinfo.BuildInfo `json:"build_info"`
+}
+
+type locationKey struct{}
+
+type buildInfoKey struct{}
+
+// WithLocation returns a context that tells the hello world service the location of the edge that sent a request
+func WithLocation(ctx context.Context, location string) context.Context {
+	return context.WithValue(ctx, locationKey{}, location)
+}
+
+// WithBuildInfo returns a context that tells the hello world service the BuildInfo of the cloudflared that
+// serves a request
+func WithBuildInfo(ctx context.Context, buildInfo *buildinfo.BuildInfo) context.Context {
+	return context.WithValue(ctx, buildInfoKey{}, buildInfo)
+}
+
+// HelloWorldService is a built-in origin to smoke test a tunnel. It serves requests itself instead of proxying
+// them to a server, so it doesn't listen on any port.
+type HelloWorldService struct {
+	originURL *url.URL
+	upgrader  websocket.Upgrader
+}
+
+func NewHelloWorldService() OriginService {
+	return &HelloWorldService{
+		originURL: &url.URL{Scheme: "http", Host: "hello-world"},
+		upgrader: websocket.Upgrader{
+			// Anyone who can reach the tunnel hostname is allowed to use the echo route
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+	}
+}
+
+func (hws *HelloWorldService) Proxy(stream *h2mux.MuxedStream, req *http.Request) (*http.Response, error) {
+	w := newStreamResponseWriter(stream)
+	info := &helloWorldInfo{
+		TunnelHostname: stream.TunnelHostname(),
+		Location:       "unknown",
+		Method:         req.Method,
+		Path:           req.URL.Path,
+		Headers:        req.Header,
+		BuildInfo:      buildinfo.GetBuildInfo("unknown"),
+	}
+	if location, ok := req.Context().Value(locationKey{}).(string); ok && location != "" {
+		info.Location = location
+	}
+	if buildInfo, ok := req.Context().Value(buildInfoKey{}).(*buildinfo.BuildInfo); ok && buildInfo != nil {
+		info.BuildInfo = buildInfo
+	}
+
+	var err error
+	switch req.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = helloWorldPage.Execute(w, info)
+	case "/json":
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(info)
+	case "/ws":
+		err = hws.echoWebsocket(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error serving hello world request")
+	}
+	return w.response(), nil
+}
+
+// echoWebsocket sends every message it receives back to the client, until the client closes the websocket
+func (hws *HelloWorldService) echoWebsocket(w http.ResponseWriter, req *http.Request) error {
+	conn, err := hws.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// Upgrade has already responded with an error status
+		return nil
+	}
+	defer conn.Close()
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil
+			}
+			return err
+		}
+		if err := conn.WriteMessage(messageType, message); err != nil {
+			return err
+		}
+	}
+}
+
+func (hws *HelloWorldService) URL() *url.URL {
+	return hws.originURL
+}
+
+func (hws *HelloWorldService) Summary() string {
+	return "Hello world service built into cloudflared"
+}
+
+func (hws *HelloWorldService) Shutdown() {}
+
+// streamResponseWriter is a http.ResponseWriter that writes the response to a MuxedStream. It can be hijacked
+// to upgrade to a websocket.
+type streamResponseWriter struct {
+	stream      *h2mux.MuxedStream
+	header      http.Header
+	statusCode  int
+	wroteHeader bool
+}
+
+func newStreamResponseWriter(stream *h2mux.MuxedStream) *streamResponseWriter {
+	return &streamResponseWriter{
+		stream: stream,
+		header: make(http.Header),
+	}
+}
+
+func (w *streamResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *streamResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.statusCode = statusCode
+	w.stream.WriteHeaders(h1ResponseToH2Response(w.response()))
+}
+
+func (w *streamResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.stream.Write(p)
+}
+
+// Hijack implements http.Hijacker. The HTTP/1.1 response written to the connection before anything else is
+// converted to the headers of the stream.
+func (w *streamResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn := &hijackedStreamConn{w: w}
+	return conn, bufio.NewReadWriter(bufio.NewReader(w.stream), bufio.NewWriter(conn)), nil
+}
+
+func (w *streamResponseWriter) response() *http.Response {
+	statusCode := w.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	return &http.Response{
+		StatusCode: statusCode,
+		Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		Header:     w.header,
+	}
+}
+
+// hijackedStreamConn is the net.Conn of a hijacked streamResponseWriter
+type hijackedStreamConn struct {
+	w *streamResponseWriter
+	// responseHead buffers the HTTP/1.1 response until all of its headers have been written
+	responseHead bytes.Buffer
+}
+
+func (c *hijackedStreamConn) Read(p []byte) (int, error) {
+	return c.w.stream.Read(p)
+}
+
+func (c *hijackedStreamConn) Write(p []byte) (int, error) {
+	if c.w.wroteHeader {
+		return c.w.stream.Write(p)
+	}
+	c.responseHead.Write(p)
+	headEnd := bytes.Index(c.responseHead.Bytes(), []byte("\r\n\r\n"))
+	if headEnd < 0 {
+		return len(p), nil
+	}
+	head := c.responseHead.Bytes()[:headEnd+4]
+	body := c.responseHead.Bytes()[headEnd+4:]
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(head)), nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot parse response written to hijacked connection")
+	}
+	for name, values := range resp.Header {
+		c.w.header[name] = values
+	}
+	c.w.WriteHeader(resp.StatusCode)
+	if len(body) > 0 {
+		if _, err := c.w.stream.Write(body); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (c *hijackedStreamConn) Close() error {
+	return c.w.stream.Close()
+}
+
+func (c *hijackedStreamConn) LocalAddr() net.Addr {
+	return c.w.stream.LocalAddr()
+}
+
+func (c *hijackedStreamConn) RemoteAddr() net.Addr {
+	return c.w.stream.RemoteAddr()
+}
+
+func (c *hijackedStreamConn) SetDeadline(t time.Time) error {
+	return c.w.stream.SetDeadline(t)
+}
+
+func (c *hijackedStreamConn) SetReadDeadline(t time.Time) error {
+	return c.w.stream.SetReadDeadline(t)
+}
+
+func (c *hijackedStreamConn) SetWriteDeadline(t time.Time) error {
+	return c.w.stream.SetWriteDeadline(t)
+}
@@ -1,8 +1,9 @@
-// Package client defines and implements interface to proxy to HTTP, websocket and hello world origins
+// Package client defines and implements interface to proxy to HTTP, websocket, hello world and TCP origins
 package originservice
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -11,6 +12,8 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cloudflare/cloudflared/h2mux"
 	"github.com/cloudflare/cloudflared/log"
@@ -27,6 +30,79 @@ type OriginService interface {
 	Shutdown()
 }
 
+// RoundTripError is returned by Proxy when the request couldn't be sent to the origin, or the origin didn't respond.
+// Nothing has been written to the stream, so the request can be proxied again.
+type RoundTripError struct {
+	cause error
+}
+
+func (e RoundTripError) Error() string {
+	return e.cause.Error()
+}
+
+type dialTimeoutKey struct{}
+
+// WithDialTimeout returns a context that makes origin services give up connecting to the origin after timeout
+func WithDialTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, dialTimeoutKey{}, timeout)
+}
+
+// DialContextWithTimeout wraps dialContext to honor the timeout set by WithDialTimeout. The connections it returns
+// can be watched with ConnClosed.
+func DialContextWithTimeout(dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if timeout, ok := ctx.Value(dialTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		conn, err := dialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &closeNotifyConn{Conn: conn, closed: make(chan struct{})}, nil
+	}
+}
+
+// ConnClosed returns a channel that's closed when conn, or the connection it's layered on, is closed. It returns nil
+// if conn wasn't dialed by DialContextWithTimeout.
+func ConnClosed(conn net.Conn) <-chan struct{} {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if cnc, ok := conn.(*closeNotifyConn); ok {
+		return cnc.closed
+	}
+	return nil
+}
+
+// closeNotifyConn lets whoever is using a connection to the origin know that it has been closed, e.g. because the
+// origin hung up
+type closeNotifyConn struct {
+	net.Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (c *closeNotifyConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+// withStreamContext returns a copy of req that is also cancelled when stream is reset or its connection dies, so
+// origin requests stop when the eyeball goes away. cancel must be called once the request is done.
+func withStreamContext(req *http.Request, stream *h2mux.MuxedStream) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		select {
+		case <-stream.Context().Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return req.WithContext(ctx), cancel
+}
+
 // HTTPService talks to origin using HTTP/HTTPS
 type HTTPService struct {
 	client          http.RoundTripper
@@ -55,9 +131,11 @@ func (hc *HTTPService) Proxy(stream *h2mux.MuxedStream, req *http.Request) (*htt
 	// Request origin to keep connection alive to improve performance
 	req.Header.Set("Connection", "keep-alive")
 
+	req, cancel := withStreamContext(req, stream)
+	defer cancel()
 	resp, err := hc.client.RoundTrip(req)
 	if err != nil {
-		return nil, errors.Wrap(err, "error proxying request to HTTP origin")
+		return nil, RoundTripError{cause: errors.Wrap(err, "error proxying request to HTTP origin")}
 	}
 	defer resp.Body.Close()
 
@@ -114,7 +192,7 @@ func (wsc *WebsocketService) Proxy(stream *h2mux.MuxedStream, req *http.Request)
 	}
 	conn, response, err := websocket.ClientConnect(req, wsc.tlsConfig)
 	if err != nil {
-		return nil, err
+		return nil, RoundTripError{cause: err}
 	}
 	defer conn.Close()
 	err = stream.WriteHeaders(h1ResponseToH2Response(response))
@@ -0,0 +1,122 @@
+package originservice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/cloudflare/cloudflared/h2mux"
+	"github.com/cloudflare/cloudflared/websocket"
+
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	proxyProtocolV2Local = 0x20
+	proxyProtocolV2Proxy = 0x21
+	proxyProtocolTCP4    = 0x11
+	proxyProtocolTCP6    = 0x21
+)
+
+// proxyProtocolV2Signature starts every PROXY protocol v2 header, see
+// https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// TCPService relays the messages of websockets from the edge to TCP connections to the origin, so tunnels can
+// serve protocols such as SSH or Postgres.
+type TCPService struct {
+	address       string
+	proxyProtocol bool
+	originURL     *url.URL
+	dialer        net.Dialer
+	upgrader      gorillaws.Upgrader
+}
+
+// NewTCPService returns a TCPService that connects to address. If proxyProtocol is true, a PROXY protocol v2
+// header with the client address is sent to the origin before anything else.
+func NewTCPService(address string, proxyProtocol bool) (OriginService, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, errors.Wrapf(err, "%s is not a valid TCP address", address)
+	}
+	return &TCPService{
+		address:       address,
+		proxyProtocol: proxyProtocol,
+		originURL:     &url.URL{Scheme: "tcp", Host: address},
+		upgrader: gorillaws.Upgrader{
+			// Access to the tunnel hostname is controlled by the edge
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+	}, nil
+}
+
+func (ts *TCPService) Proxy(stream *h2mux.MuxedStream, req *http.Request) (*http.Response, error) {
+	if !websocket.IsWebSocketUpgrade(req) {
+		return nil, fmt.Errorf("request is not a websocket connection")
+	}
+	// ReverseProxyConfig.ConnectionTimeout is passed in the request context
+	originConn, err := DialContextWithTimeout(ts.dialer.DialContext)(req.Context(), "tcp", ts.address)
+	if err != nil {
+		return nil, RoundTripError{cause: errors.Wrap(err, "error connecting to TCP origin")}
+	}
+	defer originConn.Close()
+	if ts.proxyProtocol {
+		header := proxyProtocolHeader(net.ParseIP(req.Header.Get("Cf-Connecting-IP")), originConn.RemoteAddr())
+		if _, err := originConn.Write(header); err != nil {
+			return nil, RoundTripError{cause: errors.Wrap(err, "error writing PROXY protocol header to TCP origin")}
+		}
+	}
+
+	w := newStreamResponseWriter(stream)
+	conn, err := ts.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// Upgrade has already responded with an error status
+		return w.response(), nil
+	}
+	defer conn.Close()
+	websocket.Stream(&websocket.Conn{Conn: conn}, originConn)
+	return w.response(), nil
+}
+
+func (ts *TCPService) URL() *url.URL {
+	return ts.originURL
+}
+
+func (ts *TCPService) Summary() string {
+	return fmt.Sprintf("TCP service listening on %s", ts.address)
+}
+
+func (ts *TCPService) Shutdown() {}
+
+// proxyProtocolHeader returns the PROXY protocol v2 header of a connection from clientIP to originAddr. The client
+// port isn't known, so it's 0. If clientIP is nil, the header tells the origin to use the address of the
+// connection instead.
+func proxyProtocolHeader(clientIP net.IP, originAddr net.Addr) []byte {
+	var header bytes.Buffer
+	header.Write(proxyProtocolV2Signature)
+	originTCPAddr, ok := originAddr.(*net.TCPAddr)
+	if clientIP == nil || !ok {
+		header.Write([]byte{proxyProtocolV2Local, 0, 0, 0})
+		return header.Bytes()
+	}
+
+	var addresses bytes.Buffer
+	if clientIPv4, originIPv4 := clientIP.To4(), originTCPAddr.IP.To4(); clientIPv4 != nil && originIPv4 != nil {
+		header.Write([]byte{proxyProtocolV2Proxy, proxyProtocolTCP4})
+		addresses.Write(clientIPv4)
+		addresses.Write(originIPv4)
+	} else {
+		// IPv4 addresses are mapped to IPv6 if the client and the origin use different families
+		header.Write([]byte{proxyProtocolV2Proxy, proxyProtocolTCP6})
+		addresses.Write(clientIP.To16())
+		addresses.Write(originTCPAddr.IP.To16())
+	}
+	binary.Write(&addresses, binary.BigEndian, uint16(0))
+	binary.Write(&addresses, binary.BigEndian, uint16(originTCPAddr.Port))
+	binary.Write(&header, binary.BigEndian, uint16(addresses.Len()))
+	header.Write(addresses.Bytes())
+	return header.Bytes()
+}
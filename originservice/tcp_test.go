@@ -0,0 +1,37 @@
+package originservice
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyProtocolHeader(t *testing.T) {
+	signature := []byte("\r\n\r\n\x00\r\nQUIT\n")
+	originAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+
+	header := proxyProtocolHeader(net.ParseIP("203.0.113.7"), originAddr)
+	expected := append(append([]byte{}, signature...), 0x21, 0x11, 0, 12)
+	expected = append(expected, 203, 0, 113, 7, 10, 0, 0, 1, 0, 0, 0, 22)
+	assert.Equal(t, expected, header)
+
+	// The origin address is mapped to IPv6 when the client uses IPv6
+	header = proxyProtocolHeader(net.ParseIP("2001:db8::7"), originAddr)
+	expected = append(append([]byte{}, signature...), 0x21, 0x21, 0, 36)
+	expected = append(expected, net.ParseIP("2001:db8::7")...)
+	expected = append(expected, net.ParseIP("::ffff:10.0.0.1")...)
+	expected = append(expected, 0, 0, 0, 22)
+	assert.Equal(t, expected, header)
+
+	// Without the client address, the origin should use the address of the connection
+	header = proxyProtocolHeader(nil, originAddr)
+	assert.Equal(t, append(append([]byte{}, signature...), 0x20, 0, 0, 0), header)
+}
+
+func TestNewTCPServiceInvalidAddress(t *testing.T) {
+	for _, address := range []string{"localhost", "tcp://localhost:22", ""} {
+		_, err := NewTCPService(address, false)
+		assert.Error(t, err, address)
+	}
+}
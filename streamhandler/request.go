@@ -2,12 +2,17 @@ package streamhandler
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/cloudflare/cloudflared/h2mux"
+	"github.com/cloudflare/cloudflared/originservice"
 	"github.com/pkg/errors"
 )
 
@@ -67,3 +72,130 @@ func H2RequestHeadersToH1Request(h2 []h2mux.Header, h1 *http.Request) error {
 	}
 	return nil
 }
+
+// errAttemptAbandoned is returned by reads of a request body after the connection the attempt was using to proxy it
+// failed
+var errAttemptAbandoned = errors.New("connection to the origin closed while reading the request body")
+
+// retryableBody is a request body that can be proxied again, as long as the origin hasn't read anything from it.
+// Each attempt reads it through attempt(). The underlying body is read in its own goroutine, so that an attempt can
+// give up on a read that's waiting for the eyeball, and the next attempt gets the data instead.
+type retryableBody struct {
+	body io.ReadCloser
+	// readAttempted and bytesRead are accessed atomically, because http.Transport can read the body in its own
+	// goroutine
+	readAttempted int32
+	bytesRead     int64
+
+	// readLock serializes reads, and guards the fields below
+	readLock sync.Mutex
+	// reading is true while a read of body is in progress, its result is sent to readResults
+	reading     bool
+	readResults chan readResult
+	// pending is what has been read from body, but not returned to an attempt yet
+	pending    []byte
+	pendingErr error
+}
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+func newRetryableBody(body io.ReadCloser) *retryableBody {
+	return &retryableBody{
+		body:        body,
+		readResults: make(chan readResult, 1),
+	}
+}
+
+// attempt returns the body to send with an attempt to proxy the request
+func (rb *retryableBody) attempt() *attemptBody {
+	return &attemptBody{retryableBody: rb}
+}
+
+// read reads into p, unless abandoned is closed before anything can be read
+func (rb *retryableBody) read(p []byte, abandoned <-chan struct{}) (int, error) {
+	atomic.StoreInt32(&rb.readAttempted, 1)
+	rb.readLock.Lock()
+	defer rb.readLock.Unlock()
+	if len(rb.pending) == 0 && rb.pendingErr == nil {
+		if !rb.reading {
+			rb.reading = true
+			go rb.readBody(len(p))
+		}
+		select {
+		case result := <-rb.readResults:
+			rb.reading = false
+			rb.pending, rb.pendingErr = result.data, result.err
+		case <-abandoned:
+			return 0, errAttemptAbandoned
+		}
+	}
+	n := copy(p, rb.pending)
+	rb.pending = rb.pending[n:]
+	atomic.AddInt64(&rb.bytesRead, int64(n))
+	if len(rb.pending) > 0 {
+		return n, nil
+	}
+	// Errors, including io.EOF, are kept for the following reads
+	return n, rb.pendingErr
+}
+
+func (rb *retryableBody) readBody(size int) {
+	buf := make([]byte, size)
+	n, err := rb.body.Read(buf)
+	rb.readResults <- readResult{data: buf[:n], err: err}
+}
+
+func (rb *retryableBody) closeBody() error {
+	return rb.body.Close()
+}
+
+// canRetry reports whether the request can be proxied again. No part of the body can have been consumed, because it
+// cannot be replayed. Requests that aren't idempotent can only be retried if the origin didn't start reading them,
+// otherwise the origin might have acted on them already.
+func (rb *retryableBody) canRetry(method string) bool {
+	if atomic.LoadInt64(&rb.bytesRead) > 0 {
+		return false
+	}
+	return isIdempotent(method) || atomic.LoadInt32(&rb.readAttempted) == 0
+}
+
+// isIdempotent reports whether method is idempotent, see RFC 7231 section 4.2.2
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// attemptBody is the request body of one attempt to proxy a request
+type attemptBody struct {
+	*retryableBody
+	sync.Mutex
+	abandoned <-chan struct{}
+}
+
+func (ab *attemptBody) Read(p []byte) (int, error) {
+	ab.Lock()
+	abandoned := ab.abandoned
+	ab.Unlock()
+	return ab.read(p, abandoned)
+}
+
+// Close doesn't close the underlying body, because http.Transport closes the body even if the request failed.
+// retryableBody.closeBody closes it once no more attempts will be made.
+func (ab *attemptBody) Close() error {
+	return nil
+}
+
+// abandonOnClose makes reads give up once conn is closed. http.Transport waits for the request body to be read
+// before returning an error, even if the connection failed.
+func (ab *attemptBody) abandonOnClose(conn net.Conn) {
+	ab.Lock()
+	defer ab.Unlock()
+	ab.abandoned = originservice.ConnClosed(conn)
+}
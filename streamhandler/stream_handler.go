@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptrace"
 	"strconv"
+	"time"
 
+	"github.com/cloudflare/cloudflared/buildinfo"
 	"github.com/cloudflare/cloudflared/h2mux"
+	"github.com/cloudflare/cloudflared/originservice"
 	"github.com/cloudflare/cloudflared/tunnelhostnamemapper"
 	"github.com/cloudflare/cloudflared/tunnelrpc"
 	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
@@ -16,7 +20,9 @@ import (
 )
 
 const (
-	statusPseudoHeader = ":status"
+	statusPseudoHeader  = ":status"
+	initialRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff     = 5 * time.Second
 )
 
 type httpErrorStatus struct {
@@ -45,18 +51,22 @@ type StreamHandler struct {
 	useConfigResultChan <-chan *pogs.UseConfigurationResult
 	// originMapper maps tunnel hostname to origin service
 	tunnelHostnameMapper *tunnelhostnamemapper.TunnelHostnameMapper
-	logger               *logrus.Entry
+	// buildInfo is shown by the hello world origin service
+	buildInfo *buildinfo.BuildInfo
+	logger    *logrus.Entry
 }
 
 // NewStreamHandler creates a new StreamHandler
 func NewStreamHandler(newConfigChan chan<- *pogs.ClientConfig,
 	useConfigResultChan <-chan *pogs.UseConfigurationResult,
+	buildInfo *buildinfo.BuildInfo,
 	logger *logrus.Logger,
 ) *StreamHandler {
 	return &StreamHandler{
 		newConfigChan:        newConfigChan,
 		useConfigResultChan:  useConfigResultChan,
 		tunnelHostnameMapper: tunnelhostnamemapper.NewTunnelHostnameMapper(),
+		buildInfo:            buildInfo,
 		logger:               logger.WithField("subsystem", "streamHandler"),
 	}
 }
@@ -89,7 +99,8 @@ func (s *StreamHandler) UpdateConfig(newConfig []*pogs.ReverseProxyConfig) (fail
 		s.tunnelHostnameMapper.Delete(hostnameToRemove)
 	}
 
-	// Add new configs that weren't in the old mapper
+	// Add new configs that weren't in the old mapper, and replace the origin services of changed configs. If a
+	// changed config is invalid, the current origin service is kept.
 	toAdd := s.tunnelHostnameMapper.ToAdd(newConfig)
 	for _, tunnelConfig := range toAdd {
 		tunnelHostname := tunnelConfig.TunnelHostname
@@ -102,7 +113,7 @@ func (s *StreamHandler) UpdateConfig(newConfig []*pogs.ReverseProxyConfig) (fail
 			})
 			continue
 		}
-		s.tunnelHostnameMapper.Add(tunnelConfig.TunnelHostname, originSerice)
+		s.tunnelHostnameMapper.AddConfig(tunnelConfig, originSerice)
 		s.logger.WithField("tunnelHostname", tunnelHostname).Infof("New origin service config: %v", originSerice.Summary())
 	}
 	return
@@ -110,10 +121,16 @@ func (s *StreamHandler) UpdateConfig(newConfig []*pogs.ReverseProxyConfig) (fail
 
 // ServeStream implements MuxedStreamHandler interface
 func (s *StreamHandler) ServeStream(stream *h2mux.MuxedStream) error {
+	return s.ServeStreamAt(stream, "")
+}
+
+// ServeStreamAt serves a stream opened by the edge in location, the data center of the connection the stream was
+// opened on. It's empty if the location isn't known.
+func (s *StreamHandler) ServeStreamAt(stream *h2mux.MuxedStream, location string) error {
 	if stream.IsRPCStream() {
 		return s.serveRPC(stream)
 	}
-	if err := s.serveRequest(stream); err != nil {
+	if err := s.serveRequest(stream, location); err != nil {
 		s.logger.Error(err)
 		return err
 	}
@@ -132,29 +149,31 @@ func (s *StreamHandler) serveRPC(stream *h2mux.MuxedStream) error {
 	return rpcConn.Wait()
 }
 
-func (s *StreamHandler) serveRequest(stream *h2mux.MuxedStream) error {
+func (s *StreamHandler) serveRequest(stream *h2mux.MuxedStream, location string) error {
 	tunnelHostname := stream.TunnelHostname()
 	if !tunnelHostname.IsSet() {
 		s.writeErrorStatus(stream, statusBadRequest)
 		return fmt.Errorf("stream doesn't have tunnelHostname")
 	}
 
-	originService, ok := s.tunnelHostnameMapper.Get(tunnelHostname)
+	originService, reverseProxyConfig, release, ok := s.tunnelHostnameMapper.Acquire(tunnelHostname)
 	if !ok {
 		s.writeErrorStatus(stream, statusNotFound)
 		return fmt.Errorf("cannot map tunnel hostname %s to origin", tunnelHostname)
 	}
+	defer release()
 
 	req, err := createRequest(stream, originService.URL())
 	if err != nil {
 		s.writeErrorStatus(stream, statusBadRequest)
 		return errors.Wrap(err, "cannot create request")
 	}
+	req = req.WithContext(originservice.WithBuildInfo(originservice.WithLocation(req.Context(), location), s.buildInfo))
 
 	logger := s.requestLogger(req, tunnelHostname)
 	logger.Debugf("Request Headers %+v", req.Header)
 
-	resp, err := originService.Proxy(stream, req)
+	resp, err := s.proxy(originService, reverseProxyConfig, stream, req, logger)
 	if err != nil {
 		s.writeErrorStatus(stream, statusBadGateway)
 		return errors.Wrap(err, "cannot proxy request")
@@ -164,6 +183,56 @@ func (s *StreamHandler) serveRequest(stream *h2mux.MuxedStream) error {
 	return nil
 }
 
+// proxy proxies req to originService. If the request couldn't reach the origin, it's retried with exponential
+// backoff up to reverseProxyConfig.Retries times, as long as it can be sent again.
+func (s *StreamHandler) proxy(
+	originService originservice.OriginService,
+	reverseProxyConfig *pogs.ReverseProxyConfig,
+	stream *h2mux.MuxedStream,
+	req *http.Request,
+	logger *logrus.Entry,
+) (*http.Response, error) {
+	var retries uint64
+	if reverseProxyConfig != nil {
+		retries = reverseProxyConfig.Retries
+		req = req.WithContext(originservice.WithDialTimeout(req.Context(), reverseProxyConfig.ConnectionTimeout))
+	}
+	body := newRetryableBody(req.Body)
+	defer body.closeBody()
+
+	backoff := initialRetryBackoff
+	for attempt := uint64(1); ; attempt++ {
+		logger.Debugf("Proxying request, attempt %d of %d", attempt, retries+1)
+		attemptBody := body.attempt()
+		attemptReq := req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				attemptBody.abandonOnClose(info.Conn)
+			},
+		}))
+		attemptReq.Body = attemptBody
+		resp, err := originService.Proxy(stream, attemptReq)
+		if err == nil {
+			return resp, nil
+		}
+		if _, ok := err.(originservice.RoundTripError); !ok || attempt > retries || !body.canRetry(req.Method) {
+			return nil, err
+		}
+		if stream.Context().Err() != nil {
+			// The eyeball went away, so there's no one to respond to
+			return nil, err
+		}
+		logger.WithError(err).Warnf("Attempt %d of %d to reach the origin failed, retrying in %v", attempt, retries+1, backoff)
+		select {
+		case <-stream.Context().Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
 func (s *StreamHandler) requestLogger(req *http.Request, tunnelHostname h2mux.TunnelHostname) *logrus.Entry {
 	cfRay := FindCfRayHeader(req)
 	lbProbe := IsLBProbeRequest(req)
@@ -2,6 +2,7 @@ package streamhandler
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net"
 	"net/http"
@@ -11,11 +12,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cloudflare/cloudflared/buildinfo"
 	"github.com/cloudflare/cloudflared/h2mux"
 	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -41,7 +44,7 @@ var (
 func TestServeRequest(t *testing.T) {
 	configChan := make(chan *pogs.ClientConfig)
 	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
-	streamHandler := NewStreamHandler(configChan, useConfigResultChan, logrus.New())
+	streamHandler := NewStreamHandler(configChan, useConfigResultChan, nil, logrus.New())
 
 	message := []byte("Hello cloudflared")
 	httpServer := httptest.NewServer(&mockHTTPHandler{message})
@@ -71,10 +74,522 @@ func TestServeRequest(t *testing.T) {
 	assertRespBody(t, message, stream)
 }
 
+func TestUpdateConfigReplacesChangedOrigin(t *testing.T) {
+	configChan := make(chan *pogs.ClientConfig)
+	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
+	streamHandler := NewStreamHandler(configChan, useConfigResultChan, nil, logrus.New())
+
+	firstMessage := []byte("Hello from the first origin")
+	firstServer := httptest.NewServer(&mockHTTPHandler{firstMessage})
+	defer firstServer.Close()
+	secondMessage := []byte("Hello from the second origin")
+	secondServer := httptest.NewServer(&mockHTTPHandler{secondMessage})
+	defer secondServer.Close()
+
+	newConfigs := func(url string) []*pogs.ReverseProxyConfig {
+		return []*pogs.ReverseProxyConfig{
+			{
+				TunnelHostname: testTunnelHostname,
+				OriginConfigJSONHandler: &pogs.OriginConfigJSONHandler{
+					OriginConfig: &pogs.HTTPOriginConfig{
+						URLString: url,
+					},
+				},
+			},
+		}
+	}
+	assert.Empty(t, streamHandler.UpdateConfig(newConfigs(firstServer.URL)))
+
+	muxPair := NewDefaultMuxerPair(t, streamHandler)
+	muxPair.Serve(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+	defer cancel()
+
+	headers := append(baseHeaders, tunnelHostnameHeader)
+	stream, err := muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+	assert.NoError(t, err)
+	assertStatusHeader(t, http.StatusOK, stream.Headers)
+	assertRespBody(t, firstMessage, stream)
+
+	// Same tunnel hostname with a different origin URL
+	assert.Empty(t, streamHandler.UpdateConfig(newConfigs(secondServer.URL)))
+	stream, err = muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+	assert.NoError(t, err)
+	assertStatusHeader(t, http.StatusOK, stream.Headers)
+	assertRespBody(t, secondMessage, stream)
+
+	// A changed config that is invalid keeps the current origin
+	invalidConfigs := newConfigs(firstServer.URL)
+	invalidConfigs[0].OriginConfigJSONHandler.OriginConfig.(*pogs.HTTPOriginConfig).OriginCAPool = "/nonexistent/ca.pem"
+	assert.Len(t, streamHandler.UpdateConfig(invalidConfigs), 1)
+	stream, err = muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+	assert.NoError(t, err)
+	assertStatusHeader(t, http.StatusOK, stream.Headers)
+	assertRespBody(t, secondMessage, stream)
+}
+
+func TestServeRequestCancelledByReset(t *testing.T) {
+	cancelledC := make(chan struct{})
+	// The origin responds with headers, then waits for the request to be cancelled
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(cancelledC)
+	}))
+	defer httpServer.Close()
+
+	configChan := make(chan *pogs.ClientConfig)
+	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
+	streamHandler := NewStreamHandler(configChan, useConfigResultChan, nil, logrus.New())
+	streamHandler.UpdateConfig([]*pogs.ReverseProxyConfig{
+		{
+			TunnelHostname: testTunnelHostname,
+			OriginConfigJSONHandler: &pogs.OriginConfigJSONHandler{
+				OriginConfig: &pogs.HTTPOriginConfig{
+					URLString: httpServer.URL,
+				},
+			},
+		},
+	})
+
+	muxPair := NewDefaultMuxerPair(t, streamHandler)
+	muxPair.Serve(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+	defer cancel()
+
+	headers := append(baseHeaders, tunnelHostnameHeader)
+	stream, err := muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+	assert.NoError(t, err)
+	assertStatusHeader(t, http.StatusOK, stream.Headers)
+
+	stream.Reset(http2.ErrCodeCancel)
+	select {
+	case <-cancelledC:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request to the origin wasn't cancelled when the stream was reset")
+	}
+}
+
+func TestServeRequestStopsRetryingWhenStreamEnds(t *testing.T) {
+	// Every connection to the origin is closed before it responds
+	listener := &flakyListener{failures: 100, acceptedC: make(chan struct{}, 100)}
+	httpServer := httptest.NewUnstartedServer(&mockHTTPHandler{})
+	listener.Listener = httpServer.Listener
+	httpServer.Listener = listener
+	httpServer.Start()
+	defer httpServer.Close()
+
+	configChan := make(chan *pogs.ClientConfig)
+	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
+	streamHandler := NewStreamHandler(configChan, useConfigResultChan, nil, logrus.New())
+	assert.Empty(t, streamHandler.UpdateConfig([]*pogs.ReverseProxyConfig{
+		{
+			TunnelHostname: testTunnelHostname,
+			OriginConfigJSONHandler: &pogs.OriginConfigJSONHandler{
+				OriginConfig: &pogs.HTTPOriginConfig{
+					URLString: httpServer.URL,
+				},
+			},
+			Retries:           5,
+			ConnectionTimeout: time.Second,
+		},
+	}))
+
+	muxPair := NewDefaultMuxerPair(t, streamHandler)
+	muxPair.Serve(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+	defer cancel()
+	headers := append(baseHeaders, tunnelHostnameHeader)
+	go muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+
+	<-listener.acceptedC
+	// The connection to the edge dies while waiting to retry
+	muxPair.EdgeConn.Close()
+	select {
+	case <-listener.acceptedC:
+		t.Fatal("request was retried after its stream ended")
+	case <-time.After(initialRetryBackoff * 3):
+	}
+}
+
+func TestServeRequestRetries(t *testing.T) {
+	message := []byte("Hello after retrying")
+	// The first 2 connections to the origin are closed before it responds
+	listener := &flakyListener{failures: 2}
+	httpServer := httptest.NewUnstartedServer(&mockHTTPHandler{message})
+	listener.Listener = httpServer.Listener
+	httpServer.Listener = listener
+	httpServer.Start()
+	defer httpServer.Close()
+
+	configChan := make(chan *pogs.ClientConfig)
+	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
+	streamHandler := NewStreamHandler(configChan, useConfigResultChan, nil, logrus.New())
+	newConfigs := func(retries uint64) []*pogs.ReverseProxyConfig {
+		return []*pogs.ReverseProxyConfig{
+			{
+				TunnelHostname: testTunnelHostname,
+				OriginConfigJSONHandler: &pogs.OriginConfigJSONHandler{
+					OriginConfig: &pogs.HTTPOriginConfig{
+						URLString: httpServer.URL,
+					},
+				},
+				Retries:           retries,
+				ConnectionTimeout: time.Second,
+			},
+		}
+	}
+
+	muxPair := NewDefaultMuxerPair(t, streamHandler)
+	muxPair.Serve(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+	defer cancel()
+	headers := append(baseHeaders, tunnelHostnameHeader)
+
+	// 1 retry isn't enough to get past 2 failures
+	assert.Empty(t, streamHandler.UpdateConfig(newConfigs(1)))
+	stream, err := muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+	assert.NoError(t, err)
+	assertStatusHeader(t, http.StatusBadGateway, stream.Headers)
+
+	listener.reset(2)
+	assert.Empty(t, streamHandler.UpdateConfig(newConfigs(2)))
+	stream, err = muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+	assert.NoError(t, err)
+	assertStatusHeader(t, http.StatusOK, stream.Headers)
+	assertRespBody(t, message, stream)
+}
+
+// The origin hangs up while the request body is still being sent by the eyeball
+func TestServeRequestRetriesWithUnfinishedBody(t *testing.T) {
+	message := []byte("Hello after retrying")
+	handler := &hangUpHandler{mockHTTPHandler: mockHTTPHandler{message}}
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+
+	configChan := make(chan *pogs.ClientConfig)
+	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
+	streamHandler := NewStreamHandler(configChan, useConfigResultChan, nil, logrus.New())
+	assert.Empty(t, streamHandler.UpdateConfig([]*pogs.ReverseProxyConfig{
+		{
+			TunnelHostname: testTunnelHostname,
+			OriginConfigJSONHandler: &pogs.OriginConfigJSONHandler{
+				OriginConfig: &pogs.HTTPOriginConfig{
+					URLString: httpServer.URL,
+				},
+			},
+			Retries:           2,
+			ConnectionTimeout: time.Second,
+		},
+	}))
+
+	muxPair := NewDefaultMuxerPair(t, streamHandler)
+	muxPair.Serve(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+	defer cancel()
+
+	tests := []struct {
+		method string
+		status int
+	}{
+		// The origin might have seen the start of the request
+		{method: http.MethodPost, status: http.StatusBadGateway},
+		{method: http.MethodGet, status: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			handler.reset(1)
+			headers := []h2mux.Header{
+				{Name: ":method", Value: test.method},
+				{Name: ":scheme", Value: "http"},
+				{Name: ":authority", Value: "example.com"},
+				{Name: ":path", Value: "/"},
+				tunnelHostnameHeader,
+			}
+			// The request body is never ended
+			stream, err := muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+			if assert.NoError(t, err) {
+				assertStatusHeader(t, test.status, stream.Headers)
+			}
+		})
+	}
+}
+
+func TestRetryableBodyCanRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		readAttempted bool
+		bytesRead     int64
+		canRetry      bool
+	}{
+		{name: "unread_post", method: http.MethodPost, canRetry: true},
+		{name: "started_post", method: http.MethodPost, readAttempted: true},
+		{name: "started_get", method: http.MethodGet, readAttempted: true, canRetry: true},
+		{name: "consumed_put", method: http.MethodPut, readAttempted: true, bytesRead: 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := newRetryableBody(nil)
+			if test.readAttempted {
+				body.readAttempted = 1
+			}
+			body.bytesRead = test.bytesRead
+			assert.Equal(t, test.canRetry, body.canRetry(test.method))
+		})
+	}
+}
+
+func TestRetryableBodyAbandonedRead(t *testing.T) {
+	pr, pw := io.Pipe()
+	body := newRetryableBody(pr)
+	defer body.closeBody()
+
+	// The first attempt gives up waiting for the eyeball
+	abandoned := make(chan struct{})
+	close(abandoned)
+	buf := make([]byte, 16)
+	n, err := body.read(buf, abandoned)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, errAttemptAbandoned, err)
+	assert.True(t, body.canRetry(http.MethodGet))
+
+	// The next attempt gets what the eyeball sent, even though the read was started by the first attempt
+	go pw.Write([]byte("hello"))
+	n, err = body.attempt().Read(buf[:3])
+	assert.NoError(t, err)
+	assert.Equal(t, "hel", string(buf[:n]))
+	n, err = body.attempt().Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "lo", string(buf[:n]))
+
+	pw.Close()
+	_, err = body.attempt().Read(buf)
+	assert.Equal(t, io.EOF, err)
+	assert.False(t, body.canRetry(http.MethodGet))
+}
+
+// flakyListener closes the first connections it accepts
+type flakyListener struct {
+	net.Listener
+	sync.Mutex
+	failures int
+	// acceptedC, if set, receives a value for every connection accepted
+	acceptedC chan struct{}
+}
+
+func (fl *flakyListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := fl.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if fl.acceptedC != nil {
+			fl.acceptedC <- struct{}{}
+		}
+		fl.Lock()
+		fail := fl.failures > 0
+		fl.failures--
+		fl.Unlock()
+		if !fail {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
+
+func (fl *flakyListener) reset(failures int) {
+	fl.Lock()
+	defer fl.Unlock()
+	fl.failures = failures
+}
+
+// hangUpHandler closes the connection of the first requests it receives, without responding
+type hangUpHandler struct {
+	mockHTTPHandler
+	sync.Mutex
+	failures int
+}
+
+func (huh *hangUpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	huh.Lock()
+	fail := huh.failures > 0
+	huh.failures--
+	huh.Unlock()
+	if !fail {
+		huh.mockHTTPHandler.ServeHTTP(w, r)
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		panic(err)
+	}
+	conn.Close()
+}
+
+func (huh *hangUpHandler) reset(failures int) {
+	huh.Lock()
+	defer huh.Unlock()
+	huh.failures = failures
+}
+
+func TestServeHelloWorld(t *testing.T) {
+	configChan := make(chan *pogs.ClientConfig)
+	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
+	streamHandler := NewStreamHandler(configChan, useConfigResultChan, buildinfo.GetBuildInfo("test"), logrus.New())
+
+	reverseProxyConfigs := []*pogs.ReverseProxyConfig{
+		{
+			TunnelHostname: testTunnelHostname,
+			OriginConfigJSONHandler: &pogs.OriginConfigJSONHandler{
+				OriginConfig: &pogs.HelloWorldOriginConfig{},
+			},
+		},
+	}
+	assert.Empty(t, streamHandler.UpdateConfig(reverseProxyConfigs))
+
+	// The connection is to the edge in SFO
+	muxPair := NewDefaultMuxerPair(t, h2mux.MuxedStreamFunc(func(stream *h2mux.MuxedStream) error {
+		return streamHandler.ServeStreamAt(stream, "SFO")
+	}))
+	muxPair.Serve(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+	defer cancel()
+
+	headers := []h2mux.Header{
+		{Name: ":method", Value: "GET"},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: "example.com"},
+		{Name: ":path", Value: "/json"},
+		tunnelHostnameHeader,
+	}
+	stream, err := muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+	assert.NoError(t, err)
+	assertStatusHeader(t, http.StatusOK, stream.Headers)
+	var info map[string]interface{}
+	assert.NoError(t, json.NewDecoder(stream).Decode(&info))
+	assert.Equal(t, testTunnelHostname.String(), info["tunnel_hostname"])
+	assert.Equal(t, "SFO", info["location"])
+	assert.Equal(t, "test", info["build_info"].(map[string]interface{})["cloudflared_version"])
+
+	// The websocket route echoes every message
+	headers = []h2mux.Header{
+		{Name: ":method", Value: "GET"},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: "example.com"},
+		{Name: ":path", Value: "/ws"},
+		{Name: "connection", Value: "Upgrade"},
+		{Name: "upgrade", Value: "websocket"},
+		{Name: "sec-websocket-version", Value: "13"},
+		{Name: "sec-websocket-key", Value: "dGhlIHNhbXBsZSBub25jZQ=="},
+		tunnelHostnameHeader,
+	}
+	stream, err = muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+	assert.NoError(t, err)
+	assertStatusHeader(t, http.StatusSwitchingProtocols, stream.Headers)
+	assert.Contains(t, stream.Headers, h2mux.Header{Name: "sec-websocket-accept", Value: "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="})
+
+	message := []byte("echo")
+	mask := []byte{1, 2, 3, 4}
+	// A final text frame, which must be masked because it is sent by the client
+	frame := append([]byte{0x81, 0x80 | byte(len(message))}, mask...)
+	for i, b := range message {
+		frame = append(frame, b^mask[i%len(mask)])
+	}
+	_, err = stream.Write(frame)
+	assert.NoError(t, err)
+	assertRespBody(t, append([]byte{0x81, byte(len(message))}, message...), stream)
+}
+
+func TestServeTCP(t *testing.T) {
+	// The origin echoes everything after the PROXY protocol header
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	proxyHeaderC := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		proxyHeader := make([]byte, 28)
+		if _, err := io.ReadFull(conn, proxyHeader); err != nil {
+			return
+		}
+		proxyHeaderC <- proxyHeader
+		io.Copy(conn, conn)
+	}()
+
+	configChan := make(chan *pogs.ClientConfig)
+	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
+	streamHandler := NewStreamHandler(configChan, useConfigResultChan, nil, logrus.New())
+	reverseProxyConfigs := []*pogs.ReverseProxyConfig{
+		{
+			TunnelHostname: testTunnelHostname,
+			OriginConfigJSONHandler: &pogs.OriginConfigJSONHandler{
+				OriginConfig: &pogs.TCPOriginConfig{Address: listener.Addr().String(), ProxyProtocol: true},
+			},
+		},
+	}
+	assert.Empty(t, streamHandler.UpdateConfig(reverseProxyConfigs))
+
+	muxPair := NewDefaultMuxerPair(t, streamHandler)
+	muxPair.Serve(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testOpenStreamTimeout)
+	defer cancel()
+
+	headers := []h2mux.Header{
+		{Name: ":method", Value: "GET"},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: "example.com"},
+		{Name: ":path", Value: "/"},
+		{Name: "connection", Value: "Upgrade"},
+		{Name: "upgrade", Value: "websocket"},
+		{Name: "sec-websocket-version", Value: "13"},
+		{Name: "sec-websocket-key", Value: "dGhlIHNhbXBsZSBub25jZQ=="},
+		{Name: "cf-connecting-ip", Value: "203.0.113.7"},
+		tunnelHostnameHeader,
+	}
+	stream, err := muxPair.EdgeMux.OpenStream(ctx, headers, nil)
+	assert.NoError(t, err)
+	assertStatusHeader(t, http.StatusSwitchingProtocols, stream.Headers)
+
+	select {
+	case proxyHeader := <-proxyHeaderC:
+		// TCP over IPv4 from the client address
+		assert.Equal(t, []byte("\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x0c"), proxyHeader[:16])
+		assert.Equal(t, []byte{203, 0, 113, 7, 127, 0, 0, 1}, proxyHeader[16:24])
+	case <-ctx.Done():
+		t.Fatal("origin didn't receive a PROXY protocol header")
+	}
+
+	message := []byte("SSH-2.0-OpenSSH_7.9")
+	mask := []byte{1, 2, 3, 4}
+	// A final binary frame, which must be masked because it is sent by the client
+	frame := append([]byte{0x82, 0x80 | byte(len(message))}, mask...)
+	for i, b := range message {
+		frame = append(frame, b^mask[i%len(mask)])
+	}
+	_, err = stream.Write(frame)
+	assert.NoError(t, err)
+	assertRespBody(t, append([]byte{0x82, byte(len(message))}, message...), stream)
+}
+
 func TestServeBadRequest(t *testing.T) {
 	configChan := make(chan *pogs.ClientConfig)
 	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
-	streamHandler := NewStreamHandler(configChan, useConfigResultChan, logrus.New())
+	streamHandler := NewStreamHandler(configChan, useConfigResultChan, nil, logrus.New())
 
 	muxPair := NewDefaultMuxerPair(t, streamHandler)
 	muxPair.Serve(t)
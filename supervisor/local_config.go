@@ -0,0 +1,112 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultConfigPollInterval = 5 * time.Second
+
+// LocalConfigLoader loads the ClientConfig defined locally, e.g. by a config file and command line options
+type LocalConfigLoader func() (*pogs.ClientConfig, error)
+
+// localConfigWatcher triggers a reload of the local config on SIGHUP, or when the config file changes
+type localConfigWatcher struct {
+	path         string
+	load         LocalConfigLoader
+	pollInterval time.Duration
+	logger       *logrus.Entry
+}
+
+// WatchLocalConfig makes Supervisor reload the local config with load when it receives SIGHUP, or when the
+// file at path changes. path can be empty if the config only comes from command line options. It must be
+// called before Run.
+func (s *Supervisor) WatchLocalConfig(path string, load LocalConfigLoader) {
+	s.localConfigWatcher = &localConfigWatcher{
+		path:         path,
+		load:         load,
+		pollInterval: defaultConfigPollInterval,
+		logger:       s.logger,
+	}
+}
+
+// run sends to reloadChan every time the local config should be reloaded, until ctx is done
+func (w *localConfigWatcher) run(ctx context.Context, reloadChan chan<- struct{}) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	lastModified := w.modTime()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-signals:
+			w.logger.Info("Received SIGHUP, reloading local config")
+		case <-ticker.C:
+			modified := w.modTime()
+			if modified.Equal(lastModified) {
+				continue
+			}
+			lastModified = modified
+			w.logger.Infof("%s has changed, reloading local config", w.path)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case reloadChan <- struct{}{}:
+		}
+	}
+}
+
+// modTime returns when the config file was last modified, or the zero time if it cannot be determined
+func (w *localConfigWatcher) modTime() time.Time {
+	if w.path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reloadLocalConfig loads, validates and applies the local config. If the config cannot be loaded, is invalid or
+// cannot be applied, the current config is left in place. The version of the last config from the edge isn't
+// changed.
+func (s *Supervisor) reloadLocalConfig() {
+	newConfig, err := s.localConfigWatcher.load()
+	if err != nil {
+		s.logger.WithError(err).Error("Cannot reload local config, keeping the current config")
+		return
+	}
+	if failedConfigs := config.Validate(newConfig); len(failedConfigs) > 0 {
+		s.logFailedConfigs(failedConfigs)
+		s.logger.Error("Local config is invalid, keeping the current config")
+		return
+	}
+	result := s.applyConfig(newConfig)
+	if !result.Success {
+		s.logFailedConfigs(result.FailedConfigs)
+		s.logger.Error("Cannot apply local config, keeping the current config")
+		return
+	}
+	s.logger.Info("Applied local config")
+}
+
+func (s *Supervisor) logFailedConfigs(failedConfigs []*pogs.FailedConfig) {
+	for _, failedConfig := range failedConfigs {
+		s.logger.Errorf("Config %+v failed, reason: %s", failedConfig.Config, failedConfig.Reason)
+	}
+}
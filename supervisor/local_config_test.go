@@ -0,0 +1,125 @@
+package supervisor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalConfigWatcherFileChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloudflared-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("version: 1\n"), 0600))
+
+	w := &localConfigWatcher{
+		path:         path,
+		pollInterval: 10 * time.Millisecond,
+		logger:       logrus.NewEntry(logrus.New()),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	reloadChan := make(chan struct{})
+	errChan := make(chan error)
+	go func() {
+		errChan <- w.run(ctx, reloadChan)
+	}()
+
+	select {
+	case <-reloadChan:
+		t.Fatal("reload triggered before the config file changed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	modified := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, modified, modified))
+	select {
+	case <-reloadChan:
+	case <-time.After(time.Second):
+		t.Fatal("reload was not triggered after the config file changed")
+	}
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-errChan)
+}
+
+func TestReloadLocalConfigKeepsEdgeVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloudflared-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("supervisor_config:\n  grace_period: 2s\n"), 0600))
+
+	s := newTestSupervisor(t, &pogs.ClientConfig{Version: pogs.InitVersion()})
+	defer s.dohProxyManager.Shutdown()
+	s.WatchLocalConfig(path, func() (*pogs.ClientConfig, error) {
+		clientConfig, _, err := config.Load(path)
+		return clientConfig, err
+	})
+	s.reloadLocalConfig()
+	assert.Equal(t, 2*time.Second, s.state.getSupervisorConfig().GracePeriod)
+
+	// The first config from the edge is still applied after the reload
+	edgeConfig := &pogs.ClientConfig{
+		Version:          pogs.InitVersion() + 1,
+		SupervisorConfig: &pogs.SupervisorConfig{GracePeriod: time.Minute},
+	}
+	assert.True(t, s.notifySubsystemsNewConfig(edgeConfig).Success)
+	assert.Equal(t, edgeConfig, s.state.getConfig())
+	assert.True(t, s.state.hasAppliedVersion(edgeConfig.Version))
+}
+
+func TestReloadInvalidLocalConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloudflared-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "unparseable", config: "supervisor_config: [\n"},
+		{name: "invalid", config: "edge_connection_config:\n  num_ha_connections: 0\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.name+".yml")
+			assert.NoError(t, ioutil.WriteFile(path, []byte(test.config), 0600))
+			currentConfig := &pogs.ClientConfig{
+				Version:          3,
+				SupervisorConfig: &pogs.SupervisorConfig{MetricsUpdateFrequency: time.Second},
+			}
+			// The subsystems are left nil, so the test panics if the config is sent to them
+			s := &Supervisor{
+				metricsUpdateFreqChan: make(chan time.Duration, 1),
+				localConfigWatcher: &localConfigWatcher{
+					path: path,
+					load: func() (*pogs.ClientConfig, error) {
+						clientConfig, _, err := config.Load(path)
+						return clientConfig, err
+					},
+				},
+				state:  newState(currentConfig),
+				logger: logrus.NewEntry(logrus.New()),
+			}
+
+			s.reloadLocalConfig()
+			assert.Equal(t, currentConfig, s.state.currentConfig)
+			assert.Equal(t, pogs.Version(3), s.state.edgeVersion)
+			select {
+			case frequency := <-s.metricsUpdateFreqChan:
+				t.Fatalf("metrics update frequency %v was sent for an invalid config", frequency)
+			default:
+			}
+		})
+	}
+}
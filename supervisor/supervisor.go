@@ -8,10 +8,12 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/dohproxy"
 	"github.com/cloudflare/cloudflared/h2mux"
 	"github.com/cloudflare/cloudflared/streamhandler"
 	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
@@ -21,23 +23,28 @@ import (
 type Supervisor struct {
 	connManager         *connection.EdgeManager
 	streamHandler       *streamhandler.StreamHandler
+	dohProxyManager     *dohproxy.Manager
 	newConfigChan       <-chan *pogs.ClientConfig
 	useConfigResultChan chan<- *pogs.UseConfigurationResult
-	state               *state
-	logger              *logrus.Entry
+	// metricsUpdateFreqChan receives the metrics update frequency of every new SupervisorConfig
+	metricsUpdateFreqChan chan time.Duration
+	localConfigWatcher    *localConfigWatcher
+	state                 *state
+	logger                *logrus.Entry
 }
 
 func NewSupervisor(
 	defaultClientConfig *pogs.ClientConfig,
 	userCredential []byte,
 	tlsConfig *tls.Config,
+	edgeDialer connection.EdgeDialer,
 	serviceDiscoverer connection.EdgeServiceDiscoverer,
 	cloudflaredConfig *connection.CloudflaredConfig,
 	logger *logrus.Logger,
 ) (*Supervisor, error) {
 	newConfigChan := make(chan *pogs.ClientConfig)
 	useConfigResultChan := make(chan *pogs.UseConfigurationResult)
-	streamHandler := streamhandler.NewStreamHandler(newConfigChan, useConfigResultChan, logger)
+	streamHandler := streamhandler.NewStreamHandler(newConfigChan, useConfigResultChan, cloudflaredConfig.BuildInfo, logger)
 	invalidConfigs := streamHandler.UpdateConfig(defaultClientConfig.ReverseProxyConfigs)
 
 	if len(invalidConfigs) > 0 {
@@ -47,6 +54,15 @@ func NewSupervisor(
 		return nil, fmt.Errorf("At least 1 Tunnel config is invalid")
 	}
 
+	dohProxyManager := dohproxy.NewManager(logger)
+	if failedConfigs := dohProxyManager.UpdateConfig(defaultClientConfig.DoHProxyConfigs); len(failedConfigs) > 0 {
+		dohProxyManager.Shutdown()
+		for _, failedConfig := range failedConfigs {
+			logger.Errorf("DNS over HTTPS proxy %+v is invalid, reason: %s", failedConfig.Config, failedConfig.Reason)
+		}
+		return nil, fmt.Errorf("At least 1 DNS over HTTPS proxy config is invalid")
+	}
+
 	tunnelHostnames := make([]h2mux.TunnelHostname, len(defaultClientConfig.ReverseProxyConfigs))
 	for i, reverseProxyConfig := range defaultClientConfig.ReverseProxyConfigs {
 		tunnelHostnames[i] = reverseProxyConfig.TunnelHostname
@@ -56,60 +72,138 @@ func NewSupervisor(
 		EdgeConnectionConfig: defaultClientConfig.EdgeConnectionConfig,
 	}
 	return &Supervisor{
-		connManager: connection.NewEdgeManager(streamHandler, defaultEdgeMgrConfigurable, userCredential, tlsConfig,
-			serviceDiscoverer, cloudflaredConfig, logger),
-		streamHandler:       streamHandler,
-		newConfigChan:       newConfigChan,
-		useConfigResultChan: useConfigResultChan,
-		state:               newState(defaultClientConfig),
-		logger:              logger.WithField("subsystem", "supervisor"),
+		connManager: connection.NewEdgeManager(streamHandler, streamHandler, defaultEdgeMgrConfigurable, userCredential, tlsConfig,
+			edgeDialer, serviceDiscoverer, cloudflaredConfig, logger),
+		streamHandler:         streamHandler,
+		dohProxyManager:       dohProxyManager,
+		newConfigChan:         newConfigChan,
+		useConfigResultChan:   useConfigResultChan,
+		metricsUpdateFreqChan: make(chan time.Duration, 1),
+		state:                 newState(defaultClientConfig),
+		logger:                logger.WithField("subsystem", "supervisor"),
 	}, nil
 }
 
 func (s *Supervisor) Run(ctx context.Context) error {
+	defer s.dohProxyManager.Shutdown()
 	errGroup, groupCtx := errgroup.WithContext(ctx)
 
 	errGroup.Go(func() error {
 		return s.connManager.Run(groupCtx)
 	})
 
+	// reloadLocalConfigChan stays nil, so it never fires, if the local config isn't watched
+	var reloadLocalConfigChan chan struct{}
+	if s.localConfigWatcher != nil {
+		reloadLocalConfigChan = make(chan struct{})
+		errGroup.Go(func() error {
+			return s.localConfigWatcher.run(groupCtx, reloadLocalConfigChan)
+		})
+	}
+
 	errGroup.Go(func() error {
-		return s.listenToNewConfig(groupCtx)
+		return s.listenToNewConfig(groupCtx, reloadLocalConfigChan)
 	})
 
 	errGroup.Go(func() error {
-		return s.listenToShutdownSignal(groupCtx)
+		return s.updateMetrics(groupCtx)
+	})
+
+	shutdownSignals := make(chan os.Signal, 10)
+	signal.Notify(shutdownSignals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(shutdownSignals)
+	errGroup.Go(func() error {
+		return s.listenToShutdownSignal(groupCtx, shutdownSignals)
 	})
 
 	err := errGroup.Wait()
 	s.logger.Warnf("Supervisor terminated, reason: %v", err)
+	// Shutting down because of a signal is the expected way for Supervisor to terminate
+	if _, ok := err.(signalError); ok {
+		return nil
+	}
 	return err
 }
 
-func (s *Supervisor) listenToShutdownSignal(serveCtx context.Context) error {
-	signals := make(chan os.Signal, 10)
-	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
-	defer signal.Stop(signals)
+// listenToShutdownSignal returns when Supervisor should terminate. On the first SIGTERM/SIGINT, connections
+// stop accepting new requests, and in-flight requests have GracePeriod to finish. A second signal terminates
+// Supervisor immediately.
+func (s *Supervisor) listenToShutdownSignal(serveCtx context.Context, signals <-chan os.Signal) error {
+	var sig os.Signal
+	select {
+	case <-serveCtx.Done():
+		return serveCtx.Err()
+	case sig = <-signals:
+	}
+
+	gracePeriod := s.state.getSupervisorConfig().GracePeriod
+	if gracePeriod <= 0 {
+		return signalError{sig: sig}
+	}
+	s.logger.Infof("Received %v, waiting up to %v for in-flight requests to finish. Send the signal again to exit immediately", sig, gracePeriod)
+	gracePeriodCtx, cancel := context.WithTimeout(serveCtx, gracePeriod)
+	defer cancel()
+	drainedC := make(chan struct{})
+	go func() {
+		s.connManager.Unregister(gracePeriodCtx, gracePeriod)
+		close(drainedC)
+	}()
 
 	select {
 	case <-serveCtx.Done():
 		return serveCtx.Err()
-	case sig := <-signals:
-		return fmt.Errorf("received %v signal", sig)
+	case <-drainedC:
+		s.logger.Info("In-flight requests finished")
+		return signalError{sig: sig}
+	case <-gracePeriodCtx.Done():
+		return signalError{sig: sig}
+	case sig = <-signals:
+		s.logger.Warnf("Received %v again, exiting without waiting for in-flight requests", sig)
+		return signalError{sig: sig}
 	}
 }
 
-func (s *Supervisor) listenToNewConfig(ctx context.Context) error {
+// updateMetrics updates the metrics of edge connections every MetricsUpdateFrequency. The frequency changes when
+// a new SupervisorConfig is applied. A frequency of 0 disables metrics updates.
+func (s *Supervisor) updateMetrics(ctx context.Context) error {
+	frequency := s.state.getSupervisorConfig().MetricsUpdateFrequency
+	ticker := newMetricsTicker(frequency)
+	defer func() {
+		ticker.stop()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.c:
+			s.connManager.UpdateMetrics()
+		case newFrequency := <-s.metricsUpdateFreqChan:
+			if newFrequency == frequency {
+				continue
+			}
+			s.logger.Infof("Metrics update frequency changed from %v to %v", frequency, newFrequency)
+			frequency = newFrequency
+			ticker.stop()
+			ticker = newMetricsTicker(frequency)
+		}
+	}
+}
+
+// listenToNewConfig applies configs from the edge and reloads of the local config one at a time
+func (s *Supervisor) listenToNewConfig(ctx context.Context, reloadLocalConfigChan <-chan struct{}) error {
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case newConfig := <-s.newConfigChan:
 			s.useConfigResultChan <- s.notifySubsystemsNewConfig(newConfig)
+		case <-reloadLocalConfigChan:
+			s.reloadLocalConfig()
 		}
 	}
 }
 
+// notifySubsystemsNewConfig applies a config pushed by the edge, unless its version has already been applied
 func (s *Supervisor) notifySubsystemsNewConfig(newConfig *pogs.ClientConfig) *pogs.UseConfigurationResult {
 	s.logger.Infof("Received configuration %v", newConfig.Version)
 	if s.state.hasAppliedVersion(newConfig.Version) {
@@ -118,8 +212,37 @@ func (s *Supervisor) notifySubsystemsNewConfig(newConfig *pogs.ClientConfig) *po
 			Success: true,
 		}
 	}
+	result := s.applyConfig(newConfig)
+	if result.Success {
+		s.state.updateEdgeVersion(newConfig.Version)
+	}
+	return result
+}
+
+// applyConfig applies newConfig to every subsystem, and makes it the current config. If a subsystem cannot apply
+// it, the current config is applied again and kept.
+func (s *Supervisor) applyConfig(newConfig *pogs.ClientConfig) *pogs.UseConfigurationResult {
+	// Keep the current SupervisorConfig if the new config doesn't have one
+	if newConfig.SupervisorConfig == nil {
+		newConfig.SupervisorConfig = s.state.getSupervisorConfig()
+	}
+
+	// Update streamHandler tunnelHostnameMapper mapping
+	failedConfigs := s.streamHandler.UpdateConfig(newConfig.ReverseProxyConfigs)
+	// Start and stop DNS over HTTPS proxies
+	failedConfigs = append(failedConfigs, s.dohProxyManager.UpdateConfig(newConfig.DoHProxyConfigs)...)
+	if len(failedConfigs) > 0 {
+		currentConfig := s.state.getConfig()
+		s.streamHandler.UpdateConfig(currentConfig.ReverseProxyConfigs)
+		s.dohProxyManager.UpdateConfig(currentConfig.DoHProxyConfigs)
+		return &pogs.UseConfigurationResult{
+			Success:       false,
+			FailedConfigs: failedConfigs,
+		}
+	}
 
 	s.state.updateConfig(newConfig)
+	s.notifyMetricsUpdateFrequency(newConfig.SupervisorConfig.MetricsUpdateFrequency)
 	var tunnelHostnames []h2mux.TunnelHostname
 	for _, tunnelConfig := range newConfig.ReverseProxyConfigs {
 		tunnelHostnames = append(tunnelHostnames, tunnelConfig.TunnelHostname)
@@ -129,30 +252,93 @@ func (s *Supervisor) notifySubsystemsNewConfig(newConfig *pogs.ClientConfig) *po
 		TunnelHostnames:      tunnelHostnames,
 		EdgeConnectionConfig: newConfig.EdgeConnectionConfig,
 	})
-	// Update streamHandler tunnelHostnameMapper mapping
-	failedConfigs := s.streamHandler.UpdateConfig(newConfig.ReverseProxyConfigs)
-
 	return &pogs.UseConfigurationResult{
-		Success:       len(failedConfigs) == 0,
-		FailedConfigs: failedConfigs,
+		Success: true,
 	}
 }
 
+// notifyMetricsUpdateFrequency sends frequency to updateMetrics without blocking, replacing a frequency
+// that updateMetrics hasn't received yet
+func (s *Supervisor) notifyMetricsUpdateFrequency(frequency time.Duration) {
+	select {
+	case <-s.metricsUpdateFreqChan:
+	default:
+	}
+	s.metricsUpdateFreqChan <- frequency
+}
+
+// metricsTicker is a time.Ticker that never ticks if its frequency is 0
+type metricsTicker struct {
+	c      <-chan time.Time
+	ticker *time.Ticker
+}
+
+func newMetricsTicker(frequency time.Duration) *metricsTicker {
+	if frequency <= 0 {
+		return &metricsTicker{}
+	}
+	ticker := time.NewTicker(frequency)
+	return &metricsTicker{
+		c:      ticker.C,
+		ticker: ticker,
+	}
+}
+
+func (mt *metricsTicker) stop() {
+	if mt.ticker != nil {
+		mt.ticker.Stop()
+	}
+}
+
+type signalError struct {
+	sig os.Signal
+}
+
+func (e signalError) Error() string {
+	return fmt.Sprintf("received %v signal", e.sig)
+}
+
 type state struct {
 	sync.RWMutex
 	currentConfig *pogs.ClientConfig
+	// edgeVersion is the version of the last config from the edge that was applied. Reloads of the local config
+	// don't change it, so they don't stop the edge from pushing its next version.
+	edgeVersion pogs.Version
 }
 
 func newState(currentConfig *pogs.ClientConfig) *state {
 	return &state{
 		currentConfig: currentConfig,
+		edgeVersion:   currentConfig.Version,
 	}
 }
 
 func (s *state) hasAppliedVersion(incomingVersion pogs.Version) bool {
 	s.RLock()
 	defer s.RUnlock()
-	return s.currentConfig.Version.IsNewerOrEqual(incomingVersion)
+	return s.edgeVersion.IsNewerOrEqual(incomingVersion)
+}
+
+func (s *state) updateEdgeVersion(version pogs.Version) {
+	s.Lock()
+	defer s.Unlock()
+	s.edgeVersion = version
+}
+
+func (s *state) getConfig() *pogs.ClientConfig {
+	s.RLock()
+	defer s.RUnlock()
+	return s.currentConfig
+}
+
+// getSupervisorConfig returns the current SupervisorConfig, or an empty one if there is none
+func (s *state) getSupervisorConfig() *pogs.SupervisorConfig {
+	s.RLock()
+	defer s.RUnlock()
+	if s.currentConfig.SupervisorConfig == nil {
+		return &pogs.SupervisorConfig{}
+	}
+	return s.currentConfig.SupervisorConfig
 }
 
 func (s *state) updateConfig(newConfig *pogs.ClientConfig) {
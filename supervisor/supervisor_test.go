@@ -0,0 +1,264 @@
+package supervisor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflared/buildinfo"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/edgeemulator"
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const testHostname = "tunnel.example.com"
+
+func TestNotifyMetricsUpdateFrequency(t *testing.T) {
+	s := &Supervisor{metricsUpdateFreqChan: make(chan time.Duration, 1)}
+	s.notifyMetricsUpdateFrequency(time.Second)
+	// Doesn't block even though the previous frequency hasn't been received
+	s.notifyMetricsUpdateFrequency(time.Minute)
+	assert.Equal(t, time.Minute, <-s.metricsUpdateFreqChan)
+}
+
+func TestMetricsTicker(t *testing.T) {
+	disabled := newMetricsTicker(0)
+	defer disabled.stop()
+	enabled := newMetricsTicker(time.Millisecond)
+	defer enabled.stop()
+
+	select {
+	case <-enabled.c:
+	case <-time.After(time.Second):
+		t.Fatal("metricsTicker with a positive frequency didn't tick")
+	}
+	select {
+	case <-disabled.c:
+		t.Fatal("metricsTicker with frequency 0 ticked")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestStateGetSupervisorConfig(t *testing.T) {
+	s := newState(&pogs.ClientConfig{})
+	assert.Equal(t, &pogs.SupervisorConfig{}, s.getSupervisorConfig())
+
+	supervisorConfig := &pogs.SupervisorConfig{GracePeriod: time.Minute}
+	s.updateConfig(&pogs.ClientConfig{SupervisorConfig: supervisorConfig})
+	assert.Equal(t, supervisorConfig, s.getSupervisorConfig())
+}
+
+// newTestSupervisor returns a Supervisor that isn't connected to the edge
+func newTestSupervisor(t *testing.T, defaultClientConfig *pogs.ClientConfig) *Supervisor {
+	s, err := NewSupervisor(defaultClientConfig, nil, nil, &net.Dialer{}, nil, &connection.CloudflaredConfig{},
+		logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestApplyConfigFailure(t *testing.T) {
+	// The DNS over HTTPS proxy of the new config cannot listen on the port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	currentConfig := &pogs.ClientConfig{
+		Version:          5,
+		SupervisorConfig: &pogs.SupervisorConfig{MetricsUpdateFrequency: time.Second},
+	}
+	s := newTestSupervisor(t, currentConfig)
+	defer s.dohProxyManager.Shutdown()
+	newConfig := &pogs.ClientConfig{
+		Version:          6,
+		SupervisorConfig: &pogs.SupervisorConfig{MetricsUpdateFrequency: time.Minute},
+		DoHProxyConfigs: []*pogs.DoHProxyConfig{{
+			ListenHost: "127.0.0.1",
+			ListenPort: uint16(listener.Addr().(*net.TCPAddr).Port),
+			Upstreams:  []string{"https://1.1.1.1/dns-query"},
+		}},
+	}
+	result := s.notifySubsystemsNewConfig(newConfig)
+	assert.False(t, result.Success)
+	assert.Len(t, result.FailedConfigs, 1)
+
+	// The current config is kept, and the edge can push the version again
+	assert.Equal(t, currentConfig, s.state.getConfig())
+	assert.False(t, s.state.hasAppliedVersion(newConfig.Version))
+	select {
+	case frequency := <-s.metricsUpdateFreqChan:
+		t.Fatalf("metrics update frequency %v was sent for a config that failed", frequency)
+	default:
+	}
+}
+
+// inFlightRequest starts a Supervisor connected to an edge emulator, and sends it a request that its origin holds
+// until release is closed. It returns the Supervisor once the origin has received the request, and a channel of
+// the request's status code.
+func inFlightRequest(ctx context.Context, t *testing.T, gracePeriod time.Duration, release <-chan struct{}) (*Supervisor, <-chan int) {
+	receivedC := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(receivedC)
+		<-release
+	}))
+	go func() {
+		<-ctx.Done()
+		origin.Close()
+	}()
+
+	logger := logrus.New()
+	edge, err := edgeemulator.New(&edgeemulator.Config{}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		<-ctx.Done()
+		edge.Close()
+	}()
+	reverseProxyConfig, err := pogs.NewReverseProxyConfig(testHostname, &pogs.HTTPOriginConfig{URLString: origin.URL}, 0, time.Second, 0)
+	assert.NoError(t, err)
+	serviceDiscoverer, err := connection.NewEdgeHostnameResolver([]string{edge.Addr().String()})
+	assert.NoError(t, err)
+	s, err := NewSupervisor(
+		&pogs.ClientConfig{
+			Version:          pogs.InitVersion(),
+			SupervisorConfig: &pogs.SupervisorConfig{GracePeriod: gracePeriod},
+			EdgeConnectionConfig: &pogs.EdgeConnectionConfig{
+				NumHAConnections:    1,
+				HeartbeatInterval:   time.Second,
+				Timeout:             5 * time.Second,
+				MaxFailedHeartbeats: 5,
+			},
+			ReverseProxyConfigs: []*pogs.ReverseProxyConfig{reverseProxyConfig},
+		},
+		[]byte("origin cert"),
+		edge.ClientTLSConfig(),
+		&net.Dialer{},
+		serviceDiscoverer,
+		&connection.CloudflaredConfig{
+			CloudflaredID: uuid.New(),
+			BuildInfo:     buildinfo.GetBuildInfo("test"),
+			Scope:         pogs.NewGroup("test"),
+		},
+		logger,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.connManager.Run(ctx)
+
+	addr, err := edge.Expose(testHostname)
+	assert.NoError(t, err)
+	for i := 0; edge.Connections() == 0; i++ {
+		if i == 100 {
+			t.Fatal("Supervisor didn't connect to the edge")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	statusC := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			statusC <- 0
+			return
+		}
+		resp.Body.Close()
+		statusC <- resp.StatusCode
+	}()
+	select {
+	case <-receivedC:
+	case <-time.After(10 * time.Second):
+		t.Fatal("origin didn't receive the request")
+	}
+	return s, statusC
+}
+
+// listenToShutdownSignal runs Supervisor.listenToShutdownSignal, and returns a channel of its error
+func listenToShutdownSignal(ctx context.Context, s *Supervisor, signals <-chan os.Signal) <-chan error {
+	errC := make(chan error, 1)
+	go func() {
+		errC <- s.listenToShutdownSignal(ctx, signals)
+	}()
+	return errC
+}
+
+func TestShutdownSignalWaitsForInFlightRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	s, statusC := inFlightRequest(ctx, t, time.Minute, release)
+
+	signals := make(chan os.Signal, 2)
+	errC := listenToShutdownSignal(ctx, s, signals)
+	signals <- syscall.SIGTERM
+	select {
+	case err := <-errC:
+		t.Fatalf("returned %v before the in-flight request finished", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-errC:
+		assert.Equal(t, signalError{sig: syscall.SIGTERM}, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("didn't return after the in-flight request finished")
+	}
+	assert.Equal(t, http.StatusOK, <-statusC)
+}
+
+func TestShutdownSignalWaitsForGracePeriod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	gracePeriod := 500 * time.Millisecond
+	s, _ := inFlightRequest(ctx, t, gracePeriod, release)
+
+	signals := make(chan os.Signal, 2)
+	errC := listenToShutdownSignal(ctx, s, signals)
+	start := time.Now()
+	signals <- syscall.SIGTERM
+	select {
+	case err := <-errC:
+		assert.Equal(t, signalError{sig: syscall.SIGTERM}, err)
+		assert.True(t, time.Since(start) >= gracePeriod, "returned before the grace period ended")
+	case <-time.After(10 * time.Second):
+		t.Fatal("didn't return after the grace period")
+	}
+}
+
+func TestSecondShutdownSignalExitsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	s, _ := inFlightRequest(ctx, t, time.Minute, release)
+
+	signals := make(chan os.Signal, 2)
+	errC := listenToShutdownSignal(ctx, s, signals)
+	signals <- syscall.SIGTERM
+	select {
+	case err := <-errC:
+		t.Fatalf("returned %v before the in-flight request finished", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	signals <- syscall.SIGINT
+	select {
+	case err := <-errC:
+		assert.Equal(t, signalError{sig: syscall.SIGINT}, err)
+	case <-time.After(time.Second):
+		t.Fatal("didn't return after the second signal")
+	}
+}
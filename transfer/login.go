@@ -1,14 +1,17 @@
 package transfer
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -26,7 +29,26 @@ var (
 	defaultConfigDirs = []string{"~/.cloudflared", "~/.cloudflare-warp", "~/cloudflare-warp", "/usr/local/etc/cloudflared", "/etc/cloudflared"}
 )
 
-func Login() error {
+// DefaultLoginTimeout is how long Login waits for the user to log in by default
+const DefaultLoginTimeout = 10 * time.Minute
+
+// LoginOptions are the options of Login
+type LoginOptions struct {
+	// NoBrowser prints the login URL instead of opening a browser, for machines without one such as servers
+	// accessed over SSH
+	NoBrowser bool
+	// Timeout is how long to wait for the user to log in. Zero means DefaultLoginTimeout.
+	Timeout time.Duration
+}
+
+// Login downloads the certificate of the user after they log in with their Cloudflare account. If a previous login
+// was interrupted, it resumes waiting for that login.
+func Login(ctx context.Context, options LoginOptions) error {
+	return NewClient().Login(ctx, options)
+}
+
+// Login is Login with the transfer service of c
+func (c *Client) Login(ctx context.Context, options LoginOptions) error {
 	path, ok, err := checkForExistingCert()
 	if ok {
 		fmt.Fprintf(os.Stdout, "You have an existing certificate at %s which login would overwrite.\nIf this is intentional, please move or delete that file then run this command again.\n", path)
@@ -35,13 +57,18 @@ func Login() error {
 		return err
 	}
 
-	loginURL, err := url.Parse(baseLoginURL)
+	loginURL, err := url.Parse(c.LoginURL)
 	if err != nil {
-		// shouldn't happen, URL is hardcoded
-		return err
+		return errors.Wrapf(err, "%s is not a valid login URL", c.LoginURL)
 	}
 
-	_, err = run(loginURL, "cert", "callback", callbackStoreURL, path, false)
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = DefaultLoginTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	_, err = c.run(ctx, loginURL, "cert", "callback", c.CallbackStoreURL, path, false, options.NoBrowser)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to write the certificate due to the following error:\n%v\n\nYour browser will download the certificate instead. You will have to manually\ncopy it to the following path:\n\n%s\n", err, path)
 		return err
@@ -52,7 +79,7 @@ func Login() error {
 }
 
 func Cert() ([]byte, error) {
-	path, _, err := checkForExistingCert()
+	path, err := CertPath()
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +87,12 @@ func Cert() ([]byte, error) {
 	return ioutil.ReadFile(path)
 }
 
+// CertPath returns the path that Login saves the certificate to
+func CertPath() (string, error) {
+	path, _, err := checkForExistingCert()
+	return path, err
+}
+
 func checkForExistingCert() (string, bool, error) {
 	configPath, err := homedir.Expand(defaultConfigDirs[0])
 	if err != nil {
@@ -99,10 +132,10 @@ func fileExists(path string) (bool, error) {
 	return true, nil
 }
 
-// findDefaultConfigPath returns the first path that contains a config file.
+// FindDefaultConfigPath returns the first path that contains a config file.
 // If none of the combination of DefaultConfigDirs and DefaultConfigFiles
 // contains a config file, return empty string.
-func findDefaultConfigPath() string {
+func FindDefaultConfigPath() string {
 	for _, configDir := range defaultConfigDirs {
 		for _, configFile := range defaultConfigFiles {
 			dirPath, err := homedir.Expand(configDir)
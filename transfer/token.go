@@ -0,0 +1,117 @@
+package transfer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+const tokenFileSuffix = "-token"
+
+// TokenPath returns the path the Access token of the application at appURL is cached at
+func TokenPath(appURL *url.URL) (string, error) {
+	configPath, err := homedir.Expand(defaultConfigDirs[0])
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configPath, appURL.Hostname()+tokenFileSuffix), nil
+}
+
+// GetTokenIfExists returns the Access token cached for the application at appURL, or an empty string if there is
+// none
+func GetTokenIfExists(appURL *url.URL) (string, error) {
+	path, err := TokenPath(appURL)
+	if err != nil {
+		return "", err
+	}
+	token, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// FetchToken returns an Access token of the application at appURL. The token cached for the application is reused
+// until it expires, then the user logs in to the application again to get a new one.
+func FetchToken(appURL *url.URL) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultLoginTimeout)
+	defer cancel()
+	return NewClient().FetchToken(ctx, appURL)
+}
+
+// FetchToken is FetchToken with the transfer service of c
+func (c *Client) FetchToken(ctx context.Context, appURL *url.URL) (string, error) {
+	token, err := GetTokenIfExists(appURL)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot read cached Access token")
+	}
+	if token != "" && !TokenExpired(token, time.Now()) {
+		return token, nil
+	}
+
+	path, err := TokenPath(appURL)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", errors.Wrapf(err, "cannot create %s", filepath.Dir(path))
+	}
+	// Remove the expired token, so the new one is created with the permissions of a new file
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", errors.Wrap(err, "cannot remove expired Access token")
+	}
+
+	// run modifies the URL it's given
+	transferURL := *appURL
+	resource, err := c.run(ctx, &transferURL, "token", "token", "", path, true, false)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot fetch Access token of %s", appURL.Hostname())
+	}
+	return strings.TrimSpace(string(resource)), nil
+}
+
+// jwtClaims are the claims of an Access token that are checked before reusing it
+type jwtClaims struct {
+	Expiry int64 `json:"exp"`
+}
+
+// TokenExpired returns true if token expired before now. The signature isn't verified, the token has already been
+// accepted by the transfer service. A token that can't be parsed or has no expiry is considered expired, so it's
+// replaced.
+func TokenExpired(token string, now time.Time) bool {
+	claims, err := parseTokenClaims(token)
+	if err != nil || claims.Expiry == 0 {
+		return true
+	}
+	return !now.Before(time.Unix(claims.Expiry, 0))
+}
+
+// parseTokenClaims decodes the payload of a JWT
+func parseTokenClaims(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token has %d parts instead of 3", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode token payload")
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.Wrap(err, "cannot parse token claims")
+	}
+	return &claims, nil
+}
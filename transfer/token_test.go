@@ -0,0 +1,101 @@
+package transfer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/stretchr/testify/assert"
+)
+
+// testToken returns an unsigned JWT that expires at expiry
+func testToken(expiry time.Time) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	header := encode([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload := encode([]byte(fmt.Sprintf(`{"aud":"app","exp":%d}`, expiry.Unix())))
+	return header + "." + payload + "." + encode([]byte("signature"))
+}
+
+// withHome sets the home directory, where tokens are cached, to a temporary directory
+func withHome(t *testing.T) (string, func()) {
+	home, err := ioutil.TempDir("", "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+	return home, func() {
+		homedir.DisableCache = false
+		os.Setenv("HOME", oldHome)
+		os.RemoveAll(home)
+	}
+}
+
+func TestTokenExpired(t *testing.T) {
+	now := time.Now()
+	assert.False(t, TokenExpired(testToken(now.Add(time.Minute)), now))
+	assert.True(t, TokenExpired(testToken(now.Add(-time.Minute)), now))
+	assert.True(t, TokenExpired(testToken(now), now))
+
+	assert.True(t, TokenExpired("not a jwt", now))
+	assert.True(t, TokenExpired("header.!!!.signature", now))
+	noExpiry := base64.RawURLEncoding.EncodeToString([]byte(`{"aud":"app"}`))
+	assert.True(t, TokenExpired("header."+noExpiry+".signature", now))
+}
+
+func TestFetchToken(t *testing.T) {
+	home, cleanupHome := withHome(t)
+	defer cleanupHome()
+	service := newTestTransferService(t)
+	defer service.Close()
+
+	validToken := testToken(time.Now().Add(time.Hour))
+	client := service.client("token", []byte(validToken))
+	fetchBrowser := client.Browser
+	browserOpened := 0
+	client.Browser = BrowserOpenerFunc(func(openedURL string) error {
+		browserOpened++
+		parsedURL, err := url.Parse(openedURL)
+		assert.NoError(t, err)
+		assert.Equal(t, "/cdn-cgi/access/cli", parsedURL.Path)
+		return fetchBrowser.Open(openedURL)
+	})
+	appURL, _ := url.Parse("https://app.example.com/path")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	token, err := client.FetchToken(ctx, appURL)
+	assert.NoError(t, err)
+	assert.Equal(t, validToken, token)
+	assert.Equal(t, 1, browserOpened)
+	assert.Equal(t, "https://app.example.com/path", appURL.String())
+	tokenPath := filepath.Join(home, ".cloudflared", "app.example.com-token")
+	info, err := os.Stat(tokenPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// The cached token is reused until it expires
+	token, err = client.FetchToken(ctx, appURL)
+	assert.NoError(t, err)
+	assert.Equal(t, validToken, token)
+	assert.Equal(t, 1, browserOpened)
+
+	assert.NoError(t, ioutil.WriteFile(tokenPath, []byte(testToken(time.Now().Add(-time.Hour))), 0644))
+	token, err = client.FetchToken(ctx, appURL)
+	assert.NoError(t, err)
+	assert.Equal(t, validToken, token)
+	assert.Equal(t, 2, browserOpened)
+	info, err = os.Stat(tokenPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
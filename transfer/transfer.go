@@ -2,8 +2,8 @@ package transfer
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,25 +11,79 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"time"
 
 	"github.com/cloudflare/cloudflared/encrypter"
+
+	"github.com/cloudflare/backoff"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
 )
 
 const (
 	baseStoreURL  = "https://login.argotunnel.com/"
 	clientTimeout = time.Second * 60
+	// The key pair identifies the transfer, it's kept in Client.KeyDir until the resource is downloaded so an
+	// interrupted transfer can be resumed. The files are prefixed with the hostname the transfer is for, so
+	// transfers of different resources don't share a key pair.
+	privateKeyFileSuffix = "-cloudflared_priv.pem"
+	publicKeyFileSuffix  = "-cloudflared_pub.pem"
+	// pollInterval is the initial wait between polls while the user hasn't completed the action
+	pollInterval    = time.Second
+	maxPollInterval = 30 * time.Second
 )
 
+// BrowserOpener opens a URL for the user to complete an action, such as logging in
+type BrowserOpener interface {
+	Open(url string) error
+}
+
+// BrowserOpenerFunc is a function that implements BrowserOpener
+type BrowserOpenerFunc func(url string) error
+
+func (f BrowserOpenerFunc) Open(url string) error {
+	return f(url)
+}
+
+// Client talks to the transfer service (loginhelper)
+type Client struct {
+	// LoginURL is the dashboard page where users log in to get a certificate
+	LoginURL string
+	// CallbackStoreURL is where the dashboard stores the certificate after the user logged in
+	CallbackStoreURL string
+	// StoreURL is where resources are downloaded from
+	StoreURL   string
+	HTTPClient *http.Client
+	Browser    BrowserOpener
+	// KeyDir is where the key pair of a transfer is saved, so running the same command again resumes an
+	// interrupted transfer. If it's empty, the key pair is only kept in memory.
+	KeyDir string
+}
+
+// NewClient returns a Client of Cloudflare's transfer service, which opens URLs in the default browser
+func NewClient() *Client {
+	return &Client{
+		LoginURL:         baseLoginURL,
+		CallbackStoreURL: callbackStoreURL,
+		StoreURL:         baseStoreURL,
+		HTTPClient:       &http.Client{Timeout: clientTimeout},
+		Browser:          BrowserOpenerFunc(openBrowser),
+		KeyDir:           defaultConfigDirs[0],
+	}
+}
+
 // run does the transfer "dance" with the end result downloading the supported resource.
 // The expanded description is run is encapsulation of shared business logic needed
 // to request a resource (token/cert/etc) from the transfer service (loginhelper).
 // The "dance" we refer to is building a HTTP request, opening that in a browser waiting for
 // the user to complete an action, while it long polls in the background waiting for an
 // action to be completed to download the resource.
-func run(transferURL *url.URL, resourceName, key, value, path string, shouldEncrypt bool) ([]byte, error) {
-	encrypterClient, err := encrypter.New("cloudflared_priv.pem", "cloudflared_pub.pem")
+// Polling stops when ctx is done. If noBrowser is true, the URL is only printed, so the user can open it on
+// another machine.
+func (c *Client) run(ctx context.Context, transferURL *url.URL, resourceName, key, value, path string, shouldEncrypt, noBrowser bool) ([]byte, error) {
+	encrypterClient, removeKeys, err := c.newEncrypter(transferURL.Hostname())
 	if err != nil {
 		return nil, err
 	}
@@ -39,8 +93,9 @@ func run(transferURL *url.URL, resourceName, key, value, path string, shouldEncr
 	}
 
 	// See AUTH-1423 for why we use stderr (the way git wraps ssh)
-	err = openBrowser(requestURL)
-	if err != nil {
+	if noBrowser {
+		fmt.Fprintf(os.Stderr, "Please open the following URL on any machine and log in with your Cloudflare account:\n\n%s\n\nLeave cloudflared running to download the %s automatically.\n", requestURL, resourceName)
+	} else if err := c.Browser.Open(requestURL); err != nil {
 		fmt.Fprintf(os.Stderr, "Please open the following URL and log in with your Cloudflare account:\n\n%s\n\nLeave cloudflared running to download the %s automatically.\n", requestURL, resourceName)
 	} else {
 		fmt.Fprintf(os.Stderr, "A browser window should have opened at the following URL:\n\n%s\n\nIf the browser failed to open, open it yourself and visit the URL above.\n", requestURL)
@@ -49,7 +104,7 @@ func run(transferURL *url.URL, resourceName, key, value, path string, shouldEncr
 	var resourceData []byte
 
 	if shouldEncrypt {
-		buf, key, err := transferRequest(baseStoreURL + "transfer/" + encrypterClient.PublicKey())
+		buf, key, err := c.transferRequest(ctx, c.StoreURL+"transfer/"+encrypterClient.PublicKey())
 		if err != nil {
 			return nil, err
 		}
@@ -65,7 +120,7 @@ func run(transferURL *url.URL, resourceName, key, value, path string, shouldEncr
 
 		resourceData = decrypted
 	} else {
-		buf, _, err := transferRequest(baseStoreURL + encrypterClient.PublicKey())
+		buf, _, err := c.transferRequest(ctx, c.StoreURL+encrypterClient.PublicKey())
 		if err != nil {
 			return nil, err
 		}
@@ -76,9 +131,37 @@ func run(transferURL *url.URL, resourceName, key, value, path string, shouldEncr
 		return nil, err
 	}
 
+	// The transfer is complete, the next one gets a new key pair
+	removeKeys()
 	return resourceData, nil
 }
 
+// newEncrypter returns the encrypter of a transfer for hostname and a function that removes its saved key pair. The
+// key pair is loaded from c.KeyDir, or generated and saved there if there is none.
+func (c *Client) newEncrypter(hostname string) (*encrypter.Encrypter, func(), error) {
+	if c.KeyDir == "" {
+		encrypterClient, err := encrypter.NewEphemeral()
+		return encrypterClient, func() {}, err
+	}
+	keyDir, err := homedir.Expand(c.KeyDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(keyDir, 0700); err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot create %s", keyDir)
+	}
+	privateKeyPath := filepath.Join(keyDir, hostname+privateKeyFileSuffix)
+	publicKeyPath := filepath.Join(keyDir, hostname+publicKeyFileSuffix)
+	encrypterClient, err := encrypter.New(privateKeyPath, publicKeyPath)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot load the key pair of the transfer")
+	}
+	return encrypterClient, func() {
+		os.Remove(privateKeyPath)
+		os.Remove(publicKeyPath)
+	}, nil
+}
+
 // openBrowser opens the specified URL in the default browser of the user
 func openBrowser(url string) error {
 	var cmd string
@@ -114,28 +197,39 @@ func buildRequestURL(baseURL *url.URL, key, value string, cli bool) (string, err
 	return baseURL.String(), nil
 }
 
-// transferRequest downloads the requested resource from the request URL
-func transferRequest(requestURL string) ([]byte, string, error) {
-	client := &http.Client{Timeout: clientTimeout}
-	const pollAttempts = 10
+// transferRequest downloads the requested resource from the request URL. It polls with exponential backoff until
+// the resource is available or ctx is done.
+func (c *Client) transferRequest(ctx context.Context, requestURL string) ([]byte, string, error) {
+	pollBackoff := backoff.New(maxPollInterval, pollInterval)
 	// we do "long polling" on the endpoint to get the resource.
-	for i := 0; i < pollAttempts; i++ {
-		buf, key, err := poll(client, requestURL)
+	for {
+		buf, key, err := c.poll(ctx, requestURL)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, "", errors.Wrap(ctx.Err(), "Failed to fetch resource")
+			}
 			return nil, "", err
 		} else if len(buf) > 0 {
-			if err := putSuccess(client, requestURL); err != nil {
+			if err := c.putSuccess(requestURL); err != nil {
 				fmt.Fprintf(os.Stderr, "Failed to update resource success: %v\n", err)
 			}
 			return buf, key, nil
 		}
+		select {
+		case <-ctx.Done():
+			return nil, "", errors.Wrap(ctx.Err(), "Failed to fetch resource")
+		case <-time.After(pollBackoff.Duration()):
+		}
 	}
-	return nil, "", errors.New("Failed to fetch resource")
 }
 
 // poll the endpoint for the request resource, waiting for the user interaction
-func poll(client *http.Client, requestURL string) ([]byte, string, error) {
-	resp, err := client.Get(requestURL)
+func (c *Client) poll(ctx context.Context, requestURL string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, "", err
 	}
@@ -147,7 +241,7 @@ func poll(client *http.Client, requestURL string) ([]byte, string, error) {
 		return nil, "", fmt.Errorf("error on request %d", resp.StatusCode)
 	}
 	if resp.StatusCode != 200 {
-		fmt.Fprint(os.Stdout, "Waiting for login...\n")
+		fmt.Fprint(os.Stderr, "Waiting for login...\n")
 		return nil, "", nil
 	}
 
@@ -159,13 +253,13 @@ func poll(client *http.Client, requestURL string) ([]byte, string, error) {
 }
 
 // putSuccess tells the server we successfully downloaded the resource
-func putSuccess(client *http.Client, requestURL string) error {
+func (c *Client) putSuccess(requestURL string) error {
 	req, err := http.NewRequest("PUT", requestURL+"/ok", nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := client.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return err
 	}
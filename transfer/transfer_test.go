@@ -0,0 +1,316 @@
+package transfer
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflared/encrypter"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// testTransferService stands in for the transfer service. A resource can be polled once it has been stored.
+type testTransferService struct {
+	*httptest.Server
+	sync.Mutex
+	// resources maps public keys to the resource stored for them
+	resources map[string][]byte
+	// succeeded has the public keys whose download was reported successful
+	succeeded map[string]bool
+	polls     int
+	// pollStatus is the status polls are answered with if it's not 0
+	pollStatus int
+	encrypter  *encrypter.Encrypter
+}
+
+func newTestTransferService(t *testing.T) *testTransferService {
+	serviceEncrypter, err := encrypter.NewEphemeral()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &testTransferService{
+		resources: make(map[string][]byte),
+		succeeded: make(map[string]bool),
+		encrypter: serviceEncrypter,
+	}
+	ts.Server = httptest.NewServer(http.HandlerFunc(ts.serveHTTP))
+	return ts
+}
+
+func (ts *testTransferService) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	ts.Lock()
+	defer ts.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if r.Method == http.MethodPut {
+		ts.succeeded[strings.TrimSuffix(strings.TrimPrefix(path, "transfer/"), "/ok")] = true
+		return
+	}
+	ts.polls++
+	if ts.pollStatus != 0 {
+		w.WriteHeader(ts.pollStatus)
+		return
+	}
+	encrypted := strings.HasPrefix(path, "transfer/")
+	publicKey := strings.TrimPrefix(path, "transfer/")
+	resource, ok := ts.resources[publicKey]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !encrypted {
+		w.Write(resource)
+		return
+	}
+	encryptedResource, err := ts.encrypter.Encrypt(resource, publicKey)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("service-public-key", ts.encrypter.PublicKey())
+	w.Write([]byte(base64.StdEncoding.EncodeToString(encryptedResource)))
+}
+
+func (ts *testTransferService) store(publicKey string, resource []byte) {
+	ts.Lock()
+	defer ts.Unlock()
+	ts.resources[publicKey] = resource
+}
+
+func (ts *testTransferService) setPollStatus(status int) {
+	ts.Lock()
+	defer ts.Unlock()
+	ts.pollStatus = status
+}
+
+func (ts *testTransferService) pollCount() int {
+	ts.Lock()
+	defer ts.Unlock()
+	return ts.polls
+}
+
+func (ts *testTransferService) successCount() int {
+	ts.Lock()
+	defer ts.Unlock()
+	return len(ts.succeeded)
+}
+
+func (ts *testTransferService) didSucceed(publicKey string) bool {
+	ts.Lock()
+	defer ts.Unlock()
+	return ts.succeeded[publicKey]
+}
+
+// client returns a Client of the service whose browser stands in for a user that completes the action. The public
+// key of the transfer is read from the query parameter named key of the opened URL. The key pair of transfers is
+// kept in memory.
+func (ts *testTransferService) client(key string, resource []byte) *Client {
+	return &Client{
+		LoginURL:         ts.URL + "/login",
+		CallbackStoreURL: ts.URL + "/",
+		StoreURL:         ts.URL + "/",
+		HTTPClient:       ts.Client(),
+		Browser: BrowserOpenerFunc(func(openedURL string) error {
+			parsedURL, err := url.Parse(openedURL)
+			if err != nil {
+				return err
+			}
+			publicKey := strings.TrimPrefix(parsedURL.Query().Get(key), ts.URL+"/")
+			ts.store(publicKey, resource)
+			return nil
+		}),
+	}
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "transfer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestRunDownloadsResource(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	service := newTestTransferService(t)
+	defer service.Close()
+	client := service.client("callback", []byte("cert"))
+	client.KeyDir = dir
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	loginURL, _ := url.Parse(client.LoginURL)
+	path := filepath.Join(dir, "cert.pem")
+	resource, err := client.run(ctx, loginURL, "cert", "callback", client.CallbackStoreURL, path, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "cert", string(resource))
+	saved, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "cert", string(saved))
+	assert.Equal(t, 1, service.successCount())
+
+	// The key pair of a completed transfer isn't kept
+	_, err = os.Stat(filepath.Join(dir, loginURL.Hostname()+privateKeyFileSuffix))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunDownloadsEncryptedResource(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	service := newTestTransferService(t)
+	defer service.Close()
+	client := service.client("token", []byte("jwt"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	appURL, _ := url.Parse(service.URL + "/app")
+	resource, err := client.run(ctx, appURL, "token", "token", client.StoreURL, filepath.Join(dir, "token"), true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt", string(resource))
+	assert.Equal(t, 1, service.successCount())
+}
+
+func TestRunWithoutBrowser(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	service := newTestTransferService(t)
+	defer service.Close()
+	client := service.client("callback", []byte("cert"))
+	client.KeyDir = dir
+	client.Browser = BrowserOpenerFunc(func(string) error {
+		t.Fatal("browser opened with --no-browser")
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	loginURL, _ := url.Parse(client.LoginURL)
+	_, err := client.run(ctx, loginURL, "cert", "callback", client.CallbackStoreURL, filepath.Join(dir, "cert.pem"), false, true)
+	assert.Error(t, err)
+
+	// The key pair is kept, so the login can be resumed
+	info, err := os.Stat(filepath.Join(dir, loginURL.Hostname()+privateKeyFileSuffix))
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+	publicKey, err := ioutil.ReadFile(filepath.Join(dir, loginURL.Hostname()+publicKeyFileSuffix))
+	assert.NoError(t, err)
+
+	_, err = client.run(ctx, loginURL, "cert", "callback", client.CallbackStoreURL, filepath.Join(dir, "cert.pem"), false, true)
+	assert.Error(t, err)
+	resumedPublicKey, err := ioutil.ReadFile(filepath.Join(dir, loginURL.Hostname()+publicKeyFileSuffix))
+	assert.NoError(t, err)
+	assert.Equal(t, publicKey, resumedPublicKey)
+}
+
+func TestRunKeepsKeyPairPerHostname(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	service := newTestTransferService(t)
+	defer service.Close()
+	client := service.client("token", []byte("jwt-b"))
+	client.KeyDir = dir
+	serviceURL, _ := url.Parse(service.URL)
+
+	// The transfer of the token of a is interrupted before the user logs in
+	appA, _ := url.Parse("http://127.0.0.1:" + serviceURL.Port() + "/a")
+	interruptedCtx, cancelInterrupted := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelInterrupted()
+	_, err := client.run(interruptedCtx, appA, "token", "token", "", filepath.Join(dir, "a-token"), true, true)
+	assert.Error(t, err)
+	publicKeyA, err := ioutil.ReadFile(filepath.Join(dir, "127.0.0.1"+publicKeyFileSuffix))
+	assert.NoError(t, err)
+
+	// The transfer of the token of b doesn't resume the transfer of a
+	appB, _ := url.Parse("http://localhost:" + serviceURL.Port() + "/b")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resource, err := client.run(ctx, appB, "token", "token", "", filepath.Join(dir, "b-token"), true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt-b", string(resource))
+	_, err = os.Stat(filepath.Join(dir, "localhost"+privateKeyFileSuffix))
+	assert.True(t, os.IsNotExist(err))
+
+	// The transfer of a can still be resumed
+	resumedPublicKeyA, err := ioutil.ReadFile(filepath.Join(dir, "127.0.0.1"+publicKeyFileSuffix))
+	assert.NoError(t, err)
+	assert.Equal(t, publicKeyA, resumedPublicKeyA)
+}
+
+func TestTransferRequestPollsUntilAvailable(t *testing.T) {
+	service := newTestTransferService(t)
+	defer service.Close()
+	client := service.client("callback", nil)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		service.store("key", []byte("cert"))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	buf, _, err := client.transferRequest(ctx, service.URL+"/key")
+	assert.NoError(t, err)
+	assert.Equal(t, "cert", string(buf))
+	// The backoff is jittered, so the exact number of polls varies
+	assert.True(t, service.pollCount() >= 2)
+	assert.True(t, service.didSucceed("key"))
+}
+
+func TestTransferRequestDeadline(t *testing.T) {
+	service := newTestTransferService(t)
+	defer service.Close()
+	client := service.client("callback", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, err := client.transferRequest(ctx, service.URL+"/key")
+	if assert.Error(t, err) {
+		assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	}
+}
+
+func TestPoll(t *testing.T) {
+	service := newTestTransferService(t)
+	defer service.Close()
+	client := service.client("callback", nil)
+	ctx := context.Background()
+
+	// Not found until the user completes the action
+	buf, _, err := client.poll(ctx, service.URL+"/key")
+	assert.NoError(t, err)
+	assert.Empty(t, buf)
+
+	// Other client errors are treated the same way
+	service.setPollStatus(http.StatusForbidden)
+	buf, _, err = client.poll(ctx, service.URL+"/key")
+	assert.NoError(t, err)
+	assert.Empty(t, buf)
+
+	service.setPollStatus(http.StatusBadGateway)
+	_, _, err = client.poll(ctx, service.URL+"/key")
+	assert.Error(t, err)
+}
+
+func TestPutSuccess(t *testing.T) {
+	service := newTestTransferService(t)
+	defer service.Close()
+	client := service.client("callback", nil)
+
+	assert.NoError(t, client.putSuccess(service.URL+"/key"))
+	assert.True(t, service.didSucceed("key"))
+
+	closedService := newTestTransferService(t)
+	closedService.Close()
+	assert.Error(t, client.putSuccess(closedService.URL+"/key"))
+}
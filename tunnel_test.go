@@ -0,0 +1,214 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfigFile = `edge_connection_config:
+  num_ha_connections: 3
+  user_credential_path: /etc/cloudflared/cert.pem
+reverse_proxy_configs:
+  - tunnel_hostname: config.example.com
+    origin_config:
+      Http:
+        url_string: http://localhost:9090
+`
+
+// withHome points the home directory to a temporary directory, so the default origin certificate is looked up there
+func withHome(t *testing.T) (string, func()) {
+	home, err := ioutil.TempDir("", "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	homedir.DisableCache = true
+	return home, func() {
+		homedir.DisableCache = false
+		os.Setenv("HOME", oldHome)
+		os.RemoveAll(home)
+	}
+}
+
+func TestLoadClientConfig(t *testing.T) {
+	home, cleanup := withHome(t)
+	defer cleanup()
+	defaultCertPath := filepath.Join(home, ".cloudflared", "cert.pem")
+
+	tests := []struct {
+		name string
+		// config is the content of the config file
+		config string
+		args   []string
+		// expectedErr means loadClientConfig fails, the other fields aren't checked
+		expectedErr            bool
+		expectedHAConnections  uint8
+		expectedCredentialPath string
+		expectedHostname       string
+		expectedOriginURL      string
+	}{
+		{
+			name:                   "flags",
+			args:                   []string{"--hostname", "flag.example.com", "--url", "http://localhost:8000", "--ha-connections", "2"},
+			expectedHAConnections:  2,
+			expectedCredentialPath: defaultCertPath,
+			expectedHostname:       "flag.example.com",
+			expectedOriginURL:      "http://localhost:8000",
+		},
+		{
+			name:                   "flag_defaults",
+			args:                   []string{"--hostname", "flag.example.com"},
+			expectedHAConnections:  4,
+			expectedCredentialPath: defaultCertPath,
+			expectedHostname:       "flag.example.com",
+			expectedOriginURL:      "http://localhost:8080",
+		},
+		{
+			name:                   "config_file_over_flags",
+			config:                 testConfigFile,
+			args:                   []string{"--hostname", "flag.example.com", "--url", "http://localhost:8000", "--ha-connections", "2"},
+			expectedHAConnections:  3,
+			expectedCredentialPath: "/etc/cloudflared/cert.pem",
+			expectedHostname:       "config.example.com",
+			expectedOriginURL:      "http://localhost:9090",
+		},
+		{
+			name:                   "origincert_over_config_file",
+			config:                 testConfigFile,
+			args:                   []string{"--origincert", "/tmp/cert.pem"},
+			expectedHAConnections:  3,
+			expectedCredentialPath: "/tmp/cert.pem",
+			expectedHostname:       "config.example.com",
+			expectedOriginURL:      "http://localhost:9090",
+		},
+		{
+			name:                   "origincert_expands_home",
+			args:                   []string{"--hostname", "flag.example.com", "--origincert", "~/certs/cert.pem"},
+			expectedHAConnections:  4,
+			expectedCredentialPath: filepath.Join(home, "certs", "cert.pem"),
+			expectedHostname:       "flag.example.com",
+			expectedOriginURL:      "http://localhost:8080",
+		},
+		{
+			name:                   "max_ha_connections",
+			args:                   []string{"--hostname", "flag.example.com", "--ha-connections", "255"},
+			expectedHAConnections:  255,
+			expectedCredentialPath: defaultCertPath,
+			expectedHostname:       "flag.example.com",
+			expectedOriginURL:      "http://localhost:8080",
+		},
+		{
+			name:        "too_many_ha_connections",
+			args:        []string{"--hostname", "flag.example.com", "--ha-connections", "256"},
+			expectedErr: true,
+		},
+		{
+			// --ha-connections isn't used when the config file has edge_connection_config
+			name:                   "too_many_ha_connections_with_config_file",
+			config:                 testConfigFile,
+			args:                   []string{"--ha-connections", "256"},
+			expectedHAConnections:  3,
+			expectedCredentialPath: "/etc/cloudflared/cert.pem",
+			expectedHostname:       "config.example.com",
+			expectedOriginURL:      "http://localhost:9090",
+		},
+		{
+			name:        "no_hostname",
+			args:        []string{"--url", "http://localhost:8000"},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// A config file is always given, so no config file from the default directories is loaded
+			configPath := filepath.Join(home, test.name+".yml")
+			if err := ioutil.WriteFile(configPath, []byte(test.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			var tf tunnelFlags
+			assert.NoError(t, newTunnelFlagSet("run", &tf).Parse(append([]string{"--config", configPath}, test.args...)))
+
+			clientConfig, loadedPath, err := tf.loadClientConfig(logrus.New())
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, configPath, loadedPath)
+			assert.Equal(t, test.expectedHAConnections, clientConfig.EdgeConnectionConfig.NumHAConnections)
+			assert.Equal(t, test.expectedCredentialPath, clientConfig.EdgeConnectionConfig.UserCredentialPath)
+			assert.NotNil(t, clientConfig.SupervisorConfig)
+			if assert.Len(t, clientConfig.ReverseProxyConfigs, 1) {
+				reverseProxyConfig := clientConfig.ReverseProxyConfigs[0]
+				assert.Equal(t, test.expectedHostname, reverseProxyConfig.TunnelHostname.String())
+				originConfig, ok := reverseProxyConfig.OriginConfigJSONHandler.OriginConfig.(*pogs.HTTPOriginConfig)
+				if assert.True(t, ok) {
+					assert.Equal(t, test.expectedOriginURL, originConfig.URLString)
+				}
+			}
+		})
+	}
+}
+
+func TestReverseProxyConfig(t *testing.T) {
+	tests := []struct {
+		name                    string
+		args                    []string
+		expectedSkipTLSVerify   bool
+		expectedChunkedEncoding bool
+		expectedRetries         uint64
+		expectedTimeout         time.Duration
+	}{
+		{
+			name:                    "defaults",
+			args:                    []string{"--hostname", "flag.example.com"},
+			expectedChunkedEncoding: true,
+			expectedRetries:         5,
+			expectedTimeout:         30 * time.Second,
+		},
+		{
+			name: "options",
+			args: []string{
+				"--hostname", "flag.example.com",
+				"--no-tls-verify",
+				"--no-chunked-encoding",
+				"--retries", "2",
+				"--proxy-connect-timeout", "10s",
+			},
+			expectedSkipTLSVerify: true,
+			expectedRetries:       2,
+			expectedTimeout:       10 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tf tunnelFlags
+			assert.NoError(t, newTunnelFlagSet("run", &tf).Parse(test.args))
+
+			reverseProxyConfig, err := tf.reverseProxyConfig()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "flag.example.com", reverseProxyConfig.TunnelHostname.String())
+			assert.Equal(t, test.expectedRetries, reverseProxyConfig.Retries)
+			assert.Equal(t, test.expectedTimeout, reverseProxyConfig.ConnectionTimeout)
+			originConfig := reverseProxyConfig.OriginConfigJSONHandler.OriginConfig.(*pogs.HTTPOriginConfig)
+			// TLSVerify is used as InsecureSkipVerify
+			assert.Equal(t, test.expectedSkipTLSVerify, originConfig.TLSVerify)
+			assert.Equal(t, test.expectedChunkedEncoding, originConfig.ChunkedEncoding)
+			assert.Equal(t, test.expectedTimeout, originConfig.ProxyConnectionTimeout)
+		})
+	}
+}
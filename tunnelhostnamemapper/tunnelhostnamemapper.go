@@ -1,6 +1,7 @@
 package tunnelhostnamemapper
 
 import (
+	"reflect"
 	"sync"
 
 	"github.com/cloudflare/cloudflared/h2mux"
@@ -12,11 +13,18 @@ import (
 type TunnelHostnameMapper struct {
 	sync.RWMutex
 	tunnelHostnameToOrigin map[h2mux.TunnelHostname]originservice.OriginService
+	// tunnelHostnameToConfig is the config each OriginService was created from, to find configs that changed
+	tunnelHostnameToConfig map[h2mux.TunnelHostname]*pogs.ReverseProxyConfig
+	// inFlightRequests counts the requests proxied by the OriginService of each TunnelHostname, so a replaced
+	// OriginService is only shutdown after they finish
+	inFlightRequests map[h2mux.TunnelHostname]*sync.WaitGroup
 }
 
 func NewTunnelHostnameMapper() *TunnelHostnameMapper {
 	return &TunnelHostnameMapper{
 		tunnelHostnameToOrigin: make(map[h2mux.TunnelHostname]originservice.OriginService),
+		tunnelHostnameToConfig: make(map[h2mux.TunnelHostname]*pogs.ReverseProxyConfig),
+		inFlightRequests:       make(map[h2mux.TunnelHostname]*sync.WaitGroup),
 	}
 }
 
@@ -28,29 +36,67 @@ func (om *TunnelHostnameMapper) Get(key h2mux.TunnelHostname) (originservice.Ori
 	return originService, ok
 }
 
-// Add a mapping. If there is already an OriginService with this key, shutdown the old origin service and replace it
-// with the new one
+// Acquire gets the OriginService of a TunnelHostname to proxy a request, and the config it was created from, which
+// is nil if it was added without one. release must be called once the request is done, so the OriginService can be
+// drained when it's replaced or deleted.
+func (om *TunnelHostnameMapper) Acquire(key h2mux.TunnelHostname) (os originservice.OriginService, config *pogs.ReverseProxyConfig, release func(), ok bool) {
+	om.RLock()
+	defer om.RUnlock()
+	os, ok = om.tunnelHostnameToOrigin[key]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	inFlightRequests := om.inFlightRequests[key]
+	inFlightRequests.Add(1)
+	return os, om.tunnelHostnameToConfig[key], inFlightRequests.Done, true
+}
+
+// Add a mapping. If there is already an OriginService with this key, replace it with the new one, and shutdown the
+// old origin service once its in-flight requests finish
 func (om *TunnelHostnameMapper) Add(key h2mux.TunnelHostname, os originservice.OriginService) {
 	om.Lock()
 	defer om.Unlock()
+	om.add(key, os)
+}
+
+// AddConfig is like Add, but also records the config that os was created from, so ToAdd can tell if it changed
+func (om *TunnelHostnameMapper) AddConfig(config *pogs.ReverseProxyConfig, os originservice.OriginService) {
+	om.Lock()
+	defer om.Unlock()
+	om.add(config.TunnelHostname, os)
+	om.tunnelHostnameToConfig[config.TunnelHostname] = config
+}
+
+func (om *TunnelHostnameMapper) add(key h2mux.TunnelHostname, os originservice.OriginService) {
 	if oldOS, ok := om.tunnelHostnameToOrigin[key]; ok {
-		oldOS.Shutdown()
+		go drainAndShutdown(oldOS, om.inFlightRequests[key])
 	}
 	om.tunnelHostnameToOrigin[key] = os
+	om.inFlightRequests[key] = &sync.WaitGroup{}
+	delete(om.tunnelHostnameToConfig, key)
 }
 
-// Delete a mapping, and shutdown its OriginService
+// Delete a mapping, and shutdown its OriginService once its in-flight requests finish
 func (om *TunnelHostnameMapper) Delete(key h2mux.TunnelHostname) (keyFound bool) {
 	om.Lock()
 	defer om.Unlock()
 	if os, ok := om.tunnelHostnameToOrigin[key]; ok {
-		os.Shutdown()
+		go drainAndShutdown(os, om.inFlightRequests[key])
 		delete(om.tunnelHostnameToOrigin, key)
+		delete(om.tunnelHostnameToConfig, key)
+		delete(om.inFlightRequests, key)
 		return true
 	}
 	return false
 }
 
+// drainAndShutdown waits for in-flight requests to finish before shutting down os. No new request can acquire os,
+// because it's no longer mapped.
+func drainAndShutdown(os originservice.OriginService, inFlightRequests *sync.WaitGroup) {
+	inFlightRequests.Wait()
+	os.Shutdown()
+}
+
 // ToRemove finds all keys that should be removed from the TunnelHostnameMapper.
 func (om *TunnelHostnameMapper) ToRemove(newConfigs []*pogs.ReverseProxyConfig) (toRemove []h2mux.TunnelHostname) {
 	om.Lock()
@@ -69,15 +115,18 @@ func (om *TunnelHostnameMapper) ToRemove(newConfigs []*pogs.ReverseProxyConfig)
 	return
 }
 
-// ToAdd filters the given configs, keeping those that should be added to the TunnelHostnameMapper.
+// ToAdd filters the given configs, keeping those that should be added to the TunnelHostnameMapper, or that should
+// replace the OriginService of their TunnelHostname because the config changed.
 func (om *TunnelHostnameMapper) ToAdd(newConfigs []*pogs.ReverseProxyConfig) (toAdd []*pogs.ReverseProxyConfig) {
 	om.Lock()
 	defer om.Unlock()
 
-	// If a config in `newConfigs` isn't in `om`, it must be added.
+	// If a config in `newConfigs` isn't in `om`, or is different from the config in `om`, it must be added.
 	for _, config := range newConfigs {
 		if _, ok := om.tunnelHostnameToOrigin[config.TunnelHostname]; !ok {
 			toAdd = append(toAdd, config)
+		} else if currentConfig, ok := om.tunnelHostnameToConfig[config.TunnelHostname]; ok && !reflect.DeepEqual(currentConfig, config) {
+			toAdd = append(toAdd, config)
 		}
 	}
 
@@ -191,6 +191,76 @@ func TestTunnelHostnameMapper_ToRemove(t *testing.T) {
 	}
 }
 
+func TestTunnelHostnameMapper_ToAddChangedConfig(t *testing.T) {
+	thm := NewTunnelHostnameMapper()
+	thm.AddConfig(sampleConfig1(), &originservice.HTTPService{})
+	thm.AddConfig(sampleConfig2(), &originservice.HTTPService{})
+
+	// Structurally equal configs don't need a new OriginService
+	assert.Empty(t, thm.ToAdd([]*pogs.ReverseProxyConfig{sampleConfig1(), sampleConfig2()}))
+
+	changedOrigin := sampleConfig1()
+	changedOrigin.OriginConfigJSONHandler.OriginConfig = &pogs.HTTPOriginConfig{URLString: "https://127.0.0.1:8443"}
+	changedTimeout := sampleConfig2()
+	changedTimeout.ConnectionTimeout = time.Minute
+	newConfigs := []*pogs.ReverseProxyConfig{changedOrigin, changedTimeout}
+	assert.Equal(t, newConfigs, thm.ToAdd(newConfigs))
+}
+
+func TestTunnelHostnameMapperDrainBeforeShutdown(t *testing.T) {
+	thm := NewTunnelHostnameMapper()
+	oldOS := newMockOriginService()
+	thm.AddConfig(sampleConfig1(), oldOS)
+
+	os, config, release, ok := thm.Acquire(sampleConfig1().TunnelHostname)
+	assert.True(t, ok)
+	assert.Equal(t, oldOS, os)
+	assert.Equal(t, sampleConfig1(), config)
+
+	// Replacing the OriginService swaps it immediately, but the old one is shutdown after the in-flight request
+	newOS := newMockOriginService()
+	thm.AddConfig(sampleConfig1(), newOS)
+	os, _, newRelease, ok := thm.Acquire(sampleConfig1().TunnelHostname)
+	assert.True(t, ok)
+	assert.Equal(t, newOS, os)
+	newRelease()
+
+	select {
+	case <-oldOS.shutdownC:
+		t.Fatal("OriginService was shutdown before its in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-oldOS.shutdownC:
+	case <-time.After(time.Second):
+		t.Fatal("OriginService wasn't shutdown after its in-flight request finished")
+	}
+
+	// Deleting drains the same way
+	assert.True(t, thm.Delete(sampleConfig1().TunnelHostname))
+	select {
+	case <-newOS.shutdownC:
+	case <-time.After(time.Second):
+		t.Fatal("OriginService wasn't shutdown after it was deleted")
+	}
+	_, _, _, ok = thm.Acquire(sampleConfig1().TunnelHostname)
+	assert.False(t, ok)
+}
+
+type mockOriginService struct {
+	originservice.HTTPService
+	shutdownC chan struct{}
+}
+
+func newMockOriginService() *mockOriginService {
+	return &mockOriginService{shutdownC: make(chan struct{})}
+}
+
+func (mos *mockOriginService) Shutdown() {
+	close(mos.shutdownC)
+}
+
 func sampleConfig1() *pogs.ReverseProxyConfig {
 	return &pogs.ReverseProxyConfig{
 		TunnelHostname:          "mock.example.com",
@@ -181,6 +181,7 @@ const (
 	httpType originType = iota
 	wsType
 	helloWorldType
+	tcpType
 )
 
 func (ot originType) String() string {
@@ -191,6 +192,8 @@ func (ot originType) String() string {
 		return "WebSocket"
 	case helloWorldType:
 		return "HelloWorld"
+	case tcpType:
+		return "TCP"
 	default:
 		return "unknown"
 	}
@@ -217,11 +220,12 @@ func (hc *HTTPOriginConfig) Service() (originservice.OriginService, error) {
 		return nil, err
 	}
 
-	dialContext := (&net.Dialer{
+	// ReverseProxyConfig.ConnectionTimeout is passed in the request context
+	dialContext := originservice.DialContextWithTimeout((&net.Dialer{
 		Timeout:   hc.ProxyConnectionTimeout,
 		KeepAlive: hc.TCPKeepAlive,
 		DualStack: hc.DialDualStack,
-	}).DialContext
+	}).DialContext)
 	transport := &http.Transport{
 		Proxy:       http.ProxyFromEnvironment,
 		DialContext: dialContext,
@@ -283,13 +287,26 @@ func (_ *WebSocketOriginConfig) jsonType() string {
 type HelloWorldOriginConfig struct{}
 
 func (_ *HelloWorldOriginConfig) Service() (originservice.OriginService, error) {
-	return nil, fmt.Errorf("not implemented error")
+	return originservice.NewHelloWorldService(), nil
 }
 
 func (_ *HelloWorldOriginConfig) jsonType() string {
 	return helloWorldType.String()
 }
 
+type TCPOriginConfig struct {
+	Address       string `capnp:"address" json:"address" mapstructure:"address"`
+	ProxyProtocol bool   `capnp:"proxyProtocol" json:"proxy_protocol" mapstructure:"proxy_protocol"`
+}
+
+func (tc *TCPOriginConfig) Service() (originservice.OriginService, error) {
+	return originservice.NewTCPService(tc.Address, tc.ProxyProtocol)
+}
+
+func (_ *TCPOriginConfig) jsonType() string {
+	return tcpType.String()
+}
+
 /*
  * Boilerplate to convert between these structs and the primitive structs
  * generated by capnp-go.
@@ -522,6 +539,14 @@ func MarshalReverseProxyConfig(s tunnelrpc.ReverseProxyConfig, p *ReverseProxyCo
 		if err := MarshalHelloWorldOriginConfig(ss, config); err != nil {
 			return err
 		}
+	case *TCPOriginConfig:
+		ss, err := s.Origin().NewTcp()
+		if err != nil {
+			return err
+		}
+		if err := MarshalTCPOriginConfig(ss, config); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("Unknown type for config: %T", config)
 	}
@@ -569,6 +594,16 @@ func UnmarshalReverseProxyConfig(s tunnelrpc.ReverseProxyConfig) (*ReverseProxyC
 			return nil, err
 		}
 		p.OriginConfigJSONHandler = &OriginConfigJSONHandler{config}
+	case tunnelrpc.ReverseProxyConfig_origin_Which_tcp:
+		ss, err := s.Origin().Tcp()
+		if err != nil {
+			return nil, err
+		}
+		config, err := UnmarshalTCPOriginConfig(ss)
+		if err != nil {
+			return nil, err
+		}
+		p.OriginConfigJSONHandler = &OriginConfigJSONHandler{config}
 	}
 	p.Retries = s.Retries()
 	p.ConnectionTimeout = time.Duration(s.ConnectionTimeout())
@@ -606,6 +641,16 @@ func UnmarshalHelloWorldOriginConfig(s tunnelrpc.HelloWorldOriginConfig) (*Hello
 	return p, err
 }
 
+func MarshalTCPOriginConfig(s tunnelrpc.TCPOriginConfig, p *TCPOriginConfig) error {
+	return pogs.Insert(tunnelrpc.TCPOriginConfig_TypeID, s.Struct, p)
+}
+
+func UnmarshalTCPOriginConfig(s tunnelrpc.TCPOriginConfig) (*TCPOriginConfig, error) {
+	p := new(TCPOriginConfig)
+	err := pogs.Extract(p, tunnelrpc.TCPOriginConfig_TypeID, s.Struct)
+	return p, err
+}
+
 type ClientService interface {
 	UseConfiguration(ctx context.Context, config *ClientConfig) (*UseConfigurationResult, error)
 }
@@ -21,6 +21,9 @@ var _ OriginConfig = (*WebSocketOriginConfig)(nil)
 // Assert *HelloWorldOriginConfig implements OriginConfig
 var _ OriginConfig = (*HelloWorldOriginConfig)(nil)
 
+// Assert *TCPOriginConfig implements OriginConfig
+var _ OriginConfig = (*TCPOriginConfig)(nil)
+
 func TestVersion(t *testing.T) {
 	firstVersion := InitVersion()
 	secondVersion := Version(1)
@@ -69,6 +72,9 @@ func ClientConfigTestCases() []*ClientConfig {
 			sampleReverseProxyConfig(func(c *ReverseProxyConfig) {
 				c.OriginConfigJSONHandler = &OriginConfigJSONHandler{sampleWebSocketOriginConfig()}
 			}),
+			sampleReverseProxyConfig(func(c *ReverseProxyConfig) {
+				c.OriginConfigJSONHandler = &OriginConfigJSONHandler{sampleTCPOriginConfig()}
+			}),
 		}
 	}
 
@@ -175,6 +181,9 @@ func TestReverseProxyConfig(t *testing.T) {
 		sampleReverseProxyConfig(func(c *ReverseProxyConfig) {
 			c.OriginConfigJSONHandler = &OriginConfigJSONHandler{sampleWebSocketOriginConfig()}
 		}),
+		sampleReverseProxyConfig(func(c *ReverseProxyConfig) {
+			c.OriginConfigJSONHandler = &OriginConfigJSONHandler{sampleTCPOriginConfig()}
+		}),
 	}
 	for i, testCase := range testCases {
 		_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
@@ -238,6 +247,28 @@ func TestWebSocketOriginConfig(t *testing.T) {
 	}
 }
 
+func TestTCPOriginConfig(t *testing.T) {
+	testCases := []*TCPOriginConfig{
+		sampleTCPOriginConfig(),
+	}
+	for i, testCase := range testCases {
+		_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+		capnpEntity, err := tunnelrpc.NewTCPOriginConfig(seg)
+		if !assert.NoError(t, err) {
+			t.Fatal("Couldn't initialize a new message")
+		}
+		err = MarshalTCPOriginConfig(capnpEntity, testCase)
+		if !assert.NoError(t, err, "testCase index %v failed to marshal", i) {
+			continue
+		}
+		result, err := UnmarshalTCPOriginConfig(capnpEntity)
+		if !assert.NoError(t, err, "testCase index %v failed to unmarshal", i) {
+			continue
+		}
+		assert.Equal(t, testCase, result, "testCase index %v didn't preserve struct through marshalling and unmarshalling", i)
+	}
+}
+
 func TestOriginConfigInvalidURL(t *testing.T) {
 	invalidConfigs := []OriginConfig{
 		&HTTPOriginConfig{
@@ -247,6 +278,10 @@ func TestOriginConfigInvalidURL(t *testing.T) {
 		&WebSocketOriginConfig{
 			URLString: "127.0.0.1:36192",
 		},
+		&TCPOriginConfig{
+			// this address doesn't have a port
+			Address: "127.0.0.1",
+		},
 	}
 
 	for _, config := range invalidConfigs {
@@ -394,6 +429,18 @@ func sampleWebSocketOriginConfig(overrides ...func(*WebSocketOriginConfig)) *Web
 	return sample
 }
 
+func sampleTCPOriginConfig(overrides ...func(*TCPOriginConfig)) *TCPOriginConfig {
+	sample := &TCPOriginConfig{
+		Address:       "localhost:22",
+		ProxyProtocol: true,
+	}
+	sample.ensureNoZeroFields()
+	for _, f := range overrides {
+		f(sample)
+	}
+	return sample
+}
+
 func (c *ClientConfig) ensureNoZeroFields() {
 	ensureNoZeroFieldsInSample(reflect.ValueOf(c), []string{"DoHProxyConfigs", "ReverseProxyConfigs"})
 }
@@ -422,6 +469,10 @@ func (c *WebSocketOriginConfig) ensureNoZeroFields() {
 	ensureNoZeroFieldsInSample(reflect.ValueOf(c), []string{})
 }
 
+func (c *TCPOriginConfig) ensureNoZeroFields() {
+	ensureNoZeroFieldsInSample(reflect.ValueOf(c), []string{})
+}
+
 // ensureNoZeroFieldsInSample checks that all fields in the sample struct,
 // except those listed in `allowedZeroFieldNames`, are initialized to nonzero
 // values. Note that the value has to be a pointer for reflection to work
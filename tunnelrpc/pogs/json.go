@@ -61,7 +61,7 @@ func (ocjh *OriginConfigJSONHandler) UnmarshalJSON(b []byte) error {
 
 	if originConfig, ok := originJSON[httpType.String()]; ok {
 		httpOriginConfig := &HTTPOriginConfig{}
-		if err := mapstructure.Decode(originConfig, httpOriginConfig); err != nil {
+		if err := decodeOriginConfig(originConfig, httpOriginConfig); err != nil {
 			return errors.Wrapf(err, "cannot decode %+v into HTTPOriginConfig", originConfig)
 		}
 		ocjh.OriginConfig = httpOriginConfig
@@ -70,7 +70,7 @@ func (ocjh *OriginConfigJSONHandler) UnmarshalJSON(b []byte) error {
 
 	if originConfig, ok := originJSON[wsType.String()]; ok {
 		wsOriginConfig := &WebSocketOriginConfig{}
-		if err := mapstructure.Decode(originConfig, wsOriginConfig); err != nil {
+		if err := decodeOriginConfig(originConfig, wsOriginConfig); err != nil {
 			return errors.Wrapf(err, "cannot decode %+v into WebSocketOriginConfig", originConfig)
 		}
 		ocjh.OriginConfig = wsOriginConfig
@@ -79,16 +79,38 @@ func (ocjh *OriginConfigJSONHandler) UnmarshalJSON(b []byte) error {
 
 	if originConfig, ok := originJSON[helloWorldType.String()]; ok {
 		helloWorldOriginConfig := &HelloWorldOriginConfig{}
-		if err := mapstructure.Decode(originConfig, helloWorldOriginConfig); err != nil {
+		if err := decodeOriginConfig(originConfig, helloWorldOriginConfig); err != nil {
 			return errors.Wrapf(err, "cannot decode %+v into HelloWorldOriginConfig", originConfig)
 		}
 		ocjh.OriginConfig = helloWorldOriginConfig
 		return nil
 	}
 
+	if originConfig, ok := originJSON[tcpType.String()]; ok {
+		tcpOriginConfig := &TCPOriginConfig{}
+		if err := decodeOriginConfig(originConfig, tcpOriginConfig); err != nil {
+			return errors.Wrapf(err, "cannot decode %+v into TCPOriginConfig", originConfig)
+		}
+		ocjh.OriginConfig = tcpOriginConfig
+		return nil
+	}
+
 	return fmt.Errorf("cannot unmarshal %s into OriginConfig", string(b))
 }
 
+// decodeOriginConfig decodes a generic map into one of the OriginConfig structs. Durations can either be
+// nanoseconds, or strings accepted by time.ParseDuration such as "30s".
+func decodeOriginConfig(input interface{}, originConfig OriginConfig) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     originConfig,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
 // FallibleConfigMarshaler is a wrapper for FallibleConfig to implement custom marshal logic
 type FallibleConfigMarshaler struct {
 	FallibleConfig FallibleConfig
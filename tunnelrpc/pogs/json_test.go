@@ -86,6 +86,25 @@ func TestUnmarshalOrigin(t *testing.T) {
 			}`,
 			exceptedOriginConfig: sampleHTTPOriginConfig(),
 		},
+		{
+			jsonLiteral: `{
+				"Http":{
+					"url_string":"https.example.com",
+					"tcp_keep_alive":"7s",
+					"dial_dual_stack":true,
+					"tls_handshake_timeout":"11s",
+					"tls_verify":true,
+					"origin_ca_pool":"/etc/cert.pem",
+					"origin_server_name":"secure.example.com",
+					"max_idle_connections":19,
+					"idle_connection_timeout":"17s",
+					"proxy_connection_timeout":"15s",
+					"expect_continue_timeout":"21s",
+					"chunked_encoding":true
+				}
+			}`,
+			exceptedOriginConfig: sampleHTTPOriginConfig(),
+		},
 		{
 			jsonLiteral: `{
 				"WebSocket":{
@@ -103,6 +122,15 @@ func TestUnmarshalOrigin(t *testing.T) {
 			}`,
 			exceptedOriginConfig: &HelloWorldOriginConfig{},
 		},
+		{
+			jsonLiteral: `{
+				"TCP":{
+					"address":"localhost:22",
+					"proxy_protocol":true
+				}
+			}`,
+			exceptedOriginConfig: sampleTCPOriginConfig(),
+		},
 	}
 
 	for _, test := range tests {
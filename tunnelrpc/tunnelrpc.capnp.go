@@ -1381,10 +1381,11 @@ const (
 	ReverseProxyConfig_origin_Which_http       ReverseProxyConfig_origin_Which = 0
 	ReverseProxyConfig_origin_Which_websocket  ReverseProxyConfig_origin_Which = 1
 	ReverseProxyConfig_origin_Which_helloWorld ReverseProxyConfig_origin_Which = 2
+	ReverseProxyConfig_origin_Which_tcp        ReverseProxyConfig_origin_Which = 3
 )
 
 func (w ReverseProxyConfig_origin_Which) String() string {
-	const s = "httpwebsockethelloWorld"
+	const s = "httpwebsockethelloWorldtcp"
 	switch w {
 	case ReverseProxyConfig_origin_Which_http:
 		return s[0:4]
@@ -1392,6 +1393,8 @@ func (w ReverseProxyConfig_origin_Which) String() string {
 		return s[4:13]
 	case ReverseProxyConfig_origin_Which_helloWorld:
 		return s[13:23]
+	case ReverseProxyConfig_origin_Which_tcp:
+		return s[23:26]
 
 	}
 	return "ReverseProxyConfig_origin_Which(" + strconv.FormatUint(uint64(w), 10) + ")"
@@ -1543,6 +1546,39 @@ func (s ReverseProxyConfig_origin) NewHelloWorld() (HelloWorldOriginConfig, erro
 	return ss, err
 }
 
+func (s ReverseProxyConfig_origin) Tcp() (TCPOriginConfig, error) {
+	if s.Struct.Uint16(0) != 3 {
+		panic("Which() != tcp")
+	}
+	p, err := s.Struct.Ptr(1)
+	return TCPOriginConfig{Struct: p.Struct()}, err
+}
+
+func (s ReverseProxyConfig_origin) HasTcp() bool {
+	if s.Struct.Uint16(0) != 3 {
+		return false
+	}
+	p, err := s.Struct.Ptr(1)
+	return p.IsValid() || err != nil
+}
+
+func (s ReverseProxyConfig_origin) SetTcp(v TCPOriginConfig) error {
+	s.Struct.SetUint16(0, 3)
+	return s.Struct.SetPtr(1, v.Struct.ToPtr())
+}
+
+// NewTcp sets the tcp field to a newly
+// allocated TCPOriginConfig struct, preferring placement in s's segment.
+func (s ReverseProxyConfig_origin) NewTcp() (TCPOriginConfig, error) {
+	s.Struct.SetUint16(0, 3)
+	ss, err := NewTCPOriginConfig(s.Struct.Segment())
+	if err != nil {
+		return TCPOriginConfig{}, err
+	}
+	err = s.Struct.SetPtr(1, ss.Struct.ToPtr())
+	return ss, err
+}
+
 func (s ReverseProxyConfig) Retries() uint64 {
 	return s.Struct.Uint64(8)
 }
@@ -1621,6 +1657,10 @@ func (p ReverseProxyConfig_origin_Promise) HelloWorld() HelloWorldOriginConfig_P
 	return HelloWorldOriginConfig_Promise{Pipeline: p.Pipeline.GetPipeline(1)}
 }
 
+func (p ReverseProxyConfig_origin_Promise) Tcp() TCPOriginConfig_Promise {
+	return TCPOriginConfig_Promise{Pipeline: p.Pipeline.GetPipeline(1)}
+}
+
 type WebSocketOriginConfig struct{ capnp.Struct }
 
 // WebSocketOriginConfig_TypeID is the unique identifier for the type WebSocketOriginConfig.
@@ -2081,6 +2121,88 @@ func (p HelloWorldOriginConfig_Promise) Struct() (HelloWorldOriginConfig, error)
 	return HelloWorldOriginConfig{s}, err
 }
 
+type TCPOriginConfig struct{ capnp.Struct }
+
+// TCPOriginConfig_TypeID is the unique identifier for the type TCPOriginConfig.
+const TCPOriginConfig_TypeID = 0xad63c4bd563a3761
+
+func NewTCPOriginConfig(s *capnp.Segment) (TCPOriginConfig, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1})
+	return TCPOriginConfig{st}, err
+}
+
+func NewRootTCPOriginConfig(s *capnp.Segment) (TCPOriginConfig, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1})
+	return TCPOriginConfig{st}, err
+}
+
+func ReadRootTCPOriginConfig(msg *capnp.Message) (TCPOriginConfig, error) {
+	root, err := msg.RootPtr()
+	return TCPOriginConfig{root.Struct()}, err
+}
+
+func (s TCPOriginConfig) String() string {
+	str, _ := text.Marshal(0xad63c4bd563a3761, s.Struct)
+	return str
+}
+
+func (s TCPOriginConfig) Address() (string, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.Text(), err
+}
+
+func (s TCPOriginConfig) HasAddress() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s TCPOriginConfig) AddressBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s TCPOriginConfig) SetAddress(v string) error {
+	return s.Struct.SetText(0, v)
+}
+
+func (s TCPOriginConfig) ProxyProtocol() bool {
+	return s.Struct.Bit(0)
+}
+
+func (s TCPOriginConfig) SetProxyProtocol(v bool) {
+	s.Struct.SetBit(0, v)
+}
+
+// TCPOriginConfig_List is a list of TCPOriginConfig.
+type TCPOriginConfig_List struct{ capnp.List }
+
+// NewTCPOriginConfig creates a new list of TCPOriginConfig.
+func NewTCPOriginConfig_List(s *capnp.Segment, sz int32) (TCPOriginConfig_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1}, sz)
+	return TCPOriginConfig_List{l}, err
+}
+
+func (s TCPOriginConfig_List) At(i int) TCPOriginConfig {
+	return TCPOriginConfig{s.List.Struct(i)}
+}
+
+func (s TCPOriginConfig_List) Set(i int, v TCPOriginConfig) error {
+	return s.List.SetStruct(i, v.Struct)
+}
+
+func (s TCPOriginConfig_List) String() string {
+	str, _ := text.MarshalList(0xad63c4bd563a3761, s.List)
+	return str
+}
+
+// TCPOriginConfig_Promise is a wrapper for a TCPOriginConfig promised by a client call.
+type TCPOriginConfig_Promise struct{ *capnp.Pipeline }
+
+func (p TCPOriginConfig_Promise) Struct() (TCPOriginConfig, error) {
+	s, err := p.Pipeline.Struct()
+	return TCPOriginConfig{s}, err
+}
+
 type Tag struct{ capnp.Struct }
 
 // Tag_TypeID is the unique identifier for the type Tag.
@@ -3704,229 +3826,233 @@ func (p ClientService_useConfiguration_Results_Promise) Result() UseConfiguratio
 	return UseConfigurationResult_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
 
-const schema_db8274f9144abc7e = "x\xda\xacY}l]\xe5y\x7f\x9e\xf3^\xfb\xd8\x89" +
-	"o\xae\x0f\xe7\"b\x13\xcbU\x04jCI\x06\xf1\xb2" +
-	"\x11o\xeb\x8d\xed$\xb5\xdd|\xdc\xe3\x8f\x00!\x91r" +
-	"r\xef\xeb\xeb\xe3\x9c{\xce\xcd\xf9\x08v\x946\x90\x85" +
-	"\x01\x1e)\x09m&\x92\x86\x0a\xb2f|(]\x09\x0d" +
-	"\xda\xca\xa0*\xd3\x18d\xad\x04h0\xc1\xc6\xfeX!" +
-	"\x9a@\x8b\x18\xb4\x12\xa2*\x9c\xe99\xdf\xbe\xbeu\xc2" +
-	"\xd4\x7f\x92\xab\xe7<\xef\xfb>\x9f\xbf\xe7\xc37e[" +
-	"\xd7\x0977\x99Y\x00\xe5TS\xb3\xf7\xa7\xd5WO" +
-	"\xff\xd1\xf1\x9f\x1f\x06\xa9S\xf0\xbe\xf5\xfcp\xfeS\xe7" +
-	"\xd0\x7f\x00`\xcf#\xcd\xfbQ>\xd7,\x02\xc8?l" +
-	"\xde\x0a\xe8\xfd\xebM\x07\xde\xdb\xf5\xabc\xf7\x81\xd4\x89" +
-	"\x09gF\x04\xe8y\xa9y\x16\xe5\xffl\x16\x81y\x8f" +
-	"\xdc\x91\xff\x17|\xf4\x93c }\x05\x01\x9a\x90>\xff" +
-	"\xa4y\x91\x00(\xbf\xde\\\x00\xf4^\xbd\xf1\xf9\xe7\x8e" +
-	"\xfe\xf8\xde\xef\x81\xf2eD\x08\xce\x7f\xd4\xfc\x1b\x04\x94" +
-	"\x9bDb\xf8\xe8\x07_\xcd\xfc\xf0\xd5\xab\xbe\xef3x" +
-	"g^\xbf\xf5\x99\xa3?\xfe\xd2\x070.\x88\x98\x01\xe8" +
-	"Y)Z\xc4\xbbV\xfco@\xef\xbbo\xbd\xb0\xa5z" +
-	"\xec\xe4i\x90\xbe\x1c\xdd\xd5\xd1\"\x08\x90\xf1\xd6\xfc\xfb" +
-	"\xc5\xad\x9b\x9f\x99x<\xf8\x12\xc8\x91my\x86\x8ev" +
-	"\xb5\xd03\xaf\xdc\xd9\xfe@\xdf\x1f?\xf88(\x9d\x98" +
-	"\xd2\xa7\xa9\x898\xfbZfQ\xbe\xbd\x85~\x8e\xb7\xdc" +
-	"\x8a\x80\xde\xec\xda\x17\xb6\xfd\xea/\xec\xa7@Y\x89\x19" +
-	"\xef\x9f\xee\x7fw\xdf\x8a''^\xf6\xa5b\x00=\x17" +
-	"ZO\xd3\xd5o\xb7\xfe\x08\xd0\xcb\xfe\xc3\x0d[\x1e|" +
-	"o\xd39\xba:e\xd4@\x88\x99E\xbd(\xdf\xbf\x88" +
-	"\xecz\xcf\"\xe2~\xed\xc6m?\xfd\xe9\xd3\x95s\xf5" +
-	"\x82\x08\xc4}\xfd\xe2a\x94\xd7.&\xee5\x8b\x89\xfb" +
-	"\xea!|\xe7g7g\xfe.\xd4\x8b\x11\xd3\xfb\x8b?" +
-	"\xa0\xc7?\xf3\x19\xee\xf8\xed\xb3\xff\xb8\xe1\xc37~\x92" +
-	"v\xc0#m\x029\xe0\\\x1b)\xdeu\xa9?k|" +
-	"x\xe8gs\xfd\x18\xdc\xf4v\xdb0\xca\x97\xda\xe8\xb9" +
-	"\xf7\xdb\xe8\xb6\xbfr\xdfz\xc3\x1c\x1e~\xa9^8\xff" +
-	"\xda{\xb2\x02\xca\xc7\xb2\xc4}$[\x00\xfc\xe4\xa9{" +
-	"\x8f\x0e\xbd\xbb\xfee\xa5\x133\xf5\xbc\xc7\xb2\xfbQ>" +
-	"C\xbc=\x8fe\xbb\xc9\xa2\xb1\x0d\xeb\xd8}\xbd__" +
-	"2\x85\xf2\xc5%\xf4\xf3\xbf\x96\xf8\xec\xc3w|\xe7\xa1" +
-	"\xa6\x8b\xdfy\xb9\xde\xa8\xa2o\x81\x9c\x85\xf2g9\xfa" +
-	"\xf9i\xeeq\x01\xd0\xfb\xcd\xd9?\xe7\x17W\xbdr\x01" +
-	"\x94/aJ\x8dq\x14Q\x00\xe8\xb9x\xd5j2\xd9" +
-	"\xa5\xab\xee\x04\xf4:\x9f\xfe\x93\xbf\xed/\xbf\xfd\xf3:" +
-	"\x8b\x90 \xf2f\xf9c\xf9v\x99~\x8d\xcb\xc4{\xef" +
-	"Wg\xf6o\xb9~\xf6\xcd\x86\x069'\xcf\xa2|\xc1" +
-	"\xe7~\xc9\xe7\x16.\xaa\x1dw\xfd\xdb\xd7\xdeI\xc5\xe7" +
-	"\x9a\xfc/\x112\xde\x96mwL\xb5~\xf3\xddw\xd3" +
-	"\xf1\xb9\"\xef\xfb\xf1\xcf\xf2\xe4\xa6\xf3\xd2C\xf2\xf3\x8f" +
-	"\xfd\xcd{\xf4\x90X\xef\xa7\x9d\xf9\xed(\xef\xcd\xd3\xcf" +
-	"j\xde\xd77\xce\x93FQ\xa4]\xd3\x8b\xf2\xcc5$" +
-	"\x97{\x0d\xc9\xb5fW\x1f\xdfq\xcbm\x1f\x80\xd4\xc9" +
-	"\xe6d\xfd\xb3\xc4\xf9\x12q\xf6\xbcx\x8d\x88\xf2\xde\xa5" +
-	"\"\x80\xf7\xed\xca\xf6\x0b\x1f\x0d<\xf6\xbf\xf5\x97\xfb\x0a" +
-	"\xdd\xbe\xb4\x17e\x8d\xf8z\xf8R\xdfU=7\xff\xe5" +
-	"\xa5\xe3\x7f=\xf0\xd1\xbc\xdb\xcfu\xf4\xa3\xfcb\x07\xc9" +
-	"\xf1B\xc7\xd7\xe5K\x1d\xfe\xe5\xdfZ\xbfu\xed\xf2\x17" +
-	"?N[\xe2\xcd\x8e\x8f\xc9\x12\xefw\x90%&n\xf9" +
-	"\x9f\xaf_\xff\xed\x7f\xfe\xb8\xce=>ck\xe7\x0d(" +
-	"wt\xd2\x8dWw\x16\x00?\xdc\xf8\xfd7:s\x9d" +
-	"\xbfn$\xe8\xda\xce)\x947\x13o\xcfP\xa7/\xe8" +
-	"m\xbf<yg\xe1{\xbf\xfe\x84\xf4bu\x986s" +
-	"\xedv\x94\x8f\\K7\xdf\x7f-\xa5\xc2\xa6\xb3o\x7f" +
-	"m\xf2\xf8+\x9f\xd6\x1b\xc1w\xc8\x9ae\x87P\x1eZ" +
-	"F\xdc\x1b\x96\x112\x1d\xf8\xf0\xc4\xe0\x83;\xce~\x9e" +
-	"\xd6je\xd7s\xbe\x7f\xbbH\xab\xa9\xe3\x07\x9c\xc1\x87" +
-	"\x8fx\x8d\xd2pgW?\xca\xd5.\xbaM\xeb\xfa\x11" +
-	"\xac\xf4\x1c\xd70\xb8n\xd52\xa5?\x88~\x96V\x95" +
-	"\xd4\x9aQ\xeb\xdd0\xad\xd9\x8efT\xc6|z\xa1h" +
-	"\xeaZi\xa6\x88\xa8\xb4Q\xa0K]\xbd\x00\x88\xd2\xd5" +
-	"\xdb\x01P\x90\xa4~\x80\x82V1L\x8b{e\xcd." +
-	"\x99\x86\xc1\x81\x95\x9c\x83\xbbU]5J<~\xa8i" +
-	"\xfeC\x83\\\xd7\xcd[MK/o\xb5\xb4\x8af\x0c" +
-	"\x98\xc6\x84V\x01(\"\xc6\xc7\xc4\xf9\xc7\x06t\x8d\x1b" +
-	"\xce(\xb7\xf6i%\xbe\xca\xb5yp\xce\xb5TG3" +
-	"\x8d\xebF\xb8\xed\xea\x8e\x0d\xa0dX\x06 \x83\x00R" +
-	"\xb6\x17@ia\xa8\xe4\x05,X>\x03\xb6'\x99\x07" +
-	"\x88\xed\x90\xbc\xd9<\xff\xcd\xc0\x16\xf4&\xb7V\xb9\x86" +
-	"\xc5+\x9a\xedp+ _W(\xaa\x96Z\xb5\xd3\x0f" +
-	"\x9e\x04P\xda\x19*\xcb\x04\xf4*\x96Z\xe2En\xa1" +
-	"f\x96\xb7\xa8\x869\xcax\x09\x9b@\xc0\xa6\xd4\xa3\x0d" +
-	"\x1c\xb1Q\xd5t^\x0e\xb4[U\xea\xf6\xffW\xdaY" +
-	"\xa6\xcd\xf3\xfcG\xd4\xed\x00\xca.\x86\x8a.`\x16?" +
-	"\xf7\xf2T\xfe$m?\x802\xc9Pq\x04\xcc\x0a\x9f" +
-	"yy\xdfk{\x97\x03(:CeZ\xc0,\xfb\xad" +
-	"\x97\xa7\"#\xb9S\x00\x8a\xc3P\xb9K@\xcfvk" +
-	"dS\x1b\x98ia{\x12\xca\xa1ux\xb9B\x966" +
-	"\xa0\xc0Kdhl\x8f\xd09`\x10\xcb\xe6$\xb6'" +
-	"\xc5'<f\xf1}\xdc\xb2y\x11r\x969=\x83\xed" +
-	"\x09J\xd7Y=\xfbE\xad\x1e9:>\xb5\xf0y?" +
-	"4K\xceu\xc5\xeey\xce\";\xb61T\x96\x0a\xe8" +
-	"\xd5\xe8+w80\xcb\xc6\xf6\xa4\xa8\xd7I\xdb \x9c" +
-	"\x07\xe8\xdf\x81\xe0\x95bx\x8be\xfb\xe1\xac,\x8d\x1f" +
-	";A\x8f=\xccP\xf9\x81\x80\x12b\xe0\xb3\xc7,\x00" +
-	"\xe5Q\x86\xcaY\x01Q\x08<\xf6\xe4i\x00\xe5,C" +
-	"\xe5\xef\x05\x94\x98\x108\xec\xd9\x1b\x00\x94\xa7\x19*\xbf" +
-	"\x10P\xca\xb0<50\xd2\x05\x0a\xb6_0T\xde\x12" +
-	"Pj\xca\xe4\xb1\x09@zs5\x80\xf2\x1aC\xe5\x1d" +
-	"\x01=3\xc8/R\xca\xc1,\x08\x98\x05\xf4J\xba\xe9" +
-	"\x96't\x15\xba-^\x1eZ\x1f\xd3\x0d\xb7Z\xb4\xf8" +
-	">\x0dM\xd7\xees\x1c^\x15k\x8e\x8d\xcd `3" +
-	"`\xceQ+6.\x01,2\xc4\xf6\xa4\xd0\x01\x121" +
-	"\xbe\x13-^\xde\xc6-[c\xa6\x81m `\x1b`" +
-	"\xb7]2k\x1c\xdb\x93\xdayy\x9b\x8e\x84\xd1C\xb1" +
-	"\x13&\x82iibE3\x946\x96Y\xe6y\xa1\x01" +
-	"7\x90]\xd61T6\x09\xd8\x85\x9f\x13\x99l84" +
-	"\x02\xa0\x0c2T\xc6\x04\xec\x12>#2YQ!\x1f" +
-	"\x14\x19*;\x04\xccM:N\x0d\xdb\x93\xea\x18\x0au" +
-	"'\xdfm\x9b\xa5=\x1c\x90\xa0\"\x86\xea\xf0\xebd\x08" +
-	"]\xc0\xf42\xb6'\x9dl\x9dF\xacA\x94\xf8\x01R" +
-	"p6X\x96i\xf9\xa8\x1a\x87\xc6\x86\xd5\x89\x12Qd" +
-	"\x0cmO4\x90\x84u\x81Z\xca\xeeD\xfe\xee\x92\xea" +
-	"\xda<\xb2\xb1gq\xc7\x9a\xe9\x9bp\x80q+\xc6\x18" +
-	"{\xd2t\xf5\xf2\x08\x07\xd1\xb1f\x10A@\\\x18y" +
-	"\xd6\x9b\x83)\x93\x07!\x9c\x92\x93dZ\xcfP)&" +
-	"rn&\xda&\x86\xcam$gh\xfeq2\xff\x18" +
-	"C\xa5&\xa0\xa7S\xee\x1a\x83&0\xdb\x89\xc5\x0d\x88" +
-	"E\xd3\x0fL\x11\x04\x14\x01=\xb7f;\x16W\xab\x80" +
-	"q\xa4\x11\xff\x92/\x00\xd1uPQTs~\xce7" +
-	"\xd6!N\xc3\xcd\xc3i%\xc2<\x1c\xefO\x8c\xdd8" +
-	"\x91&M\xdb1\xd4*\x07\x80H\xb1\x83f\x8d0\x92" +
-	"\x10$n3\xebb\xe3\x8bW\xb6\xa0\xca\xcc\xa9k\xa7" +
-	"Se\xa6\x14\x9eF\xff\xf8\x80i\x88\x13Z\x05\xdb\x93" +
-	"^\xabN\x80\x06~\xefs\x9dIn8Z\xc9\x7fp" +
-	"\x9e\xdf\x97'\xf1\x19\xdblhu\xca\x90\x91\xcd6\xef" +
-	"N\x0c)\xee\xe131\x04\xf0\xaa\xaa\xe9\xb1\xf7Ck" +
-	"\xf6\x81\xf8\x8d\x84g\xa1\xe4\x19%\x04\xf1\xa5\xf2m\x80" +
-	"\xa9\xae\\\xca\xae\x06\xa1{\x9f\xaa\xbb|\xc1\xe6&\xac" +
-	"bA\x0d+\x04\x06\xa6\x0b\xf3\xb1\x9a\xdf\x9c\x05P\xee" +
-	"b\xa8<\x90R\xf3\xfe\x87\x00\x94\x07\x18*\x0f\xa7\xd4" +
-	"<N\xa1q\x94\xa1r\x8a \x9a\x05\xe0r\x82|r" +
-	"\x8a\xa1\xf2\x84\x80\x98\x09\x10\xfa\x0c!\xf4\x13\x0c\x95\xf3" +
-	"\x82\x8f\xaf\x83}\x03\xa6\x81\xa1\x106@\x84\xae\xde$" +
-	"W-g7W\xd1\x192\x1cn\xedSQ\x8f\xb2\xf8" +
-	"\xa0\xa3U\xb9\xe9:qVW\xd5i\xbfC\xc0\xf2`" +
-	"pJT\x1d\x1b[A\xc0VJ\"\x9b[\x03\x16/" +
-	"#\xf9S\xd5\x8b*s&\xe7\x998s9\xc4\xcd5" +
-	"0\x0f\xf5\x17\x07\x18*\xf7Q\xf6cj\x90\x95\xee\xe9" +
-	"\x05\xc1O~\xd2\xb9\xda\x9f\xf4!~\xfd\xa2\xaa\xb4\xf7" +
-	"\xa1\xa4\xe1\xf0\xebW3\xddx21x(\xda\xa0\x09" +
-	"\x85 \xa9\"\x99\x0bA\xb0\x1c$\x80\xd3x\xa2gX" +
-	"\xd654\x8d1\xdf@\x98X\xa8dVk\x16\xb7m" +
-	"\xd4LCqU]c\xceL|pA\x1b\x10z\x04" +
-	"Y\xb7\xb5\xd6\xed;\x89\x8cpSd\x04\xb9\x0f\x87\x01" +
-	"F\xd7!\xc3\xd1M\x98\x84\x89<\x84\xfd\x00\xa3\xeb\x89" +
-	"^\xc4$R\xe4\xcd\xd8\x090:H\xf41\x14\x10\x83" +
-	"X\x91\x15|\x0a`t\x8c\xc8\xbb0\xa9\xe8\xf2N\xff" +
-	"\xfa\x1dD\x9f\xc4\xa4\xa8\xcb\x1co\x00\x18\xddE\xf4\x03" +
-	"Do\x16|\x0b\xca38\x050:M\xf4\xc3D\x17" +
-	"\x9b\xf24\x1f\xc8w\xa3\x050z\x17\xd1\x1f z\xcb" +
-	"\xd2<\xb6\xd0\xfc\xe1\xd3\xef#\xfaw\x89\xde\xda\x91\xc7" +
-	"V\x00\xf9\x18\x1e\x02\x18=J\xf4SD_\x84y\\" +
-	"\x04 \x9f\xc0\x93\x00\xa3\xa7\x88\xfe\x04\xd1\x177\xe7q" +
-	"1\x80|\xc6\x97\xe7Q\xa2\x9f\xc5\x18\x82\x86\xcai$" +
-	"\xa4p\xd2\x92.\x80\x99v\x1c\x86<\x9c40\x80\xe9" +
-	"\xa2\x99\xa3Q\x03s\xc9\x0e\x09\x10s\x80^\xcd4\xf5" +
-	"-s\x11\xf6r\x8dH\x18\x16\x903\x8d\xa1r\x9c_" +
-	"A\x10m2\xa1\xbb\xa4\xeaC\xb5X\x12\xcd\xees\x1d" +
-	"\xd3\xadAwYux9\xae\x91\x96kl\xb4\xcc\xea" +
-	"\x18r\xab\xaa\x19\xaa\x0e\xf1\x97\x85b+\xe7\xbaZ\xf9" +
-	"\x8a\xf1lU\x80ZJK\xd2\xd9\xaf\xa0\xea\xf4\x15\x86" +
-	"\xca\x1f\xa6;\xfb\x9b\x09jod\xa8\xdcB\xdd\xfa\x8c" +
-	"\xed\xf0\xea\x16\x15X\x92#\xdd\x15\xcbtk\xf3\x1e\x16" +
-	"\xea\x1f\xee\xae\xf5\x8e\xa9~Z\xb7\xc4i\xbd\x82:\xa8" +
-	"\xeb\x18*7\xa5Po\xe5\xeaD\x8e\\:\x1b\x03\x91" +
-	"\xe7\xbd\xd4\xa0\x83\x1b\xaf\xabbA\xd7\x1e\x94\x96\xd4\xeb" +
-	"\xfd\xc9\xeb\xf1\xe3V\xa8\xee\xa0\x80\x07m\xb7T\"k" +
-	"G\xe6\x9f\x08g#\xe8\xa6\xbbS\x81\x10\xaf-\xc2@" +
-	"\xb8\xd2\x8e\xa1\xc2\x9d\xe0\xd7\x901aR\xa9\x15\xd5\xaa" +
-	"\xfd\xff<=\xc2\xed\x1c\x8d&\x97\x9d@\xe3E\xc4\xe5" +
-	"K\xf3\xe0\xd8X1\x19\x93Y\x80\xcai@\x1aI\x03" +
-	"R\x82GSi\xdc\x89:HY\xf1\x01\xa0H\xf4\x1d" +
-	"\x98\xcc\x17\xf2\xedxz\x0e\xf0d\xfa\x02@\xe2\xfe\xf5" +
-	"e\xa2\xd7|@\xc2\x00\x90\xaa\xfe\xfd:\xd1\xa7\xd3\x80" +
-	"\xe4\xe2\xec\\@b\x11 \x11\x90\x1c&\xfaQ\x1f\x90" +
-	"2\x01 \x1d\xc1g\xe6\x00OkS\x00H'\xf0\xb9" +
-	"9\xc0\xb3\xa89\x00\xa43>\xff\x13D?\xef\x03R" +
-	"\x7f\x00H\xe7|\x00{\x9a\xe8\xcf\x13 \xb9\x96>\xea" +
-	"X\x9a\x01XI\x82\xb5T\xfb\x06\xe7\xb5>\xc8\xe9\xda" +
-	">\x1e\x17\x8b\xb2\xa6\xea\xeb]U\x87\xeeQG-\xed" +
-	"I\xdad\xdd\x1eT\x8d\xb2\x8d\x93\xea\x1eN%FL" +
-	"\x17aG\xb7\xb7qK\x9b\x00L\x1a\xeb\xb8\xad\xc9\x15" +
-	"M\xb3\xbe\xdb\xf1\xfb3n\x05h\x16\x7f\xab\xaa\xd3C" +
-	"e\x9d\x0f`\xd4\x9a0#)q\x1a}1\x0d\x03\x83" +
-	"~aL\xeb\x9e\xdb\x08\xd4\xc2V=j(\xc6\x0au" +
-	"\x9d\x02\x9f\xae\xf1\x923`\xa2\xe1h\x86\xcb\xe7]P" +
-	"\x9at\x8d=\xbc\xbc\x01\x8d\x92Y\xd6\x8c\x0a\xcc\x9b\x11" +
-	"\xd8\xef\xdaN\xa4:\xa8\x96\xb0%\x8b\xd7\xed\xd2\x0a\xea" +
-	"\x070\xec\x07\xa4\xded\xca.\x94\xfcS\x05\x8b\xabv" +
-	"R\x1a\x16z-\\F\x05IF\xaf\xb5\xb3&\x80x" +
-	"y\x8d\xd1\xceO\xda\xbb\x1f\x04I\x131Y\x95b\xb4" +
-	"\x19\x95vZ H\xe3\"\x0a\xf1\xdf\x0c0\xda\xf7K" +
-	"C\xb3 H\x1bDd\xf1\xa2\x1f\xa3\x8d\x9b\xb4\xb6\x1f" +
-	"\x04i\xa5\xe8ES\x05\x14\x02q\xd6\xa1\x17%>t" +
-	"\xfb\xa9\xbf\x0e\xbdhO\x81\xd1\xf4\x01\xb0\x0e\x0f\x86\xf5" +
-	"h\x1d\xa6\x97[\xecw\x8d\x00\x8d\xfbR\xc2\xc8i\x86" +
-	"\xca\xe1\x04#\xef\xa6^\xf50C\xe5hj\xec:\xf2" +
-	"T\xba-\x0d7\x07'\x0e\x85{\x87\xf3\xa9\xcd\xc19" +
-	"\xeaU\xcf3T^\x13\x92B\x1d\x85]\xb4\x0fB\xd3" +
-	"\x8a\xe6\xc0\x05\xd6Bap\x86-c\xfdr\xc8+\x9b" +
-	"\x93~K\x89\xc1U6$\x88\x9d\xde\x18-Im\x8c" +
-	"0\x9a@\xc59\x00\x9f\xde\x1f-Y\x183\xe7\xccS" +
-	"\xe1\xd8@Q\x13\xfdE\x03\xa3\xbf-I\x12y?+" +
-	"z\xd1\xcc\x85Q\xb9\"\xe7\xa5]\xf6\x05\x07\xcf\x11\xde" +
-	"m_I%\x88\xb6\xcc\x97\xdf\x1f\x04\xef\xe4(\xd8\xe2" +
-	"9(\xb8w*\xb5\xc5\xd2\xcdp\x84\xcbmIU\xed" +
-	"\x85l\x15\x08\x1cu\xbe9:\\\x17~\xcb\x93\xf0\x8b" +
-	"\x1b\x84\xbb\x97'\xc3@<\x16\xdd3\x1c\x06\xe5\xa3q" +
-	"\xa7+=2\x9bl\xb8\xe2\xf0{r8\x19\x8bDn" +
-	"Y\x91\x9c\xa2k%\xb0\xa9\x9b\x95M\x9a\xc1m\xea\xfd" +
-	"\xea\xb6\x015nUU\x83\x1b\xe8\x10\x18\xb9\x16!\xea" +
-	"\\\xe4\x1aZ\x9fj\x19\x17R\x7f4\x0c\xf6 \xd6\xc3" +
-	"\xf2\x9a\x1a}O\xa7\xd60\x91\xf2\xcas\xe1zcW" +
-	"J\xf9\x9d4\xfa\xee`\xa8L\x0a\xe8\xa9\xaec\x8e\xd7" +
-	"\xca*:|\xa3\xc5\xf7\xba\\4J3\xc9\x00G\xa3" +
-	"L\xc9\x1e\xc7\x1a5\x9d\x1b-^\xd8\xeb\xf24C\xb4" +
-	"8\x06Q3\xcb\xf36\xc6\x0d\x9a\xad[\xf9\xeeQ\xb3" +
-	"\xb4\x87;s\x16\xea\x01\\F\xaa\xa8#\xc9\xd68\xd2" +
-	"D\x1bI\xcdj\x11\x8c\xec\xa5\x80\xaa1T\x0e\xa4`" +
-	"df6qx\xe3\xea\xfa\xfb)\x88\x0b(\xd9p\xab" +
-	";R\xe0W\x94h\xc9_I.\xdfr\x85{\x82\xb0" +
-	"c\xbd\x92]\xc8\x9ce]\x18\x10\xcaT\xb2?\xf2\x83" +
-	"\xbc=\xf9sn(\x83\x1d\xb6\x8e\xc0&\xcc\x06]a" +
-	"0U\x0d\x98\x10Bk\xfdF\xe7\xff\x02\x00\x00\xff\xff" +
-	"\xd4\xcdz3"
+const schema_db8274f9144abc7e = "x\xda\xacY\x7fp\x1c\xf5u\x7fo\xf7\xa4\x95l\x9f" +
+	"\xee\xb6+\xcd\xd8\xc2\xf2%\x1e\x98\x04\x07\xbb\xd8.\x0d" +
+	"\xa8m\xce\x92lG\xa7\xd8\xf2\xadN6\xc4\xd83^" +
+	"\xef}uZyo\xf7\xbc?\x8c\xe411\xb8\xa6`" +
+	"\x15\x82!q\x07;\x90\x017\x94\x1fc\x1aL\x9ci" +
+	"Cq&tB\x81&3\x90)t\xa0\xa5\x7f4\xe0" +
+	"\xe9\x0cS\x86\x9a$\xc3\x84\x01\xb6\xf3\xf6\xb7N7\xb2" +
+	"\xdd\xa9\xff\xb0\xf6\xde\xbe\xef\xf7\xfb\xde\xfb\xbe\xf7y?" +
+	"\xf6\xfa\xd1\xce\x0d\xdc\xda\xb6\x97\xb3\x00\xf2\xa3m\xed\xde" +
+	"\x9f\xd6_;\xfd\xc7'~q\x14\xc4^\xce\xfb\xd6\x0b" +
+	"#\xdd\xbfw\x8e\xfc;\x00\xae\x7f\xb5\xfd J\xff\xd1" +
+	".\x00Ho\xb7o\x03\xf4\xfe\xe5\xfaC\xef\xed\xf9\xcd" +
+	"\x03\xf7\x80\xd8\x8b\x09gF\x00X\x7f\xb1}\x16\xa5N" +
+	"A\x00\xde{\xe4\xd6\xee\x7f\xc6G?~\x00\xc4/#" +
+	"@\x1b\xd2\xeb\x0b\xed\x8b8@\xe9\xb3\xf6\"\xa0\xf7\xda" +
+	"u/<\x7f\xfcGw\x7f\x0f\xe4/!B\xb0\xbeO" +
+	"\xf8\x04\x01\xa5\xb5\x021\\\xfc\xc1W2\xcf\xbc\xf6\x07" +
+	"\xdf\xf7\x19\xbc\xc7\x7fu\xf3s\xc7\x7f\xf4\x85\xf7a;" +
+	"'`\x06`\xfd7\x05\x8bx\x99\xf0_\x80\xdew\xdf" +
+	":?Z\x7f\xe0\xd4i\x10\xbf\x14\xed\xb5\xa9\x83\xe3 " +
+	"\xe3\xdd\xf0o\x17\xb6m}n\xe2\x89\xe0M \xc7M" +
+	"\x1d\xcf\xd1\xd2R\x07\x1d\xf3\xcam\xf9{\x07\xbez\xff" +
+	"\x13 \xf7bJ\x9f\xb66\xe2\xacw\xcc\xa2tW\x07" +
+	"=\xde\xd9q3\x02z\xb37\x9d\xdf\xf1\x9b\xbf\xb0\x9f" +
+	"\x06y5f\xbc\x9f\x1f{\xf7\xc0\xb5OM\xbc\x1cK" +
+	"\xf5\xbb\xce\xd3\xb4u\xdb\"\x92J\xf9j\xff\x8e\xf3?" +
+	"W\x9fi\xde\xda\x17\xe2?\x17\x8d\xa1\xf4\xbbEd\xd7" +
+	"\x8b\x8bn\x03\xf4\xb2\xff\xb0j\xf4\xfe\xf7\xb6\x9c%n" +
+	"\xae\x99[^\xdc\x8f\x92\xb2\x98\xb8w/\xfe!\xa0\xf7" +
+	"\xfau;~\xfa\xd3gkg\x9b\xf7\xe6\x88\xfb\xb3\xc5" +
+	"#(\x89K\x88;\xbb\x84\xb8{J\xf8\xce\xcf\xd6f" +
+	"\xfe.\xb4\x02OL/.y\x9fD}\xd3g\xb8\xf5" +
+	"\xd3\x1f\xff\xe3\xa6\x0f\xdf\xf8I\xfa\xba\xdc,G\xd7u" +
+	",Kf\xea\xfb`0k|x\xe4gso=\xd8" +
+	"\xe9lv\x04\xa5\x97\xb2t\xdc\x8bY\xda\xed\xaf\xdc\xb7" +
+	"\xde0GF^j\xa9\xf8\xee.\x0e%\xad\x8b\xb8Y" +
+	"W\x11\xf0\xe3\xa7\xef>^zw\xe3\xcbr/f\x9a" +
+	"y\xb5\xae\x83(\xddN\xbc\xebg\xba\x0a\x08\x98X\xbc" +
+	"\x89\xdd\xd7\xfb\xa9\xdc\x14J\xe7s\xf4\xf8\x93\x9c\xcf>" +
+	"r\xebw\x1el\xbb\xf0\x9d\x97\x9b\x8d*\xf8\x16\xc8[" +
+	"(\xbd\x99\xa7\xc7_\xe5\x9f\xe0\x00\xbdO\xce\xfc9\xbb" +
+	"\xb0\xe6\x95WA\xfe\x02\xa6\xd4\xd8\x8e\x02r\x00\xeb\xcf" +
+	"K\xeb\xc8d/It_\xbd\xcf\xfe\xc9\xdf\x0eV\xdf" +
+	"\xfeE\x93EH\x10\xe9\x9a\xee\x8f\xa4\xb5\xdd\xf4\xb4\xba" +
+	"\x9bx\xef\xfe\xca\xcc\xc1\xd1kf\xdfli\x90c\xdd" +
+	"\xb3(=\xe6s?\xe2ss\x17\x94ew\xfc\xeb\xd7" +
+	"\xdeIys\xb6\xe7\xd7\x08\x19ot\xc7\xadS\x9d\xb7" +
+	"\xbf\xfbn\xda\x9b\xb1\xc7\xbf\xc7\x9e\x1e\xba\xa6s\xe2\x83" +
+	"\xd2\x0b\x8f\xfd\xcd{t\x90\xd0|O7\xf4\xecD\xa9" +
+	"\xd4\xe3GG\x8f\xafo\x1cU\xad\xbch`i?J" +
+	"\xf2R\x92k\xebR\x92\xeb\x86=\x03l\xd7\x8d\xb7\xbc" +
+	"\x0fb/?\x07#\xee#\xceG\x88s\xfd\xc9\xa5\x02" +
+	"J\xa5e\x02\x80\xf7\xed\xda\xceW/\x0e=\xf6?\xcd" +
+	"\x9b\xfb\x0a\xad]\xd6\x8f\xd2\x00\xf1\xad\xff\xb3e\xfeU" +
+	"\xad_\xfb\x97\x1f\x9c\xf8\xeb\xa1\x8b\xf3v?\xd6;\x88" +
+	"\xd2\xc9^\x92\xe3D\xef\xd7\xa5\x97z\xfd\xcd\xbf\xb5q" +
+	"\xdbM+_\xfc(m\x89gz?\"K\xbc\xd8K" +
+	"\x96\x98\xb8\xf1\xbf\xbf~\xcd\xb7\xff\xe9\xa3\xa6\xeb\x09b" +
+	"\xafw\x15J\x17\xfd\x1d? \xe6\x0f7\x7f\xff\x8d\xde" +
+	"\\\xefo[\x09*^5\x85\xd25W\xd1\xe3\x17\xaf" +
+	"\xf2\x05\xbd\xe5\xd7\xa7n+~\xef\xb7\x1f\x93^|\x13" +
+	"\x02\xca\xcbw\xa2\xc4\x96\xd3\xce\xcar\x0a\x85-g\xde" +
+	"\xfe\xda\xe4\x89W~\xdfl\x04\xffB\xb2}GP\xfa" +
+	"b\x1fq\xf7\xf5\x11b\x1c\xfa\xf0\xe4\xf0\xfd\xbb\xce|" +
+	"\x9e\xd6\xaam\xc5\xf3\xfe\xfd\xae \xad\xa6N\x1cr\x86" +
+	"\x1f\xba\xcfk\x15\x867\xac\x18Di\xd3\x0a\xdam`" +
+	"\xc5\x0fa\xb5\xe7\xb8\x86\xc1t\xab\x91Q\xff0zT" +
+	"\xd7\xa8J\xc3h\xf4o\x9a\xd6lG3j\xe3>\xbd" +
+	"X6uM\x9d)#\xcaK\x90\x03\x10\xfb\xfa\x01\x10" +
+	"\xc5\x9e\x9d\x00\xc8\x89\xe2 @Q\xab\x19\xa6\xc5\xbc\xaa" +
+	"f\xab\xa6a0\xe0U\xe7\xf0^EW\x0c\x95\xc5\x07" +
+	"\xb5\xcd?h\x98\xe9\xbay\xb3i\xe9\xd5m\x96V\xd3" +
+	"\x8c!\xd3\x98\xd0j\x00e\xc4x\x990\x7f\xd9\x90\xae" +
+	"1\xc3\xa90\xeb\x80\xa6\xb25\xae\xcd\x82u\xae\xa58" +
+	"\x9ai\\=\xc6lWwl\x009\xc3g\x002\x08" +
+	" f\xfb\x01\xe4\x0e\x1e\xe5n\x0e\x8b\x96\xcf\x80\xf9$" +
+	"\xf2\x001\x0f\xc9\x99\xed\xf3\xcf\x0clAg2k\x8d" +
+	"kX\xac\xa6\xd9\x0e\xb3\x02\xf2\xd5\xc5\xb2b)u;" +
+	"}\xe0)\x009\xcf\xa3\xbc\x9cC\xaff)*+3" +
+	"\x0b5\xb3:\xaa\x18f\x85g*\xb6\x01\x87m\x80\x0b" +
+	"]\xc4fE\xd3Y5\xd0n\x8dZ\xf0\xff\xcay>" +
+	"\xb3\xc4\xf3\xfcC\x94\x9d\x00\xf2\x1e\x1ee\x9d\xc3,~" +
+	"\xeeu#Q\xb5\x83\x00\xf2$\x8f\xb2\xc3a\x96\xfb\xcc" +
+	"\xeb\xf6om\xffJ\x00Y\xe7Q\x9e\xe60\xcb\x7f\xea" +
+	"u#\x0f \xbaS\x00\xb2\xc3\xa3|\x07\x87\x9e\xed6" +
+	"\xc8\xa66\xf0\xa6\x85\xf9\xc4\x95C\xeb\xb0j\x8d,m" +
+	"@\x91\xa9dh\xccG\xe8\x1c0\x08Us\x12\xf3I" +
+	"\xf2\x09\x97Y\xec\x00\xb3lV\x86\x9ceN\xcf`>" +
+	"A\xe9&\xabg\xaf\xd4\xea\xd1E\xc7\xab\x16^\xef\xbb" +
+	"\xa6\xea\\].\xcc\xbb,\xb2\xe3\x12\x1e\xe5\xa5\x1cz" +
+	"\x0dz\xcb\x1c\x06\xbcec>)\x01\x9a\xa4m\xe1\xce" +
+	"C\xf4\xffPpJ9\xdc\xc5\xb2}w\x96\x97\xc6\x87" +
+	"\x9d\xa4\xc3\x1e\xe2Q\xfe\x01\x87\"bpg\x8fYT" +
+	"j\xf1(\x9f\xe1\x10\xb9\xe0\xc6\x9e:\x0d \x9f\xe1Q" +
+	"\xfe{\x0eE\x9e\x0b.\xec\xc7\xab\x00\xe4gy\x94\x7f" +
+	"\xc9\xa1\x98\xe1\xbb1\x03 \xbeJ\xce\xf6K\x1e\xe5\xb7" +
+	"8\x14\xdb2\xdd\xd8\x06 \xbe\xb9\x0e@~\x9dG\xf9" +
+	"\x1d\x0e=3\x88/R\xca\xc1,p\x98\x05\xf4T\xdd" +
+	"t\xab\x13\xba\x02\x05\x8bUK\x1bc\xba\xe1\xd6\xcb\x16" +
+	";\xa0\xa1\xe9\xda\x03\x8e\xc3\xeaB\xc3\xb1\xb1\x1d8l" +
+	"\x07\xcc9J\xcd\xc6.\xc02\x8f\x98O\x12\x1d v" +
+	"\xa5\xf6D\x8bUw0\xcb\xd6x\xd3\xc0%\xc0\xe1\x12" +
+	"\xc0\x82\xad\x9a\x0d\x86\xf9$w^\xda\xa6c\xa1\xf7\x90" +
+	"\xef\x84\x81`Z\x9aP\xd3\x0c\x0a\x84\xe5\x9e\x17\x1aP" +
+	"!\xbb\xec\xe2Q\x9e\xe4\xb0\x0f?\xf70\xb0!\x1b\x03" +
+	"\x90\xab<\xca\x0d\x0e\xfb\xb8\xcf<\x0c\xacX\xdf\x99\x04" +
+	"C\x1f\xff)\x91\x05\x8a\x06\x8a\x91\x06\x8f\xf2!\x0es" +
+	"\x93\x8e\xd3\xc0|\x924CYoc{mS\xdd\xc7" +
+	"\x00\x09Ab\x04\x0f\xdfN\x86\x88\x06\xbc^\xc5|R" +
+	"\x0e\x87\x11\xe2\xa8\xb4e\\\xfa5\xa9\xdf\x02\x01\xc6\x87" +
+	"\xca)h\xc4\x1a\xb9RG\xecJ\xd7\x0e\x02\xc8W\xf3" +
+	"(_\xcfa\xe4I\xab\xc9\x93\xae\xe3Q\xbe\x91\xc3\xc3" +
+	"J\xb5j1\xdb\x8e.\xc0k\x90\x19\xcb\x96\x09\x05\xc7" +
+	"TM\x1d\x118\xc4\x94\x04|\x0b\xa7\xf6\xfd\xb9\xe8l" +
+	"\xb2,\xd3\xf2\x93@|\xfc&\xf2\xb0\x0d<\xca[\x92" +
+	"\xe3Kd\xd9a\x1e\xe5q\x0eEnCp\x0b\xf2^" +
+	"\x00\xb9\xcc\xa3\xbc\x8b\xc3\x82\xaa\xb86\x8b%\xb2\x98c" +
+	"\xcd\x0cL8\xc03+\x86D{\xd2t\xf5\xea\x18\x03" +
+	"\xc1\xb1f\xe6\x09\xd9\xc2L\x1b\xcd\xe1\x94\x87\x004\xc9" +
+	"I2m\xe4Q.'rn%\xda\x16\x1e\xe5[H" +
+	"\xce\xd0[\xb6\x93\xb7\x8c\x07\xde\xe2\xe9\x045\xc6\xb0\x09" +
+	"\xbc\xed\xc4\xe2\x06\xc4\xb2\xe9\xc7\x91\x00\x1c\x0a\x80\x9e\xdb" +
+	"\xb0\x1d\x8b)u\xc080\x88\xbf\xeb\x0a2J\x13\xb2" +
+	"\x95\x95\x9c\x0fQ\xadu\x88Qc\xebHZ\x89\x106" +
+	"\xb6\x0f&\xc6n\x1d\xf7\x93\xa6\xed\x18J\x9d\x01@\xa4" +
+	"\xd8a\xb3A\x90N\x80\x17W\xc5M\xdey\xe5\x898" +
+	"H\x8as\xd2\xf0\xe9TVT\xc3\xd5\xe8/\x1f2\x0d" +
+	"aB\xaba>)\x0d/\x1d\x1e\x03\xae3\xc9\x0cG" +
+	"S\xfd\x03\xe7\xdd\xfb\xca\xc4?c\x9b\x95\xd6\xa5\x0c\x19" +
+	"\xd9l\xeb\xde\xc4\x90\xc2>6\x13#\x16\xab+\x9a\x1e" +
+	"\xfd\x8a\xac9\x00\xc27\x12\x9e\x85\x82\xa7B\x80\xe7K" +
+	"\xe5\xdb\x00SM\x84\x98]\x07\\\xe1\x80\xa2\xbbl\xc1" +
+	"Z,L\xbaA\xca-\x06\x06\xa6\x0d\xbbc5o\x9f" +
+	"\x05\x90\xef\xe0Q\xbe7\xa5\xe6\xb1\x07\x01\xe4{y\x94" +
+	"\x1fJ\xa9y\x82\\\xe38\x8f\xf2\xc3\x94Q\xf8\x00\x0b" +
+	"O\xd2\x9d<\xcc\xa3\xfc$\x87\x98\x09\x12\xca\xe3\x94P" +
+	"\x9e\xe4Q>\xc7\xf9\xe9`x`\xc840\x14\xc2\x06" +
+	"\x88\x92\x817\xc9\x14\xcb\xd9\xcb\x14tJ\x86\xc3\xac\x03" +
+	"\x0a\xeaQ\x14\x1fv\xb4:3]'\xfa\xed\xd5\x95i" +
+	"\xbf\xa0\xc1\xeap\xb0JP\x1c\x1b;\x81\xc3N\x0a\"" +
+	"\x9bYC\x16\xab\"\xdd\xa7\xa2\x97\x15\xde\x99\x9cg\xe2" +
+	"\xcc\xa5\x12D\xae\x85y\xa8\x1c:\xc4\xa3|\x0f\x87\xf4" +
+	"/\xe9\xd2\xc5\xbb\xfa\x81\xf3\x83\x9ft\xae\x0f&e\x93" +
+	"\x9fn)\x89\xee\x7f0\xa9\x8f\xfct\xdbN;\x9eJ" +
+	"\x0c\x1e\x8a6lB1\x08\xaaH\xe6b\xe0,\x87\x09" +
+	"\xe04\x96\xe8\x19V!\x1a\x9a\xc6\xb8o L,\xa4" +
+	"\x9a\xf5\x86\x8f\xd7\x9ai\xc8\xae\xa2k\xbc3\x13/\\" +
+	"\xd0\x06\x84\x1eA\xd4mk\x14\xe8\x8fMF\xb8>2" +
+	"\x824\x80#\x00\x95\x0d\xc8ce\x0b&n\"\x95p" +
+	"\x10\xa0\xb2\x91\xe8eL<E\xda\x8a\xbd\x00\x95a\xa2" +
+	"\x8f#\x87\x18\xf8\x8a$\xe3\xd3\x00\x95q\"\xef\xc1\xa4" +
+	"\x00\x91v\xfb\xdb\xef\"\xfa$&5\x88\xc4p\x15@" +
+	"e\x0f\xd1\x0f\x11\xbd\x9d\xf3-(\xcd\xe0\x14@e\x9a" +
+	"\xe8G\x89.\xb4\xf9\x09X\xba\x13-\x80\xca\x1dD\xbf" +
+	"\x97\xe8\x1dK\xbb\xb1\x03@:\xe6\xd3\xef!\xfaw\x89" +
+	"\xde\xb9\xac\x1b;\x01\xa4\x07\xf0\x08@\xe58\xd1\x1f&" +
+	"\xfa\"\xec\xc6E\x00\xd2I<\x05Py\x98\xe8O\x12" +
+	"}q{7.\x06\x90\x1e\xf7\xe5y\x94\xe8g0\x86" +
+	"\xa0R5\x8d\x84\xe4NZR\xb4\xf0f\x92>Y\xd8" +
+	"\x18a\x00\xd3e3G\x9d\x11\xe6\x92\x01\x19 \xe6(" +
+	"\xcf\x9a\xa6>:\x17a/U7\x85n\x019\xd3(" +
+	"U\xe3\xf8\x0a\x9ch\x8b\x09\x05U\xd1K\x8dX\x12\xcd" +
+	"\x1ep\x1d\xd3m@\xa1\xaa8\xac\x1a\xe7H\xcb56" +
+	"[f}\x1c\x99U\xd7\x0cE\x87\xf8\xcdB\xbe\x95s" +
+	"]\xadz\xd9x\xb6&@-\xb9#iD\xae\xa5\xec" +
+	"\xf4e\x1e\xe5?J7\"k\xd7%\xa5\x88g\xcf\xd8" +
+	"\x0e\xab\x8f*\xc0'1R\xa8Y\xa6\xdb\x98w0\xd7" +
+	"|p\xa1\xd1?\xae4\xd7>\xab\x92\xda'F\xbd\xd5" +
+	"\xeb\x129r\xe9h\x0cD\x9ewR\x8b\x82s{S" +
+	"\x16\x0b\x9a\x0c\x80\xa6\xd3\x17\xaa\xbc\x869<l\xbb\xaa" +
+	"J\xd6\x8e\xcc?\x11\xb6rP\xa0\xbdS\x8e\x10OY" +
+	"BG\xb8\xdc\x8a\xa1\xc6\x9c\xe0\xa9dL\x98\x94j\x05" +
+	"\xa5n\xff\x1fW\x8f1;G\x9d\xd4%\x1b\xe6xn" +
+	"r\xe9\xd4<<>\x9e*]y\xad\xd6\x0cHci" +
+	"@J\xf0h*\x8d;Q\x05)\xc9>\x00\x94\x89\xbe" +
+	"\x0b\x93vH\xfa&\x9e\x9e\x03<\x99\x81\x00\x90\x98\xbf" +
+	"}\x95\xe8\x0d\x1f\x900\x00\xa4\xba\xbf\xbfN\xf4\xe94" +
+	" \xb98;\x17\x90\xf8\x08\x90\x08H\x8e\x12\xfd\xb8\x0f" +
+	"H\x99\x00\x90\xee\xc3\xe7\xe6\x00Og[\x00H'\xf1" +
+	"\xf99\xc0\xb3\xa8=\x00\xa4\xc7}\xfe'\x89~\xce\x07" +
+	"\xa4\xc1\x00\x90\xce\xfa\x00\xf6,\xd1_@\x0e=\xd7\xd2" +
+	"+\x8e\xa5\x19\x80\xb5\xc4Y\xd5\xc67\x18k\x0c@N" +
+	"\xd7\x0e\xb08YT5E\xdf\xe8*:\x14*\x8e\xa2" +
+	"\xeeK\xcad\xdd\x1eV\x8c\xaa\x8d\x93\xca>F)F" +
+	"H'aG\xb7w0K\x9b\x00L\x0a\xeb\xb8\xac\xc9" +
+	"\x95M\xb3\xb9\xda\xf1\xeb3f\x05h\x16\xbf\xab+\xd3" +
+	"\xa5\xaa\xce\x860*Mx#Iq\x1a\xbd1\x0d\x03" +
+	"\xfd7\xc6\xb8V\x98[\x084\xc2R=*(\xc6\x8b" +
+	"M\x95\x02\x9bn0\xd5\x192\xd1p4\xc3e\xf36" +
+	"P']c\x1f\xabnBC5\xab\x9aQ\x83\xcbi" +
+	"d\x82aJ\xaa\x82\xea\x08K\xb2\xf8[\x82x-\xd5" +
+	"\x03\x18\xd6\x03b\x7f2\x14(\xaa\xfe\xaa\xa2\xc5\x14;" +
+	"I\x0d\x0b\x9d\x16\xce\xce\x82 \xa3\xd3\xf2|\x1b@<" +
+	"k\xc7hD)\xee?\x08\x9c\xa8\x09\x98Lv1\x1a" +
+	"\xe4\x8a\xbb-\xe0\xc4\xed\x02r\xf1\x07\x11\x8c>f\x88" +
+	"\xa5Y\xe0\xc4M\x02\xf2\xf1W\x0c\x8c\x06\x84\xe2M\x83" +
+	"\xc0\x89\xab\x05/\xea*\xa0\x18\x88\xb3\x01\xbd(\xf0\xa1" +
+	"\xe0\x87\xfe\x06\xf4\xa2\xb1\x0aF\xdd\x07\xc0\x06<\x1c\xe6" +
+	"\xa3\x0dX\xc6\x05\xf5\x0cZ\x80\xd6u)a\xe44\x8f" +
+	"\xf2\xd1\x04#\xef\xa4Z\xf5(\x8f\xf2\xf1T\xdbu\xdf" +
+	"\xd3\xe9\xb24\x1ct\x9c<\x12\x8eI\xce\xa5\x06\x1dg" +
+	"\xa9V=\xc7\xa3\xfc:\x97$\xea\xc8\xed\xa2\xf1\x15\x9a" +
+	"V\xd4\x07.0\xc5\x0a\x9d3,\x19\x9bgY^\xd5" +
+	"\x9c\xf4KJ\x0c\xb6\xb2!A\xec\xf4\x80\xab+5\xe0" +
+	"\xc2\xa8\x03\x15\xe6\x00|z\xdc\xd5\xb50f\xce\xe9\xa7" +
+	"\xc2\xb6\x81\xbc&\xfa\x00\x83\xd1\x873Q\xa4\xdb\xcf\x0a" +
+	"^\xd4sa\x94\xae\x00\xe6^\xd9\x156\x9ec\xac`" +
+	"_N&\x88\x86\xe2My\xa0U\xc9\xe0\x9f\x93#g" +
+	"\x8b\xfb\xa0`\xdf\xa9\xd4\xd0M7\xc3\x16.7\x9a\xca" +
+	"\xda\x0bNF\xfc\xdfQ\xe5\x9b\xa3\xc5M\xee\xb72q" +
+	"\xbf\xb8@\xb8se\xd2\x0c\xc4m\xd1]#\xa1S>" +
+	"\x1aW\xba\xe2#\xb3\xc9@.v\xbf\xa7F\x92\xb6H" +
+	"`\x96\x15\xc9)\xb8V\x02\x9b\xbaY\xdb\xa2\x19\xcc\x06" +
+	"\x80\xe6i@\x83Yu\xc5`\x06:\x04F\xae\xc5\xa0" +
+	"\x19\xb9J\x1bS%\xe3B\xeaWBg7\xadTz" +
+	"M\xb5\xbe\xa7Sc\x98Hy\xf9\xf9p\xbc\xb1'\xa5" +
+	"\xfc\xee\xbd\xc9\xe0\xccS\\\xc7\xdc\xde\xa8*\xe8\xb0\xcd" +
+	"\x16\xdb\xef2\xc1Pg\x92\x06\x8eZ\x19\xd5\xde\x8e\x0d" +
+	"*:7[\xac\xb8\xdfei\x86h\xce\x0d\x82fV" +
+	"\xe7\x0d\xb8[\x14[7\xb3\xbd\x15\x1a\xa69s\xe6\xff" +
+	"\x01\\F\xaa(c\xc9\x90;\xd2D\x1bK\xf5j\x11" +
+	"\x8c\xec\x9fJ\xa6w1\x8c\xcc\xcc&\x17\xde:\xbb\xfe" +
+	"\xff$\xc4\x05\x94l9\x84\x1e+\xb2\xcb\x0a\xb4\xe4\xa3" +
+	"\xce\xa5K\xaepN\x10V\xac\x973\x0b\x993\xac\x0b" +
+	"\x1dB\x9eJ\xe6G\xbe\x93\xe7\x93\xaf\xcf\xa1\x0cvX" +
+	":\x02?a\xb6\xa8\x0a\x83\xaej\xc8\x84\x9c\xd1j\xa2" +
+	"\x83\xff;\x00\xed\xd8\xad\xde"
 
 func init() {
 	schemas.Register(schema_db8274f9144abc7e,
@@ -3939,6 +4065,7 @@ func init() {
 		0xa766b24d4fe5da35,
 		0xa78f37418c1077c8,
 		0xaa7386f356bd398a,
+		0xad63c4bd563a3761,
 		0xb14ce48f4e2abb0d,
 		0xb167b0bebe562cd0,
 		0xb70431c0dc014915,
@@ -38,17 +38,30 @@ var stripWebsocketHeaders = []string{
 // but implements a ReadWriter
 type Conn struct {
 	*websocket.Conn
+	// reader is the message being read, messages larger than the buffer passed to Read take several calls
+	reader io.Reader
 }
 
 // Read will read messages from the websocket connection
 func (c *Conn) Read(p []byte) (int, error) {
-	_, message, err := c.Conn.ReadMessage()
-	if err != nil {
-		return 0, err
+	for {
+		if c.reader == nil {
+			_, reader, err := c.Conn.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.reader = reader
+		}
+		n, err := c.reader.Read(p)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
 	}
-
-	return copy(p, message), nil
-
 }
 
 // Write will write messages to the websocket connection
@@ -81,6 +94,24 @@ func ClientConnect(req *http.Request, tlsClientConfig *tls.Config) (*websocket.C
 	return conn, response, err
 }
 
+// Dial opens a websocket to the URL of req with the headers of req, through the proxy in the HTTPS_PROXY
+// environment variable if it's set. Unlike ClientConnect, the websocket is for cloudflared itself, e.g. to relay a
+// local TCP connection to a tunnel hostname with Stream. Caller is responsible for closing the connection.
+func Dial(req *http.Request, tlsClientConfig *tls.Config) (*Conn, *http.Response, error) {
+	req.URL.Scheme = changeRequestScheme(req)
+	wsHeaders := websocketHeaders(req)
+
+	d := &websocket.Dialer{
+		TLSClientConfig: tlsClientConfig,
+		Proxy:           http.ProxyFromEnvironment,
+	}
+	conn, response, err := d.Dial(req.URL.String(), wsHeaders)
+	if err != nil {
+		return nil, response, err
+	}
+	return &Conn{Conn: conn}, response, nil
+}
+
 // HijackConnection takes over an HTTP connection. Caller is responsible for closing connection.
 func HijackConnection(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
 	hj, ok := w.(http.Hijacker)
@@ -153,7 +184,7 @@ func StartProxyServer(logger *logrus.Logger, listener net.Listener, remote strin
 			done <- struct{}{}
 			conn.Close()
 		}()
-		Stream(&Conn{conn}, stream)
+		Stream(&Conn{Conn: conn}, stream)
 	})
 
 	return httpServer.Serve(listener)